@@ -2,19 +2,35 @@ package main
 
 import (
 	"log"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/evdev"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatecontrol"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/input"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/journal"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/printer"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/signedtoken"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/usbdev"
 	"github.com/vaughan0/go-ini"
 )
 
 type Config struct {
 	Application ApplicationConfig
 	Terminal    TerminalConfig
-	Gate        GateConfig
+	Gates       []GateConfig
+	Printers    []PrinterConfig
+	Inputs      []InputConfig
 	RabbitMQ    RabbitMQConfig
 	Scanners    []ScannerConfig
+	Keys        []signedtoken.Key
+	Journal     JournalConfig
 }
 
 type ApplicationConfig struct {
@@ -23,6 +39,11 @@ type ApplicationConfig struct {
 	ShutdownTimeout int64
 	PrintTimeout    int64
 	InfluxUrl       string
+	// OperatorAPI is the listen address of the local operator API, if any.
+	OperatorAPI string
+	// OperatorAPIToken is the bearer token required by the operator API, if
+	// any. It is required unless the API listens on a loopback address.
+	OperatorAPIToken string
 }
 
 type TerminalConfig struct {
@@ -33,8 +54,107 @@ type TerminalConfig struct {
 type GateConfig struct {
 	Name           string
 	Purpose        agent.GatePurpose
+	Driver         GateDriverConfig
 	Cmd            string
+	CloseCmd       string
+	StatusCmd      string
+	CommandTimeout time.Duration
+	AutoClose      time.Duration
+	StatusInterval time.Duration
 	ReEntryTimeOut int
+	Formats        scanner.Formats
+	AcceptOffline  bool
+	Display        string
+	Queue          agent.QueueOptions
+	Timeouts       agent.Timeouts
+	// Printer is the name of the ticket printer of the gate. Without a
+	// printer, the agent waits printTimeout for the ticket to be taken.
+	Printer string
+	// Passage is the name of the input detecting vehicles passing the gate.
+	// Without an input, requests finish once the gate was opened.
+	Passage        string
+	PassageTimeout time.Duration
+}
+
+// GateDriverConfig configures how a gate is actuated. The shell driver runs
+// the commands of the gate, all other drivers switch relays.
+type GateDriverConfig struct {
+	Name  string
+	Pulse time.Duration
+	// OpenRelay and CloseRelay are the coils, relays or GPIO lines opening
+	// and closing the gate. CloseRelay is only used if HasCloseRelay is set.
+	OpenRelay     int
+	CloseRelay    int
+	HasCloseRelay bool
+	Address       string
+	Unit          byte
+	URL           string
+	Method        string
+	Chip          string
+	ActiveLow     bool
+	Path          string
+	Serial        scanner.SerialOptions
+	// OpenOn, OpenOff, CloseOn and CloseOff are the commands switching the
+	// relays of serial relay cards.
+	OpenOn   []byte
+	OpenOff  []byte
+	CloseOn  []byte
+	CloseOff []byte
+	// OpenedInput and ClosedInput are the names of the limit switches the
+	// state of the gate is read from.
+	OpenedInput string
+	ClosedInput string
+}
+
+// canClose reports whether the driver is able to close the gate, given the
+// close command of the gate.
+func (d GateDriverConfig) canClose(closeCmd string) bool {
+	if d.Name == "shell" {
+		return closeCmd != ""
+	}
+	return d.HasCloseRelay || d.Pulse == 0
+}
+
+// DefaultDisplay is the listen address of the traffic light display of an
+// agent serving a single gate.
+const DefaultDisplay = "localhost:8080"
+
+type PrinterConfig struct {
+	Name          string
+	Driver        string
+	Address       string
+	Path          string
+	Serial        scanner.SerialOptions
+	StatusTimeout time.Duration
+	// ConfirmTimeout is the time a print service has to confirm a print.
+	ConfirmTimeout time.Duration
+	Title          string
+}
+
+// DefaultTicketTitle is printed on top of interchange tickets, unless
+// configured otherwise.
+const DefaultTicketTitle = "Interchange"
+
+type InputConfig struct {
+	Name         string
+	Driver       string
+	Chip         string
+	Line         uint32
+	Path         string
+	Serial       scanner.SerialOptions
+	Query        input.SerialQuery
+	Address      string
+	Unit         byte
+	Register     uint16
+	ActiveLow    bool
+	PollInterval time.Duration
+}
+
+// JournalConfig configures the journal of scan requests, disabled without
+// path.
+type JournalConfig struct {
+	Path    string
+	Options journal.Options
 }
 
 type RabbitMQConfig struct {
@@ -42,10 +162,22 @@ type RabbitMQConfig struct {
 }
 
 type ScannerConfig struct {
-	Name   string
-	Driver string
-	Path   string
-	Prefix string
+	Name     string
+	Gate     string
+	Driver   string
+	Path     string
+	USB      usbdev.Selector
+	Address  string
+	Prefix   string
+	Framing  scanner.Framing
+	Layout   evdev.Layout
+	TCP      scanner.TCPOptions
+	Serial   scanner.SerialOptions
+	RFID     scanner.RFIDProtocol
+	Feedback scanner.FeedbackCommands
+	Debounce time.Duration
+	Backoff  scanner.Backoff
+	Liveness scanner.Liveness
 }
 
 func ReadConfig(path string) Config {
@@ -54,12 +186,20 @@ func ReadConfig(path string) Config {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	gates := readGatesConfig(inifile)
+	printers := readPrintersConfig(inifile, gates)
+	inputs := readInputsConfig(inifile, gates)
+
 	return Config{
 		Application: readApplicationConfig(inifile),
 		Terminal:    readTerminalConfig(inifile),
-		Gate:        readGateConfig(inifile),
+		Gates:       gates,
+		Printers:    printers,
+		Inputs:      inputs,
 		RabbitMQ:    readRabbitMQConfig(inifile),
-		Scanners:    readScannerConfig(inifile),
+		Scanners:    readScannerConfig(inifile, gates),
+		Keys:        readKeysConfig(inifile),
+		Journal:     readJournalConfig(inifile),
 	}
 }
 
@@ -82,7 +222,31 @@ func readApplicationConfig(config ini.File) ApplicationConfig {
 		log.Fatalf("No influxURL provided")
 	}
 
-	return ApplicationConfig{name, instance, shutdownTimeout, printTimeout, *influxUrl}
+	operatorAPI, operatorAPIToken := "", ""
+	if value := confOptional(config, "application", "operatorApiToken"); value != nil {
+		operatorAPIToken = *value
+	}
+	if value := confOptional(config, "application", "operatorApi"); value != nil {
+		operatorAPI = readOperatorAPIAddress(*value, operatorAPIToken)
+	}
+
+	return ApplicationConfig{name, instance, shutdownTimeout, printTimeout, *influxUrl, operatorAPI, operatorAPIToken}
+}
+
+// readOperatorAPIAddress returns the listen address of the operator API.
+// Addresses without host listen on localhost, other hosts require a token.
+func readOperatorAPIAddress(address, token string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		log.Fatalf("[application]operatorApi is not a listen address! %v", err)
+	}
+	if host == "" {
+		return net.JoinHostPort("localhost", port)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) && token == "" {
+		log.Fatalf("[application]operatorApi on %s requires an operatorApiToken", host)
+	}
+	return address
 }
 
 func readTerminalConfig(config ini.File) TerminalConfig {
@@ -94,12 +258,54 @@ func readTerminalConfig(config ini.File) TerminalConfig {
 	return TerminalConfig{location, loadingplace}
 }
 
-func readGateConfig(config ini.File) GateConfig {
-	purpose, err := agent.NewGatePurpose(conf(config, "gate", "purpose"))
+// readGatesConfig reads the gates served by the agent from the [gate X]
+// sections, or from the [gate] section of a single gate agent.
+func readGatesConfig(config ini.File) []GateConfig {
+	var gates []GateConfig
+
+	for section := range config {
+		if strings.HasPrefix(section, "gate ") {
+			gates = append(gates, readGateConfig(config, section, strings.TrimPrefix(section, "gate ")))
+		}
+	}
+	sort.Slice(gates, func(i, j int) bool { return gates[i].Name < gates[j].Name })
+
+	if _, ok := config["gate"]; ok {
+		if len(gates) > 0 {
+			log.Fatalf("[gate] cannot be combined with [gate X] sections")
+		}
+		gates = append(gates, readGateConfig(config, "gate", conf(config, "gate", "name")))
+	}
+
+	switch len(gates) {
+	case 0:
+		log.Fatalf("No gate configured")
+	case 1:
+		if gates[0].Display == "" {
+			gates[0].Display = DefaultDisplay
+		}
+	default:
+		displays := map[string]string{}
+		for _, gate := range gates {
+			if gate.Display == "" {
+				log.Fatalf("[gate %s]display is required for agents serving multiple gates", gate.Name)
+			}
+			if other, ok := displays[gate.Display]; ok {
+				log.Fatalf("[gate %s]display %s is already used by gate %s", gate.Name, gate.Display, other)
+			}
+			displays[gate.Display] = gate.Name
+		}
+	}
+
+	return gates
+}
+
+func readGateConfig(config ini.File, section, name string) GateConfig {
+	purpose, err := agent.NewGatePurpose(conf(config, section, "purpose"))
 	if err != nil {
-		log.Fatalf("[gate]purpose is not valid! %v", err)
+		log.Fatalf("[%s]purpose is not valid! %v", section, err)
 	}
-	reentryTimeoutStr := confOptional(config, "gate", "reEntryTimeout")
+	reentryTimeoutStr := confOptional(config, section, "reEntryTimeout")
 	reEntryTimeout := 5
 	if reentryTimeoutStr != nil {
 		log.Println("Not assuming default", reentryTimeoutStr)
@@ -108,16 +314,371 @@ func readGateConfig(config ini.File) GateConfig {
 			log.Fatalln("Cannot parse", reentryTimeoutStr)
 		}
 	}
-	log.Println("ReEntry timeout is", reEntryTimeout, "min")
+	log.Println("ReEntry timeout of gate", name, "is", reEntryTimeout, "min")
 	gate := GateConfig{
-		Name:           conf(config, "gate", "name"),
+		Name:           name,
 		Purpose:        purpose,
-		Cmd:            conf(config, "gate", "command"),
+		Driver:         readGateDriverConfig(config, section),
 		ReEntryTimeOut: reEntryTimeout,
+		Formats:        readFormatsConfig(config, section),
+		AcceptOffline:  confOptionalBool(config, section, "acceptOffline", false),
+	}
+	if display := confOptional(config, section, "display"); display != nil {
+		gate.Display = *display
+	}
+	gate.Queue = readQueueConfig(config, section)
+	gate.Timeouts = readTimeoutsConfig(config, section)
+	if gate.AcceptOffline {
+		// Stages ending before Gate-Control timed out never pass offline.
+		if d := gate.Timeouts.Validate; d > 0 && d <= gatecontrol.Timeout {
+			log.Fatalf("[%s]timeoutValidate must exceed the Gate-Control timeout of %v to accept signed tokens offline", section, gatecontrol.Timeout)
+		}
+		if d := gate.Timeouts.Gate; d > 0 && d <= gatecontrol.Timeout {
+			log.Fatalf("[%s]timeoutGate must exceed the Gate-Control timeout of %v to accept signed tokens offline", section, gatecontrol.Timeout)
+		}
+	}
+	if value := confOptional(config, section, "printer"); value != nil {
+		gate.Printer = *value
+	}
+	if gate.Driver.Name == "shell" {
+		gate.Cmd = conf(config, section, "command")
+		if value := confOptional(config, section, "closeCommand"); value != nil {
+			gate.CloseCmd = *value
+		}
+		if value := confOptional(config, section, "statusCommand"); value != nil {
+			gate.StatusCmd = *value
+		}
+		gate.CommandTimeout = confOptionalDuration(config, section, "commandTimeout", gatedriver.DefaultCommandTimeout)
+		if gate.CommandTimeout < 0 {
+			log.Fatalf("[%s]commandTimeout must not be negative", section)
+		}
+	}
+	gate.AutoClose = confOptionalDuration(config, section, "autoClose", 0)
+	if gate.AutoClose > 0 && !gate.Driver.canClose(gate.CloseCmd) {
+		log.Fatalf("[%s]autoClose requires a closeCommand, a closeRelay or a latching relay", section)
+	}
+	gate.StatusInterval = confOptionalDuration(config, section, "statusInterval", 10*time.Second)
+	if gate.AutoClose < 0 || gate.StatusInterval <= 0 {
+		log.Fatalf("[%s]autoClose must not be negative and statusInterval must be positive", section)
+	}
+	if value := confOptional(config, section, "passage"); value != nil {
+		gate.Passage = *value
+	}
+	gate.PassageTimeout = confOptionalDuration(config, section, "passageTimeout", input.DefaultPassageTimeout)
+	if gate.PassageTimeout <= 0 {
+		log.Fatalf("[%s]passageTimeout must be positive", section)
 	}
 	return gate
 }
 
+// readGateDriverConfig reads the driver of a gate. Gates are driven by shell
+// commands, unless configured otherwise.
+func readGateDriverConfig(config ini.File, section string) GateDriverConfig {
+	d := GateDriverConfig{Name: "shell"}
+	if value := confOptional(config, section, "driver"); value != nil {
+		d.Name = *value
+	}
+	if d.Name == "shell" {
+		return d
+	}
+
+	d.Pulse = confOptionalDuration(config, section, "pulse", gatedriver.DefaultPulse)
+	if d.Pulse < 0 {
+		log.Fatalf("[%s]pulse must not be negative", section)
+	}
+	d.OpenRelay = confOptionalInt(config, section, "openRelay", -1)
+	d.CloseRelay = confOptionalInt(config, section, "closeRelay", -1)
+	d.HasCloseRelay = d.Name != "serial-relay" && d.CloseRelay >= 0
+
+	switch d.Name {
+	case "modbus":
+		d.Address = conf(config, section, "address")
+		d.Unit = byte(confOptionalInt(config, section, "unit", 1))
+	case "http":
+		d.URL = conf(config, section, "url")
+		d.Method = "POST"
+		if value := confOptional(config, section, "method"); value != nil {
+			d.Method = strings.ToUpper(*value)
+		}
+	case "gpio":
+		d.Chip = conf(config, section, "chip")
+		d.ActiveLow = confOptionalBool(config, section, "activeLow", false)
+	case "serial-relay":
+		d.Path = conf(config, section, "path")
+		d.Serial = readSerialConfig(config, section, scanner.DefaultSerialOptions)
+		d.OpenOn = confCommand(config, section, "openOn")
+		d.OpenOff = confCommand(config, section, "openOff")
+		if confOptional(config, section, "closeOn") != nil {
+			d.CloseOn = confCommand(config, section, "closeOn")
+			d.CloseOff = confCommand(config, section, "closeOff")
+			d.HasCloseRelay = true
+		}
+	default:
+		log.Fatalf("[%s]driver is not valid! Must be shell, modbus, http, gpio or serial-relay", section)
+	}
+	if d.Name != "serial-relay" && d.OpenRelay < 0 {
+		log.Fatalf("[%s]openRelay is required for driver %s", section, d.Name)
+	}
+
+	if value := confOptional(config, section, "openedInput"); value != nil {
+		d.OpenedInput = *value
+	}
+	if value := confOptional(config, section, "closedInput"); value != nil {
+		d.ClosedInput = *value
+	}
+	if (d.OpenedInput == "") != (d.ClosedInput == "") {
+		log.Fatalf("[%s]openedInput and closedInput must be configured together", section)
+	}
+	return d
+}
+
+// confCommand reads a required command sent to a device. Escape sequences
+// like \r or \x1b are replaced.
+func confCommand(config ini.File, section, key string) []byte {
+	value := conf(config, section, key)
+	cmd, err := strconv.Unquote(`"` + value + `"`)
+	if err != nil {
+		log.Fatalf("[%s]%s is not valid! %v", section, key, err)
+	}
+	return []byte(cmd)
+}
+
+// readPrintersConfig reads the ticket printers from the [printer X] sections.
+// Every printer referred to by a gate must be defined.
+func readPrintersConfig(config ini.File, gates []GateConfig) []PrinterConfig {
+	var printers []PrinterConfig
+	defined := map[string]bool{}
+
+	for section := range config {
+		if !strings.HasPrefix(section, "printer ") {
+			continue
+		}
+		name := strings.TrimPrefix(section, "printer ")
+		p := PrinterConfig{
+			Name:          name,
+			Driver:        conf(config, section, "driver"),
+			StatusTimeout: confOptionalDuration(config, section, "statusTimeout", printer.DefaultStatusTimeout),
+			Title:         DefaultTicketTitle,
+		}
+		if title := confOptional(config, section, "title"); title != nil {
+			p.Title = *title
+		}
+
+		switch p.Driver {
+		case "tcp":
+			p.Address = conf(config, section, "address")
+		case "serial":
+			p.Path = conf(config, section, "path")
+			p.Serial = readSerialConfig(config, section, printer.DefaultSerialOptions)
+		case "usb":
+			p.Path = conf(config, section, "path")
+		case "amqp":
+			p.ConfirmTimeout = confOptionalDuration(config, section, "confirmTimeout", printer.DefaultConfirmTimeout)
+		default:
+			log.Fatalf("[%s]driver is not valid! Must be tcp, serial, usb or amqp", section)
+		}
+
+		printers = append(printers, p)
+		defined[name] = true
+	}
+	sort.Slice(printers, func(i, j int) bool { return printers[i].Name < printers[j].Name })
+
+	for _, gate := range gates {
+		if gate.Printer != "" && !defined[gate.Printer] {
+			log.Fatalf("[gate %s]printer refers to undefined printer %s", gate.Name, gate.Printer)
+		}
+	}
+	return printers
+}
+
+// readTimeoutsConfig reads the time a scan request may spend in each stage.
+func readTimeoutsConfig(config ini.File, section string) agent.Timeouts {
+	timeouts := agent.Timeouts{
+		Validate: confOptionalDuration(config, section, "timeoutValidate", 30*time.Second),
+		Print:    confOptionalDuration(config, section, "timeoutPrint", time.Minute),
+		Gate:     confOptionalDuration(config, section, "timeoutGate", time.Minute),
+		Pass:     confOptionalDuration(config, section, "timeoutPass", 2*time.Minute),
+		Error:    confOptionalDuration(config, section, "timeoutError", 10*time.Second),
+	}
+	for _, d := range []time.Duration{timeouts.Validate, timeouts.Print, timeouts.Gate, timeouts.Pass, timeouts.Error} {
+		if d < 0 {
+			log.Fatalf("[%s] timeouts must not be negative", section)
+		}
+	}
+	return timeouts
+}
+
+// readQueueConfig reads how scans are handled while the gate is busy.
+func readQueueConfig(config ini.File, section string) agent.QueueOptions {
+	policy := agent.PolicyDropNewest
+	if value := confOptional(config, section, "queue"); value != nil {
+		var err error
+		if policy, err = agent.NewQueuePolicy(*value); err != nil {
+			log.Fatalf("[%s]queue is not valid! %v", section, err)
+		}
+	}
+
+	options := agent.QueueOptions{
+		Policy: policy,
+		Size:   confOptionalInt(config, section, "queueSize", 3),
+		MaxAge: confOptionalDuration(config, section, "queueMaxAge", 2*time.Minute),
+	}
+	if options.Size < 1 || options.MaxAge < 0 {
+		log.Fatalf("[%s]queueSize must be positive and queueMaxAge must not be negative", section)
+	}
+	return options
+}
+
+// readFormatsConfig reads the token formats accepted by a gate. Formats are
+// either built-in or defined in a [format X] section.
+func readFormatsConfig(config ini.File, section string) scanner.Formats {
+	value := confOptional(config, section, "formats")
+	if value == nil {
+		return scanner.DefaultFormats
+	}
+
+	var formats scanner.Formats
+	for _, name := range strings.Split(*value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if format, ok := scanner.BuiltinFormat(name); ok {
+			formats = append(formats, format)
+			continue
+		}
+
+		formatSection := "format " + name
+		if _, ok := config[formatSection]; !ok {
+			log.Fatalf("[%s]formats refers to undefined format %s", section, name)
+		}
+		prefix, _ := config.Get(formatSection, "prefix")
+		format, err := scanner.NewPatternFormat(
+			name,
+			scanner.ScanSource(conf(config, formatSection, "source")),
+			prefix,
+			conf(config, formatSection, "pattern"),
+		)
+		if err != nil {
+			log.Fatalf("[%s] is not valid! %v", formatSection, err)
+		}
+		formats = append(formats, format)
+	}
+
+	if len(formats) == 0 {
+		log.Fatalf("[%s]formats is empty", section)
+	}
+	return formats
+}
+
+// readKeysConfig reads the keys signed tokens are verified with from the
+// [key <id>] sections.
+func readKeysConfig(config ini.File) []signedtoken.Key {
+	var keys []signedtoken.Key
+
+	for section := range config {
+		if strings.HasPrefix(section, "key ") {
+			key, err := signedtoken.NewKey(
+				strings.TrimPrefix(section, "key "),
+				signedtoken.Algorithm(conf(config, section, "algorithm")),
+				conf(config, section, "key"),
+			)
+			if err != nil {
+				log.Fatalf("[%s] is not valid! %v", section, err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// readInputsConfig reads the digital inputs from the [input X] sections. Every
+// input referred to by a gate must be defined.
+func readInputsConfig(config ini.File, gates []GateConfig) []InputConfig {
+	var inputs []InputConfig
+	defined := map[string]bool{}
+
+	for section := range config {
+		if !strings.HasPrefix(section, "input ") {
+			continue
+		}
+		name := strings.TrimPrefix(section, "input ")
+		in := InputConfig{
+			Name:         name,
+			Driver:       conf(config, section, "driver"),
+			ActiveLow:    confOptionalBool(config, section, "activeLow", false),
+			PollInterval: confOptionalDuration(config, section, "pollInterval", input.DefaultPollInterval),
+		}
+
+		switch in.Driver {
+		case "gpio":
+			in.Chip = conf(config, section, "chip")
+			line := confOptionalInt(config, section, "line", -1)
+			if line < 0 {
+				log.Fatalf("[%s]line is required", section)
+			}
+			in.Line = uint32(line)
+		case "serial":
+			in.Path = conf(config, section, "path")
+			in.Serial = readSerialConfig(config, section, scanner.DefaultSerialOptions)
+			query, err := input.NewSerialQuery(
+				conf(config, section, "query"),
+				conf(config, section, "response"),
+				uint(confOptionalInt(config, section, "bit", 0)),
+			)
+			if err != nil {
+				log.Fatalf("[%s] query is not valid! %v", section, err)
+			}
+			in.Query = query
+		case "modbus":
+			in.Address = conf(config, section, "address")
+			in.Unit = byte(confOptionalInt(config, section, "unit", 1))
+			in.Register = uint16(confOptionalInt(config, section, "input", 0))
+		default:
+			log.Fatalf("[%s]driver is not valid! Must be gpio, serial or modbus", section)
+		}
+
+		inputs = append(inputs, in)
+		defined[name] = true
+	}
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].Name < inputs[j].Name })
+
+	for _, gate := range gates {
+		if gate.Passage != "" && !defined[gate.Passage] {
+			log.Fatalf("[gate %s]passage refers to undefined input %s", gate.Name, gate.Passage)
+		}
+		for _, limit := range []string{gate.Driver.OpenedInput, gate.Driver.ClosedInput} {
+			if limit != "" && !defined[limit] {
+				log.Fatalf("[gate %s]limit switch refers to undefined input %s", gate.Name, limit)
+			}
+		}
+	}
+	return inputs
+}
+
+func readJournalConfig(config ini.File) JournalConfig {
+	if _, ok := config["journal"]; !ok {
+		return JournalConfig{}
+	}
+
+	maxSize := confOptionalInt(config, "journal", "maxSize", 100)
+	if maxSize < 0 {
+		log.Fatalf("[journal]maxSize must not be negative!")
+	}
+	tokenKey := ""
+	if value := confOptional(config, "journal", "tokenKey"); value != nil {
+		tokenKey = *value
+	}
+	return JournalConfig{
+		Path: conf(config, "journal", "path"),
+		Options: journal.Options{
+			MaxAge:   confOptionalDuration(config, "journal", "maxAge", 90*24*time.Hour),
+			MaxSize:  int64(maxSize) << 20,
+			TokenKey: tokenKey,
+		},
+	}
+}
+
 func readRabbitMQConfig(config ini.File) RabbitMQConfig {
 	rabbitMQ := RabbitMQConfig{
 		URL: conf(config, "rabbitmq", "url"),
@@ -125,22 +686,207 @@ func readRabbitMQConfig(config ini.File) RabbitMQConfig {
 	return rabbitMQ
 }
 
-func readScannerConfig(config ini.File) []ScannerConfig {
+func readScannerConfig(config ini.File, gates []GateConfig) []ScannerConfig {
 	var scanners []ScannerConfig
+	serialDefaults := scanner.DefaultSerialOptions
 
 	for section := range config {
 		if strings.HasPrefix(section, "scanner ") {
 			name := strings.TrimPrefix(section, "scanner ")
 			prefix := conf(config, section, "prefix")
 			driver := conf(config, section, "driver")
-			path := conf(config, section, "path")
-			scanners = append(scanners, ScannerConfig{name, driver, path, prefix})
+
+			scanner := ScannerConfig{
+				Name:     name,
+				Gate:     readScannerGateConfig(config, section, gates),
+				Driver:   driver,
+				Prefix:   prefix,
+				Framing:  readFramingConfig(config, section),
+				Layout:   readLayoutConfig(config, section),
+				Feedback: readFeedbackConfig(config, section),
+				Debounce: confOptionalDuration(config, section, "debounce", 0),
+				Backoff:  readBackoffConfig(config, section),
+				Liveness: readLivenessConfig(config, section),
+			}
+
+			switch driver {
+			case "tcp", "tcp-listen":
+				scanner.Address = conf(config, section, "address")
+				scanner.TCP = readTCPConfig(config, section)
+			case "simulated":
+				scanner.Address = conf(config, section, "address")
+			case "usbcom":
+				scanner.Path, scanner.USB = readDeviceConfig(config, section)
+				scanner.Serial = readSerialConfig(config, section, serialDefaults)
+			case "rfid":
+				scanner.Path, scanner.USB = readDeviceConfig(config, section)
+				scanner.Serial = readSerialConfig(config, section, serialDefaults)
+				scanner.RFID = readRFIDConfig(config, section)
+			default:
+				scanner.Path, scanner.USB = readDeviceConfig(config, section)
+			}
+
+			scanners = append(scanners, scanner)
 		}
 	}
 
 	return scanners
 }
 
+// readScannerGateConfig reads the gate a scanner reads tokens for. It may be
+// omitted for agents serving a single gate.
+func readScannerGateConfig(config ini.File, section string, gates []GateConfig) string {
+	name := confOptional(config, section, "gate")
+	if name == nil {
+		if len(gates) > 1 {
+			log.Fatalf("[%s]gate is required for agents serving multiple gates", section)
+		}
+		return gates[0].Name
+	}
+
+	for _, gate := range gates {
+		if gate.Name == *name {
+			return gate.Name
+		}
+	}
+	log.Fatalf("[%s]gate refers to undefined gate %s", section, *name)
+	return ""
+}
+
+func readTCPConfig(config ini.File, section string) scanner.TCPOptions {
+	return scanner.TCPOptions{
+		ConnectTimeout: confOptionalDuration(config, section, "connectTimeout", scanner.DefaultTCPOptions.ConnectTimeout),
+		KeepAlive:      confOptionalDuration(config, section, "keepAlive", scanner.DefaultTCPOptions.KeepAlive),
+	}
+}
+
+func readFeedbackConfig(config ini.File, section string) scanner.FeedbackCommands {
+	model := confOptional(config, section, "feedback")
+	if model == nil {
+		return scanner.FeedbackCommands{}
+	}
+
+	feedbackSection := "feedback " + *model
+	if _, ok := config[feedbackSection]; !ok {
+		log.Fatalf("[%s]feedback refers to missing section [%s]", section, feedbackSection)
+	}
+	good, _ := config.Get(feedbackSection, "good")
+	bad, _ := config.Get(feedbackSection, "bad")
+
+	cmds, err := scanner.NewFeedbackCommands(good, bad)
+	if err != nil {
+		log.Fatalf("[%s] is not valid! %v", feedbackSection, err)
+	}
+	return cmds
+}
+
+// readDeviceConfig reads the path of a scanner device, or the USB attributes
+// it is resolved by.
+func readDeviceConfig(config ini.File, section string) (string, usbdev.Selector) {
+	var usb usbdev.Selector
+	for key, value := range map[string]*string{
+		"usbVendor":  &usb.Vendor,
+		"usbProduct": &usb.Product,
+		"usbSerial":  &usb.Serial,
+		"usbPort":    &usb.Port,
+	} {
+		if v := confOptional(config, section, key); v != nil {
+			*value = *v
+		}
+	}
+
+	if usb.IsZero() {
+		return conf(config, section, "path"), usb
+	}
+	if confOptional(config, section, "path") != nil {
+		log.Fatalf("[%s] either path or usb attributes can be set", section)
+	}
+	return "", usb
+}
+
+func readRFIDConfig(config ini.File, section string) scanner.RFIDProtocol {
+	name := ""
+	if value := confOptional(config, section, "protocol"); value != nil {
+		name = *value
+	}
+	protocol, err := scanner.ParseRFIDProtocol(name)
+	if err != nil {
+		log.Fatalf("[%s]protocol is not valid! %v", section, err)
+	}
+	return protocol
+}
+
+func readLivenessConfig(config ini.File, section string) scanner.Liveness {
+	probe, _ := config.Get(section, "probe")
+	response, _ := config.Get(section, "probeResponse")
+	hoursValue, _ := config.Get(section, "operatingHours")
+
+	hours, err := scanner.ParseOperatingHours(hoursValue)
+	if err != nil {
+		log.Fatalf("[%s]operatingHours is not valid! %v", section, err)
+	}
+	liveness, err := scanner.NewLiveness(
+		probe,
+		response,
+		confOptionalDuration(config, section, "probeInterval", 0),
+		confOptionalInt(config, section, "probeFailures", 0),
+		confOptionalDuration(config, section, "maxIdle", 0),
+		hours,
+	)
+	if err != nil {
+		log.Fatalf("[%s] liveness probe is not valid! %v", section, err)
+	}
+	return liveness
+}
+
+func readBackoffConfig(config ini.File, section string) scanner.Backoff {
+	backoff := scanner.DefaultBackoff
+	backoff.Initial = confOptionalDuration(config, section, "reopenInitial", backoff.Initial)
+	backoff.Max = confOptionalDuration(config, section, "reopenMax", backoff.Max)
+	backoff.Multiplier = confOptionalFloat(config, section, "reopenMultiplier", backoff.Multiplier)
+	backoff.Jitter = confOptionalFloat(config, section, "reopenJitter", backoff.Jitter)
+
+	if err := backoff.Validate(); err != nil {
+		log.Fatalf("[%s] reopen backoff is not valid! %v", section, err)
+	}
+	return backoff
+}
+
+func readSerialConfig(config ini.File, section string, defaults scanner.SerialOptions) scanner.SerialOptions {
+	opts := defaults
+	opts.BaudRate = uint(confOptionalInt(config, section, "baudRate", int(opts.BaudRate)))
+	opts.DataBits = uint(confOptionalInt(config, section, "dataBits", int(opts.DataBits)))
+	if parity := confOptional(config, section, "parity"); parity != nil {
+		opts.Parity = *parity
+	}
+	opts.StopBits = uint(confOptionalInt(config, section, "stopBits", int(opts.StopBits)))
+	opts.RTSCTSFlowControl = confOptionalBool(config, section, "rtscts", opts.RTSCTSFlowControl)
+	opts.InterCharacterTimeout = confOptionalDuration(config, section, "interCharacterTimeout", opts.InterCharacterTimeout)
+	opts.MinimumReadSize = uint(confOptionalInt(config, section, "minimumReadSize", int(opts.MinimumReadSize)))
+
+	if err := opts.Validate(); err != nil {
+		log.Fatalf("[%s] serial port configuration is not valid! %v", section, err)
+	}
+	return opts
+}
+
+func readFramingConfig(config ini.File, section string) scanner.Framing {
+	mode := ""
+	if value := confOptional(config, section, "framing"); value != nil {
+		mode = *value
+	}
+	framing, err := scanner.NewFraming(
+		mode,
+		confOptionalInt(config, section, "frameLength", 0),
+		confOptionalDuration(config, section, "frameIdleGap", 0),
+		confOptionalInt(config, section, "maxFrameLength", 0),
+	)
+	if err != nil {
+		log.Fatalf("[%s]framing is not valid! %v", section, err)
+	}
+	return framing
+}
+
 func confOptional(config ini.File, section, key string) *string {
 	value, ok := config.Get(section, key)
 	if !ok {
@@ -149,6 +895,66 @@ func confOptional(config ini.File, section, key string) *string {
 	return &value
 }
 
+func readLayoutConfig(config ini.File, section string) evdev.Layout {
+	name := ""
+	if value := confOptional(config, section, "layout"); value != nil {
+		name = *value
+	}
+	layout, err := evdev.LayoutByName(name)
+	if err != nil {
+		log.Fatalf("[%s]layout is not valid! %v", section, err)
+	}
+	return layout
+}
+
+func confOptionalInt(config ini.File, section, key string, def int) int {
+	value := confOptional(config, section, key)
+	if value == nil {
+		return def
+	}
+	i, err := strconv.Atoi(*value)
+	if err != nil {
+		log.Fatalf("[%s]%s is not an integer!", section, key)
+	}
+	return i
+}
+
+func confOptionalBool(config ini.File, section, key string, def bool) bool {
+	value := confOptional(config, section, key)
+	if value == nil {
+		return def
+	}
+	b, err := strconv.ParseBool(*value)
+	if err != nil {
+		log.Fatalf("[%s]%s is not a boolean!", section, key)
+	}
+	return b
+}
+
+func confOptionalFloat(config ini.File, section, key string, def float64) float64 {
+	value := confOptional(config, section, key)
+	if value == nil {
+		return def
+	}
+	f, err := strconv.ParseFloat(*value, 64)
+	if err != nil {
+		log.Fatalf("[%s]%s is not a number!", section, key)
+	}
+	return f
+}
+
+func confOptionalDuration(config ini.File, section, key string, def time.Duration) time.Duration {
+	value := confOptional(config, section, key)
+	if value == nil {
+		return def
+	}
+	d, err := time.ParseDuration(*value)
+	if err != nil {
+		log.Fatalf("[%s]%s is not a duration!", section, key)
+	}
+	return d
+}
+
 func conf(config ini.File, section string, key string) string {
 	value, ok := config.Get(section, key)
 	if !ok {
@@ -164,3 +970,34 @@ func (c *Config) ScannerNames() []string {
 	}
 	return names
 }
+
+// GateScanners returns the scanners reading tokens for gate.
+func (c *Config) GateScanners(gate string) []ScannerConfig {
+	var scanners []ScannerConfig
+	for _, s := range c.Scanners {
+		if s.Gate == gate {
+			scanners = append(scanners, s)
+		}
+	}
+	return scanners
+}
+
+// Printer returns the configuration of the named printer.
+func (c *Config) Printer(name string) PrinterConfig {
+	for _, p := range c.Printers {
+		if p.Name == name {
+			return p
+		}
+	}
+	return PrinterConfig{}
+}
+
+// Input returns the configuration of the named input.
+func (c *Config) Input(name string) InputConfig {
+	for _, in := range c.Inputs {
+		if in.Name == name {
+			return in
+		}
+	}
+	return InputConfig{}
+}
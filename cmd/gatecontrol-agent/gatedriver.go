@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+)
+
+// newGateDriver creates the driver of the gate described by gateConfig.
+func newGateDriver(gateConfig GateConfig) gatedriver.Driver {
+	d := gateConfig.Driver
+	if d.Name == "shell" {
+		return &gatedriver.Shell{
+			OpenCmd:   gateConfig.Cmd,
+			CloseCmd:  gateConfig.CloseCmd,
+			StatusCmd: gateConfig.StatusCmd,
+			Timeout:   gateConfig.CommandTimeout,
+		}
+	}
+
+	var open, close gatedriver.SwitchOpener
+	switch d.Name {
+	case "modbus":
+		open = gatedriver.ModbusCoil(d.Address, d.Unit, uint16(d.OpenRelay))
+		if d.HasCloseRelay {
+			close = gatedriver.ModbusCoil(d.Address, d.Unit, uint16(d.CloseRelay))
+		}
+	case "http":
+		open = gatedriver.HTTPRelay(http.DefaultClient, d.Method, d.URL, d.OpenRelay)
+		if d.HasCloseRelay {
+			close = gatedriver.HTTPRelay(http.DefaultClient, d.Method, d.URL, d.CloseRelay)
+		}
+	case "gpio":
+		open = gatedriver.GPIOLine(d.Chip, uint32(d.OpenRelay), d.ActiveLow)
+		if d.HasCloseRelay {
+			close = gatedriver.GPIOLine(d.Chip, uint32(d.CloseRelay), d.ActiveLow)
+		}
+	case "serial-relay":
+		open = gatedriver.SerialRelay(d.Path, d.Serial, d.OpenOn, d.OpenOff)
+		if d.HasCloseRelay {
+			close = gatedriver.SerialRelay(d.Path, d.Serial, d.CloseOn, d.CloseOff)
+		}
+	}
+
+	var limits *gatedriver.Limits
+	if d.OpenedInput != "" {
+		limits = &gatedriver.Limits{
+			Opened: inputOpener(config.Input(d.OpenedInput)),
+			Closed: inputOpener(config.Input(d.ClosedInput)),
+		}
+	}
+	return gatedriver.NewRelays(d.Name, open, close, d.Pulse, limits)
+}
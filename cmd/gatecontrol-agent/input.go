@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/input"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+)
+
+// newDetector creates a passage detector reading the input described by
+// inputConfig. Vehicles have to pass within timeout.
+func newDetector(inputConfig InputConfig, timeout time.Duration) *input.Detector {
+	return input.NewDetector(inputConfig.Name, inputOpener(inputConfig), inputConfig.PollInterval, timeout)
+}
+
+func inputOpener(inputConfig InputConfig) input.Opener {
+	return input.Opener(func() (input.Input, error) {
+		var in input.Input
+		switch inputConfig.Driver {
+		case "gpio":
+			gpio, err := input.OpenGPIO(inputConfig.Chip, inputConfig.Line)
+			if err != nil {
+				return nil, err
+			}
+			in = gpio
+		case "serial":
+			port, err := scanner.OpenSerialPort(inputConfig.Path, inputConfig.Serial)
+			if err != nil {
+				return nil, err
+			}
+			in = input.NewSerialModule(port, inputConfig.Query)
+		default:
+			module, err := input.OpenModbus(inputConfig.Address, inputConfig.Unit, inputConfig.Register)
+			if err != nil {
+				return nil, err
+			}
+			in = module
+		}
+
+		if inputConfig.ActiveLow {
+			return input.Invert(in), nil
+		}
+		return in, nil
+	})
+}
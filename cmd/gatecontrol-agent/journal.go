@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/journal"
+)
+
+// journalCommand queries the journal of the agent configured and writes the
+// entries found to stdout, e.g.
+//
+//	gatecontrol-agent journal -from 2021-03-01 -to 2021-03-07 -token 4711 -format csv
+func journalCommand(args []string) {
+	flags := flag.NewFlagSet("journal", flag.ExitOnError)
+	path := flags.String("config", "./config.ini", "configure location of config file")
+	from := flags.String("from", "", "list entries since `time`, e.g. 2021-03-01 or 2021-03-01T08:00")
+	to := flags.String("to", "", "list entries before `time`, dates include the whole day")
+	gate := flags.String("gate", "", "list entries of `gate`")
+	token := flags.String("token", "", "list entries of `token`")
+	outcome := flags.String("outcome", "", "list entries with `outcome`: granted, completed, failed, rejected or opened")
+	format := flags.String("format", "csv", "export entries as csv or json")
+	flags.Parse(args)
+
+	log.SetFlags(0)
+	agentConfig := ReadConfig(*path)
+	if agentConfig.Journal.Path == "" {
+		log.Fatalf("No journal configured in %s", *path)
+	}
+
+	filter := journal.Filter{Gate: *gate, Token: *token}
+	if *from != "" {
+		filter.From = parseJournalTime("from", *from, false)
+	}
+	if *to != "" {
+		filter.To = parseJournalTime("to", *to, true)
+	}
+	if *token != "" && agentConfig.Journal.Options.TokenKey != "" {
+		filter.Token = journal.HashToken(agentConfig.Journal.Options.TokenKey, *token)
+	}
+	if *outcome != "" {
+		o, err := journal.NewOutcome(*outcome)
+		if err != nil {
+			log.Fatalf("-outcome is not valid! %v", err)
+		}
+		filter.Outcome = o
+	}
+
+	var write func(io.Writer, []journal.Entry) error
+	switch *format {
+	case "csv":
+		write = journal.WriteCSV
+	case "json":
+		write = journal.WriteJSON
+	default:
+		log.Fatalf("Undefined format %s", *format)
+	}
+
+	entries, err := journal.Query(agentConfig.Journal.Path, filter)
+	if err != nil {
+		log.Fatalf("Failed to query journal: %v", err)
+	}
+	if err := write(os.Stdout, entries); err != nil {
+		log.Fatalf("Failed to export journal: %v", err)
+	}
+}
+
+// parseJournalTime parses the time of flag name in local time. Dates ending a
+// range include the whole day.
+func parseJournalTime(name, value string, end bool) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		log.Fatalf("-%s is not a time! Use e.g. 2021-03-01, 2021-03-01T08:00 or RFC 3339: %s", name, value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
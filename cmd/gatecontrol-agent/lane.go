@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/feedback"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatecontrol"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/journal"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/metrics"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/metrics_amqp"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/printer"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/rescan"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/signedtoken"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/trafficlights"
+	"github.com/Contargo/chamqp"
+)
+
+// A lane is a gate with its own agent, re-entry handler and traffic light
+// display, processing the tokens read by the scanners of the gate.
+type lane struct {
+	config          GateConfig
+	gate            *agent.Gate
+	agent           *agent.Agent
+	rescanHandler   *rescan.RescanHandler
+	manualDataChan  chan bool
+	onlineChan      chan bool
+	tokenChan       chan scanner.Token
+	scannerFeedback map[string]scanner.Feedback
+	// journalStop stops recording to the journal, journalStopped is closed
+	// once recording stopped.
+	journalStop    chan struct{}
+	journalStopped chan struct{}
+}
+
+// A laneLock tells the status publisher whether the lane of a gate is locked
+// for maintenance.
+type laneLock struct {
+	gate   string
+	locked bool
+}
+
+// startLane starts the agent of a gate and its subscribers.
+func startLane(gateConfig GateConfig,
+	conn *chamqp.Connection,
+	influxClient metrics.InfluxClient,
+	printTimeout time.Duration,
+	ticketPrinter printer.TicketPrinter,
+	keys []signedtoken.Key,
+	j *journal.Journal,
+	gateStatusChan chan agent.GateStatus,
+	laneLockChan chan laneLock,
+	shutdownChan chan struct{}) *lane {
+
+	l := &lane{
+		config: gateConfig,
+		gate: &agent.Gate{
+			Name:      gateConfig.Name,
+			Purpose:   gateConfig.Purpose,
+			Driver:    newGateDriver(gateConfig),
+			AutoClose: gateConfig.AutoClose,
+		},
+		manualDataChan:  make(chan bool),
+		onlineChan:      make(chan bool),
+		tokenChan:       make(chan scanner.Token),
+		scannerFeedback: map[string]scanner.Feedback{},
+	}
+
+	// Every lane has its own gate-control client, replies are received on
+	// the channel the command was sent on.
+	gc := gatecontrol.NewClient(conn)
+	l.agent = &agent.Agent{
+		ValidateHandler: agent.ValidateHandler(gc),
+		PrintHandler:    agent.PrintHandler(printTimeout),
+		GateHandler:     agent.GateHandler(gc, l.gate),
+		ErrorHandler:    agent.ErrorHandler(),
+		AcceptOffline:   gateConfig.AcceptOffline,
+		Queue:           gateConfig.Queue,
+		Timeouts:        gateConfig.Timeouts,
+	}
+	if ticketPrinter != nil {
+		l.agent.PrintHandler = agent.TicketHandler(ticketPrinter, l.gate, config.Printer(gateConfig.Printer).Title)
+	}
+	if gateConfig.Passage != "" {
+		l.agent.PassHandler = agent.PassHandler(newDetector(config.Input(gateConfig.Passage), gateConfig.PassageTimeout))
+	}
+	if len(keys) > 0 {
+		l.agent.Verifier = signedtoken.NewVerifier(keys...)
+	}
+	l.agent.TestHandler = agent.TestCycle(l.gate, agent.DefaultTestHold)
+
+	metricsChannel := make(chan interface{})
+	go metrics.Listen(influxClient, metricsChannel, shutdownChan)
+	l.agent.Subscribe(metricsChannel)
+
+	rescanChan := make(chan interface{})
+	l.rescanHandler = rescan.NewRescanHandler(rescanChan, shutdownChan, l.manualDataChan, l.gate, gateConfig.ReEntryTimeOut)
+	go l.rescanHandler.Listen()
+	l.agent.Subscribe(rescanChan)
+
+	traffcLightsChan := make(chan interface{})
+	displayGateChan := make(chan agent.GateStatus, 5)
+	l.gate.NotifyStatus(displayGateChan)
+	trafficlightsWebserver := trafficlights.NewWebserver(traffcLightsChan, l.manualDataChan, l.onlineChan, displayGateChan, shutdownChan)
+	go func() {
+		if err := trafficlightsWebserver.Start(gateConfig.Display); err != nil {
+			log.Printf("Cannot start traffic lights of gate %s: %v", gateConfig.Name, err)
+		}
+	}()
+	go trafficlightsWebserver.Listen()
+	l.agent.Subscribe(traffcLightsChan)
+
+	go l.agent.Listen()
+
+	metricsAmqpChannel := make(chan interface{})
+	metricsClient := metrics_amqp.NewMetricsPublisher(conn.Channel(), config.Terminal.Location, gateConfig.Purpose.String(), metricsAmqpChannel, shutdownChan)
+	go metricsClient.Listen()
+	l.agent.Subscribe(metricsAmqpChannel)
+
+	operatorEventChan := make(chan interface{})
+	go operatorEventPublisher(conn.Channel(), gateConfig.Name, operatorEventChan, shutdownChan)
+	l.agent.Subscribe(operatorEventChan)
+
+	lockStatusChan := make(chan interface{})
+	go lockForwarder(gateConfig.Name, lockStatusChan, laneLockChan, shutdownChan)
+	l.agent.Subscribe(lockStatusChan)
+
+	if j != nil {
+		journalChan := make(chan interface{})
+		openingChan := make(chan agent.GateOpening)
+		l.gate.NotifyOpening(openingChan)
+		l.journalStop, l.journalStopped = make(chan struct{}), make(chan struct{})
+		go func() {
+			j.Listen(gateConfig.Name, journalChan, openingChan, l.journalStop)
+			close(l.journalStopped)
+		}()
+		l.agent.Subscribe(journalChan)
+	}
+
+	// Start scanned token dispatcher.
+	go tokenDispatcher(&wg, l, shutdownChan)
+
+	// Report the state of the gate to the status publisher.
+	l.gate.NotifyStatus(gateStatusChan)
+	go l.gate.Monitor(gateConfig.StatusInterval, shutdownChan)
+
+	return l
+}
+
+// addScanner lets the lane process the tokens read by s.
+func (l *lane) addScanner(name string, s *scanner.ReopeningScanner) {
+	s.NotifyTokens(l.tokenChan)
+	l.scannerFeedback[name] = s
+}
+
+// startFeedback starts sending feedback to the scanners of the lane. It must
+// be called after all scanners were added.
+func (l *lane) startFeedback(shutdownChan chan struct{}) {
+	feedbackChan := make(chan interface{})
+	feedbackNotifier := feedback.NewNotifier(l.scannerFeedback, feedbackChan, shutdownChan)
+	go feedbackNotifier.Listen()
+	l.agent.Subscribe(feedbackChan)
+}
+
+// stopJournal stops recording to the journal, if any, and waits for the last
+// entry to be recorded. The agent must be shut down before, so no scan
+// requests get lost.
+func (l *lane) stopJournal() {
+	if l.journalStop == nil {
+		return
+	}
+	close(l.journalStop)
+	<-l.journalStopped
+}
+
+// open opens the gate on request of an operator.
+func (l *lane) open() {
+	log.Printf("Open gate %s manually.", l.config.Name)
+	ctx := gatedriver.NewContext(context.Background(), gatedriver.Request{Trigger: gatedriver.TriggerManual})
+	if err := l.gate.OpenContext(ctx); err != nil {
+		log.Printf("Failed to open gate %s: %v", l.config.Name, err)
+	}
+	l.manualDataChan <- true
+}
+
+func tokenDispatcher(wg *sync.WaitGroup, l *lane, shutdownChan chan struct{}) {
+	wg.Add(1)
+	defer wg.Done()
+
+	for {
+		select {
+		case token := <-l.tokenChan:
+			if !token.IsValid() {
+				log.Println("[", token, "]", "Ignoring scan request, matches no token format, seems to be a ghost scan")
+				continue
+			}
+			if token.Content != "" {
+				// Locked lanes reject all scans, re-entries included. Signed
+				// tokens re-enter by their permission.
+				if l.agent.Locked() || !l.rescanHandler.HandleReentry(l.agent.ReentryToken(token.Content)) {
+					req := agent.NewScanRequest(config.Terminal.Location, config.Terminal.LoadingPlace, l.config.Purpose, token)
+					l.agent.HandleScanRequest(req)
+				}
+			}
+		case <-shutdownChan:
+			return
+		}
+	}
+}
+
+// lockForwarder forwards the lock changes of the agent of gate to the status
+// publisher.
+func lockForwarder(gate string, events chan interface{}, laneLockChan chan laneLock, shutdownChan chan struct{}) {
+	for {
+		select {
+		case msg := <-events:
+			status, ok := msg.(agent.LockStatus)
+			if !ok {
+				continue
+			}
+			select {
+			case laneLockChan <- laneLock{gate: gate, locked: status.Locked}:
+			case <-shutdownChan:
+				return
+			}
+		case <-shutdownChan:
+			return
+		}
+	}
+}
+
+// onlineBroadcaster forwards the online state to the displays of all lanes.
+func onlineBroadcaster(isOnlineChan chan bool, lanes []*lane, shutdownChan chan struct{}) {
+	for {
+		select {
+		case isOnline := <-isOnlineChan:
+			for _, l := range lanes {
+				select {
+				case l.onlineChan <- isOnline:
+				case <-shutdownChan:
+					return
+				}
+			}
+		case <-shutdownChan:
+			return
+		}
+	}
+}
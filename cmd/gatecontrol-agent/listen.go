@@ -8,7 +8,6 @@ import (
 	"os"
 	"sync"
 
-	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
 	"github.com/streadway/amqp"
 )
 
@@ -29,11 +28,14 @@ type OpenGate struct {
 	Gates    []NamedGate `json:"gates"`
 }
 
-func openGateRequestListener(wg *sync.WaitGroup, ch *chamqp.Channel, gate *agent.Gate, manualDataChan chan bool, shutdownChan chan struct{}) {
+// openGateRequestListener opens the gates of open gate requests for the
+// terminal, routed to the lanes by gate name. It also passes operator
+// commands to the lanes.
+func openGateRequestListener(wg *sync.WaitGroup, ch *chamqp.Channel, lanes map[string]*lane, shutdownChan chan struct{}) {
 	wg.Add(1)
 	defer wg.Done()
 
-	queue := fmt.Sprintf("%s.%s.%s.%d", config.Application.Name, config.Terminal.Location, config.Gate.Name, os.Getpid())
+	queue := fmt.Sprintf("%s.%s.%d", config.Application.Name, config.Terminal.Location, os.Getpid())
 
 	gateOpenChan := make(chan amqp.Delivery)
 	errChan := make(chan error)
@@ -41,11 +43,16 @@ func openGateRequestListener(wg *sync.WaitGroup, ch *chamqp.Channel, gate *agent
 	ch.ExchangeDeclare("gatecontrol.event", "topic", true, false, false, false, nil, errChan)
 	ch.QueueDeclare(queue, false, true, false, false, nil, nil, nil)
 	ch.QueueBind(queue, "gates.open", "gatecontrol.event", false, nil, nil)
+	ch.QueueBind(queue, "gates.operator", "gatecontrol.event", false, nil, nil)
 	ch.Consume(queue, "", false, false, false, false, nil, gateOpenChan, nil)
 
 	for {
 		select {
 		case msg := <-gateOpenChan:
+			if msg.RoutingKey == "gates.operator" {
+				handleOperatorCommand(msg, lanes)
+				continue
+			}
 			var req OpenGate
 			err := json.Unmarshal(msg.Body, &req)
 			if err != nil {
@@ -59,10 +66,8 @@ func openGateRequestListener(wg *sync.WaitGroup, ch *chamqp.Channel, gate *agent
 
 			} else {
 				for _, v := range req.Gates {
-					if config.Gate.Name == v.Name {
-						log.Printf("Open gate manually.")
-						gate.Open()
-						manualDataChan <- true
+					if l, ok := lanes[v.Name]; ok {
+						l.open()
 					}
 				}
 				msg.Ack(false)
@@ -10,17 +10,16 @@ import (
 	"sync"
 	"time"
 
-	"contargo.net/gatecontrol/gatecontrol-agent/pkg/metrics_amqp"
-	"contargo.net/gatecontrol/gatecontrol-agent/pkg/rescan"
-	"contargo.net/gatecontrol/gatecontrol-agent/pkg/trafficlights"
 	"github.com/Contargo/chamqp"
 
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/buildinfo"
-	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatecontrol"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/journal"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/metrics"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/printer"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/status"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/usbdev"
 )
 
 var (
@@ -31,6 +30,11 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "journal" {
+		journalCommand(os.Args[2:])
+		return
+	}
+
 	flag.StringVar(&configPath, "config", "./config.ini", "configure location of config file")
 	flag.BoolVar(&logTimestamps, "timestamps", false, "prepend timestamps when logging")
 	flag.Parse()
@@ -51,18 +55,36 @@ func main() {
 		config.Application.Instance)
 	log.Printf("              waiting %v to print", printTimeout)
 	log.Printf("              waiting %v to shutdown", shutdownTimeout)
+	if config.Application.OperatorAPI != "" {
+		log.Printf("              operator API on %s", config.Application.OperatorAPI)
+	}
 	log.Printf("terminal    : %s (%d)",
 		config.Terminal.Location,
 		config.Terminal.LoadingPlace)
-	log.Printf("gate        : %s (%s)",
-		config.Gate.Name,
-		config.Gate.Purpose)
+	for _, gate := range config.Gates {
+		log.Printf("gate        : %s (%s), display on %s",
+			gate.Name,
+			gate.Purpose,
+			gate.Display)
+		log.Printf("              accepting %s", strings.Join(gate.Formats.Names(), ", "))
+		if gate.AutoClose > 0 {
+			log.Printf("              closing automatically after %v", gate.AutoClose)
+		}
+		log.Printf("              scans while busy: %s", gate.Queue.Policy)
+		if gate.Printer != "" {
+			log.Printf("              printing tickets on %s", gate.Printer)
+		}
+		if gate.Passage != "" {
+			log.Printf("              detecting passage on %s within %v", gate.Passage, gate.PassageTimeout)
+		}
+		if len(config.Keys) > 0 {
+			log.Printf("              verifying signed tokens with %d key(s), offline: %v",
+				len(config.Keys), gate.AcceptOffline)
+		}
+	}
 	log.Printf("scanner(s)  : %s", strings.Join(config.ScannerNames(), ", "))
-
-	gate := &agent.Gate{
-		Name:    config.Gate.Name,
-		Purpose: config.Gate.Purpose,
-		Cmd:     config.Gate.Cmd,
+	if config.Journal.Path != "" {
+		log.Printf("journal     : %s, keeping %v", config.Journal.Path, config.Journal.Options.MaxAge)
 	}
 
 	// Global shutdown channel to notify go routines to shutdown.
@@ -77,50 +99,37 @@ func main() {
 	conn := chamqp.Dial(config.RabbitMQ.URL)
 	conn.NotifyError(amqpErrorChan)
 
-	// Start gate-control amqp client.
-	manualDataChan := make(chan bool)
-	gc := gatecontrol.NewClient(conn)
-	go openGateRequestListener(&wg, conn.Channel(), gate, manualDataChan, shutdownChan)
-
-	// Start gate-control agent.
-	a := agent.Agent{
-		ValidateHandler: agent.ValidateHandler(gc),
-		PrintHandler:    agent.PrintHandler(printTimeout),
-		GateHandler:     agent.GateHandler(gc, gate),
-		ErrorHandler:    agent.ErrorHandler(),
-	}
-	metricsChannel := make(chan interface{})
-	a.Subscribe(metricsChannel)
-
+	// Start a gate-control agent per gate.
 	influxClient := metrics.NewInfluxClient(config.Application.InfluxUrl)
-	go metrics.Listen(influxClient, metricsChannel, shutdownChan)
-
-	rescanChan := make(chan interface{})
-	rescanHandler := rescan.NewRescanHandler(rescanChan, shutdownChan, manualDataChan, gate, config.Gate.ReEntryTimeOut)
-	go rescanHandler.Listen()
-	a.Subscribe(rescanChan)
-
-	traffcLightsChan := make(chan interface{})
-
-	trafficlightsWebserver := trafficlights.NewWebserver(traffcLightsChan, manualDataChan, isOnlineChan, shutdownChan)
-	go func() {
-		if err := trafficlightsWebserver.Start("localhost:8080"); err != nil {
-			log.Println("Cannot start traffic lights", err)
+	printers := map[string]printer.TicketPrinter{}
+	for _, printerConfig := range config.Printers {
+		printers[printerConfig.Name] = newPrinter(printerConfig, conn, shutdownChan)
+	}
+	var scanJournal *journal.Journal
+	if config.Journal.Path != "" {
+		var err error
+		if scanJournal, err = journal.Open(config.Journal.Path, config.Journal.Options); err != nil {
+			log.Fatalf("Cannot open journal: %v", err)
 		}
-	}()
-	go trafficlightsWebserver.Listen()
-	a.Subscribe(traffcLightsChan)
-
-	go a.Listen()
-
-	metricsAmqpChannel := make(chan interface{})
-	metricsClient := metrics_amqp.NewMetricsPublisher(conn.Channel(), config.Terminal.Location, config.Gate.Purpose.String(), metricsAmqpChannel, shutdownChan)
-	go metricsClient.Listen()
-	a.Subscribe(metricsAmqpChannel)
-
-	// Start scanned token dispatcher.
-	tokenChan := make(chan scanner.Token)
-	go tokenDispatcher(&wg, &a, rescanHandler, tokenChan, shutdownChan)
+	}
+	gateStatusChan := make(chan agent.GateStatus, 5)
+	laneLockChan := make(chan laneLock, 5)
+	lanes := []*lane{}
+	lanesByGate := map[string]*lane{}
+	for _, gateConfig := range config.Gates {
+		l := startLane(gateConfig, conn, influxClient, printTimeout, printers[gateConfig.Printer], config.Keys, scanJournal, gateStatusChan, laneLockChan, shutdownChan)
+		lanes = append(lanes, l)
+		lanesByGate[gateConfig.Name] = l
+	}
+	go onlineBroadcaster(isOnlineChan, lanes, shutdownChan)
+	go openGateRequestListener(&wg, conn.Channel(), lanesByGate, shutdownChan)
+	if config.Application.OperatorAPI != "" {
+		go func() {
+			if err := serveOperatorAPI(config.Application.OperatorAPI, config.Application.OperatorAPIToken, lanesByGate); err != nil {
+				log.Printf("Cannot serve operator API: %v", err)
+			}
+		}()
+	}
 
 	// Start status publisher.
 	statusPublisher := status.NewPublisher(
@@ -130,31 +139,72 @@ func main() {
 		config.Terminal.LoadingPlace,
 		conn.Channel(),
 	)
-	statusPublisher.UpdateGate(config.Gate.Name, "UP")
+	for _, l := range lanes {
+		statusPublisher.UpdateGate(l.config.Name, string(l.gate.State()))
+	}
 
 	scannerStatusChan := make(chan scanner.Status, 5)
 
-	go statusUpdater(&wg, statusPublisher, isOnlineChan, scannerStatusChan, shutdownChan)
-
 	// Start reopening scanners.
 	scanners := []*scanner.ReopeningScanner{}
+	scannerCounters := []metrics.ScannerCounters{}
 	for _, config := range config.Scanners {
+		lane := lanesByGate[config.Gate]
+
 		var s *scanner.ReopeningScanner
 
 		switch config.Driver {
 		case "keyboard":
-			builder := fileScannerOpener(config.Name, config.Prefix, config.Path)
+			builder := fileScannerOpener(config.Name, config.Prefix, config.Framing, scannerLocator(config, usbdev.HIDRaw))
+			s = scanner.NewReopeningScanner(config.Name, builder)
+		case "evdev":
+			builder := evdevScannerOpener(config.Name, config.Prefix, config.Framing, scannerLocator(config, usbdev.Input), config.Layout)
 			s = scanner.NewReopeningScanner(config.Name, builder)
 		case "usbcom":
-			builder := usbComScannerOpener(config.Name, config.Prefix, config.Path, 115200)
+			builder := scanner.SerialOpener(config.Name, config.Prefix, config.Framing, scannerLocator(config, usbdev.TTY), config.Serial)
+			s = scanner.NewReopeningScanner(config.Name, builder)
+		case "tcp":
+			builder := scanner.TCPOpener(config.Name, config.Prefix, config.Framing, config.Address, config.TCP)
+			s = scanner.NewReopeningScanner(config.Name, builder)
+		case "tcp-listen":
+			builder := scanner.TCPListenOpener(config.Name, config.Prefix, config.Framing, config.Address, config.TCP)
+			s = scanner.NewReopeningScanner(config.Name, builder)
+		case "rfid":
+			builder := scanner.RFIDOpener(config.Name, config.RFID, scannerLocator(config, usbdev.TTY), config.Serial)
 			s = scanner.NewReopeningScanner(config.Name, builder)
+		case "simulated":
+			builder := scanner.SimulatedOpener(config.Name, config.Prefix, config.Address)
+			s = scanner.NewReopeningScanner(config.Name, builder)
+		default:
+			log.Fatalf("Undefined driver %s for scanner %s", config.Driver, config.Name)
+		}
+		if config.Driver == "rfid" {
+			// Badge readers only ever read card UIDs.
+			s.SetFormats(scanner.Formats{scanner.RFIDFormat})
+		} else {
+			s.SetFormats(lane.config.Formats)
 		}
+		s.SetFeedback(config.Feedback)
+		s.SetDebounce(config.Debounce)
+		s.SetBackoff(config.Backoff)
+		s.SetLiveness(config.Liveness)
 		s.NotifyStatus(scannerStatusChan)
-		s.NotifyTokens(tokenChan)
+		lane.addScanner(config.Name, s)
+		statusPublisher.AssignScanner(config.Name, config.Gate)
 		scanners = append(scanners, s)
+		scannerCounters = append(scannerCounters, s)
 		go s.Listen()
 	}
 
+	go metrics.ListenScanners(influxClient, scannerCounters, 60*time.Second, shutdownChan)
+
+	go statusUpdater(&wg, statusPublisher, isOnlineChan, scanners, scannerStatusChan, gateStatusChan, laneLockChan, shutdownChan)
+
+	// Start scanner feedback notifiers.
+	for _, l := range lanes {
+		l.startFeedback(shutdownChan)
+	}
+
 	log.Println("Ready.")
 
 	waitForInterrupt()
@@ -177,10 +227,23 @@ func main() {
 		}
 	}
 
-	// Shutting down gate-control agent.
-	if err := a.Shutdown(ctxWithTimeout); err != nil {
-		log.Printf("Agent failed to shutdown: %v", err)
-		exitCode = 1
+	// Shutting down gate-control agents.
+	for _, l := range lanes {
+		if err := l.agent.Shutdown(ctxWithTimeout); err != nil {
+			log.Printf("Agent of gate %s failed to shutdown: %v", l.config.Name, err)
+			exitCode = 1
+		}
+	}
+
+	// Closing journal once the agents recorded their last requests.
+	for _, l := range lanes {
+		l.stopJournal()
+	}
+	if scanJournal != nil {
+		if err := scanJournal.Close(); err != nil {
+			log.Printf("Journal failed to close: %v", err)
+			exitCode = 1
+		}
 	}
 
 	// Closing amqp connection manager.
@@ -192,7 +255,9 @@ func main() {
 	// Close open channels.
 	close(amqpErrorChan)
 	close(scannerStatusChan)
-	close(tokenChan)
+	for _, l := range lanes {
+		close(l.tokenChan)
+	}
 
 	// Make sure go-routines have stopped.
 	wg.Wait()
@@ -206,17 +271,38 @@ func main() {
 	os.Exit(exitCode)
 }
 
-// Periodically publishs the current state.
+// statusDetails returns the diagnostics of a scanner published in the agent
+// status.
+func statusDetails(s scanner.Status) status.ScannerDetails {
+	return status.ScannerDetails{
+		Since:          s.Since,
+		LastRead:       s.LastRead,
+		ReopenAttempts: s.Attempts,
+		LastError:      s.LastError,
+	}
+}
+
+// statusUpdater publishes the current state periodically and on changes.
 func statusUpdater(wg *sync.WaitGroup,
 	publisher *status.Publisher,
 	isOnlineChannel chan bool,
+	scanners []*scanner.ReopeningScanner,
 	scannerStatusChan chan scanner.Status,
+	gateStatusChan chan agent.GateStatus,
+	laneLockChan chan laneLock,
 	shutdownChan chan struct{}) {
 
 	wg.Add(1)
 	defer wg.Done()
 
 	publish := func() {
+		// Refresh diagnostics, they change without state transitions.
+		for _, s := range scanners {
+			status := s.Status()
+			publisher.UpdateScanner(status.Name, status.State)
+			publisher.UpdateScannerDetails(status.Name, statusDetails(status))
+		}
+
 		err := publisher.Publish()
 		if err != nil {
 			isOnlineChannel <- false
@@ -237,6 +323,14 @@ func statusUpdater(wg *sync.WaitGroup,
 			// Publish status update on state change
 			publisher.UpdateScanner(status.Name, status.State)
 			publish()
+		case status := <-gateStatusChan:
+			// Publish status update on gate state change
+			publisher.UpdateGate(status.Gate, string(status.State))
+			publish()
+		case lock := <-laneLockChan:
+			// Publish status update when a lane is locked or unlocked
+			publisher.UpdateGateMaintenance(lock.gate, lock.locked)
+			publish()
 		case <-c1:
 			publish()
 		case <-c2:
@@ -247,29 +341,6 @@ func statusUpdater(wg *sync.WaitGroup,
 	}
 }
 
-func tokenDispatcher(wg *sync.WaitGroup, a *agent.Agent, rescanHandler *rescan.RescanHandler, tokenChan chan scanner.Token, shutdownChan chan struct{}) {
-	wg.Add(1)
-	defer wg.Done()
-
-	for {
-		select {
-		case token := <-tokenChan:
-			if !token.IsValidUUID() {
-				log.Println("[", token, "]", "Ignoring scan request, seems to be a ghost scan")
-				continue
-			}
-			if token.Content != "" {
-				if !rescanHandler.HandleReentry(token.Content) {
-					req := agent.NewScanRequest(config.Terminal.Location, config.Terminal.LoadingPlace, config.Gate.Purpose, token)
-					a.HandleScanRequest(req)
-				}
-			}
-		case <-shutdownChan:
-			return
-		}
-	}
-}
-
 func errorLogger(wg *sync.WaitGroup, prefix string, errorChan <-chan error, isOnlineChan chan bool) {
 	wg.Add(1)
 	defer wg.Done()
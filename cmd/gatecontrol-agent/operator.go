@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Contargo/chamqp"
+	"github.com/streadway/amqp"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+)
+
+// OperatorCommand is a command of an operator for a gate, received over AMQP
+// or the local operator API.
+type OperatorCommand struct {
+	Terminal Terminal `json:"terminal"`
+	Gate     string   `json:"gate"`
+	Action   string   `json:"action"`
+	Operator string   `json:"operator"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// OperatorEvent is published for every operator command, whether it was
+// applied or not.
+type OperatorEvent struct {
+	Terminal Terminal  `json:"terminal"`
+	Gate     string    `json:"gate"`
+	Action   string    `json:"action"`
+	Operator string    `json:"operator"`
+	Reason   string    `json:"reason,omitempty"`
+	State    string    `json:"state"`
+	Token    string    `json:"token,omitempty"`
+	Applied  bool      `json:"applied"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// operate passes cmd to the agent of the lane.
+func (l *lane) operate(cmd OperatorCommand) error {
+	return l.agent.Operate(agent.OperatorCommand{
+		Action:   agent.OperatorAction(cmd.Action),
+		Operator: cmd.Operator,
+		Reason:   cmd.Reason,
+	})
+}
+
+// handleOperatorCommand passes the operator command in msg to the lane of its
+// gate. Commands for other terminals or gates are ignored.
+func handleOperatorCommand(msg amqp.Delivery, lanes map[string]*lane) {
+	var cmd OperatorCommand
+	if err := json.Unmarshal(msg.Body, &cmd); err != nil {
+		log.Printf("Failed to unmarshal operator command: %v", err)
+		msg.Nack(false, false)
+		return
+	}
+	defer msg.Ack(false)
+
+	if cmd.Terminal.Location != config.Terminal.Location ||
+		cmd.Terminal.LoadingPlace != config.Terminal.LoadingPlace {
+		log.Printf("Skipping operator command for different terminal: %v", cmd.Terminal)
+		return
+	}
+	l, ok := lanes[cmd.Gate]
+	if !ok {
+		return
+	}
+	if err := l.operate(cmd); err != nil {
+		log.Printf("Operator command %s of %s for gate %s failed: %v", cmd.Action, cmd.Operator, cmd.Gate, err)
+	}
+}
+
+// operatorEventPublisher publishes the operator events of the agent of gate.
+func operatorEventPublisher(ch *chamqp.Channel, gate string, events chan interface{}, shutdownChan chan struct{}) {
+	for {
+		select {
+		case msg := <-events:
+			e, ok := msg.(agent.OperatorEvent)
+			if !ok {
+				continue
+			}
+
+			event := OperatorEvent{
+				Terminal: Terminal{Location: config.Terminal.Location, LoadingPlace: config.Terminal.LoadingPlace},
+				Gate:     gate,
+				Action:   string(e.Command.Action),
+				Operator: e.Command.Operator,
+				Reason:   e.Command.Reason,
+				State:    e.State,
+				Token:    e.Token,
+				Applied:  e.Error == nil,
+				Time:     e.Time,
+			}
+			if e.Error != nil {
+				event.Error = e.Error.Error()
+			}
+			body, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to marshal operator event: %v", err)
+				continue
+			}
+			err = ch.Publish("gatecontrol.event", "gatecontrol.agent.operator", false, false, amqp.Publishing{
+				ContentType: "application/json",
+				Body:        body,
+			})
+			if err != nil {
+				log.Printf("Failed to publish operator event: %v", err)
+			}
+		case <-shutdownChan:
+			return
+		}
+	}
+}
+
+// serveOperatorAPI serves the local operator API on address. Commands are
+// posted as JSON to /operator, the terminal may be omitted. If token is set,
+// requests must carry it as bearer token.
+func serveOperatorAPI(address, token string, lanes map[string]*lane) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/operator", func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeOperatorResult(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeOperatorResult(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		var cmd OperatorCommand
+		if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+			writeOperatorResult(w, http.StatusBadRequest, err)
+			return
+		}
+		l, ok := lanes[cmd.Gate]
+		if !ok {
+			writeOperatorResult(w, http.StatusNotFound, errors.New("undefined gate: "+cmd.Gate))
+			return
+		}
+
+		switch err := l.operate(cmd); {
+		case err == nil:
+			writeOperatorResult(w, http.StatusOK, nil)
+		case errors.Is(err, agent.ErrNoOperator):
+			writeOperatorResult(w, http.StatusBadRequest, err)
+		default:
+			writeOperatorResult(w, http.StatusConflict, err)
+		}
+	})
+	return http.ListenAndServe(address, mux)
+}
+
+func writeOperatorResult(w http.ResponseWriter, status int, err error) {
+	result := struct {
+		Applied bool   `json:"applied"`
+		Error   string `json:"error,omitempty"`
+	}{Applied: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
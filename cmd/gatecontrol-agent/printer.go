@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/printer"
+	"github.com/Contargo/chamqp"
+)
+
+// newPrinter creates the ticket printer described by printerConfig. Print
+// services listen for confirmations until shutdownChan is closed.
+func newPrinter(printerConfig PrinterConfig, conn *chamqp.Connection, shutdownChan chan struct{}) printer.TicketPrinter {
+	var dial printer.Dialer
+	switch printerConfig.Driver {
+	case "amqp":
+		queue := fmt.Sprintf("%s.%s.%d.print.%s", config.Application.Name, config.Terminal.Location, os.Getpid(), printerConfig.Name)
+		service := printer.NewService(conn, printerConfig.Name, queue, printerConfig.ConfirmTimeout)
+		go service.Listen(shutdownChan)
+		return service
+	case "tcp":
+		dial = printer.TCPDialer(printerConfig.Address)
+	case "serial":
+		dial = printer.SerialDialer(printerConfig.Path, printerConfig.Serial)
+	default:
+		dial = printer.DeviceDialer(printerConfig.Path)
+	}
+	return printer.New(printerConfig.Name, dial, printerConfig.StatusTimeout)
+}
@@ -3,33 +3,46 @@ package main
 import (
 	"os"
 
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/evdev"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
-	"github.com/jacobsa/go-serial/serial"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/usbdev"
 )
 
-func fileScannerOpener(name, prefix, path string) scanner.Opener {
+func fileScannerOpener(name, prefix string, framing scanner.Framing, locate scanner.Locator) scanner.Opener {
 	return scanner.Opener(func() (*scanner.Scanner, error) {
+		path, err := locate()
+		if err != nil {
+			return nil, err
+		}
 		f, err := os.OpenFile(path, os.O_RDWR, os.ModeNamedPipe)
 		if err != nil {
 			return nil, err
 		}
-		return scanner.Listen(name, prefix, f)
+		return scanner.ListenFramed(name, prefix, framing, f)
 	})
 }
 
-func usbComScannerOpener(name, prefix, device string, baud uint) scanner.Opener {
+func evdevScannerOpener(name, prefix string, framing scanner.Framing, locate scanner.Locator, layout evdev.Layout) scanner.Opener {
 	return scanner.Opener(func() (*scanner.Scanner, error) {
-		port, err := serial.Open(serial.OpenOptions{
-			PortName:        device,
-			BaudRate:        baud,
-			DataBits:        8,
-			StopBits:        1,
-			MinimumReadSize: 4,
-		})
+		path, err := locate()
+		if err != nil {
+			return nil, err
+		}
+		d, err := evdev.Open(path, layout)
 		if err != nil {
 			return nil, err
 		}
+		return scanner.ListenFramed(name, prefix, framing, d)
+	})
+}
 
-		return scanner.Listen(name, prefix, port)
+// scannerLocator locates the device of a scanner by its USB attributes, if
+// configured, or by its path otherwise.
+func scannerLocator(config ScannerConfig, class usbdev.Class) scanner.Locator {
+	if config.USB.IsZero() {
+		return scanner.StaticPath(config.Path)
+	}
+	return scanner.Locator(func() (string, error) {
+		return usbdev.Resolve(config.USB, class)
 	})
 }
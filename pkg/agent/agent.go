@@ -2,24 +2,31 @@ package agent
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatecontrol"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/signedtoken"
 )
 
 const (
 	logStatusInterval time.Duration = 5 * time.Second
 )
 
-// A Handler knows how to validate, print and gate a ScanRequest. Also it knows
-// how to handle errors.
+// A Handler knows how to validate, print and gate a ScanRequest and how to
+// wait for the vehicle to pass the gate. Also it knows how to handle errors.
+// The context of every stage is done when the stage times out or the agent is
+// shut down. Stages must return once their context is done, the agent waits
+// for them, so a stage ignoring its context blocks the lane.
 type Handler interface {
-	Validate(ScanRequest) error
-	Print(ScanRequest) error
-	Gate(ScanRequest) error
-	Error(ScanRequest) error
+	Validate(context.Context, ScanRequest) error
+	Print(context.Context, ScanRequest) error
+	Gate(context.Context, ScanRequest) error
+	Pass(context.Context, ScanRequest) error
+	Error(context.Context, ScanRequest) error
 }
 
 // A ScanRequest represents an scan request received by an agent.
@@ -28,9 +35,19 @@ type ScanRequest struct {
 	loadingPlace int64
 	purpose      GatePurpose
 	token        scanner.Token
+	claims       *signedtoken.Claims
+	offline      bool
+	details      *requestDetails
 	error        error
 }
 
+// requestDetails are shared by all copies of a scan request, so details added
+// in one stage are available in the following ones.
+type requestDetails struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
 func (s *ScanRequest) Source() string {
 	return string(s.token.ScanSource)
 }
@@ -42,6 +59,7 @@ func NewScanRequest(location string, loadingPlace int64, purpose GatePurpose, to
 		loadingPlace: loadingPlace,
 		purpose:      purpose,
 		token:        token,
+		details:      &requestDetails{values: map[string]string{}},
 	}
 }
 
@@ -74,6 +92,48 @@ func (r *ScanRequest) Error() error {
 	return r.error
 }
 
+// Claims returns the verified claims of a signed token.
+func (r *ScanRequest) Claims() (signedtoken.Claims, bool) {
+	if r.claims == nil {
+		return signedtoken.Claims{}, false
+	}
+	return *r.claims, true
+}
+
+// AcceptsOffline reports whether the request may pass although Gate-Control
+// is unavailable, which is the case for verified signed tokens if the agent
+// allows it.
+func (r *ScanRequest) AcceptsOffline(err error) bool {
+	return r.claims != nil && r.offline && gatecontrol.IsUnavailable(err)
+}
+
+// SetDetails adds details about the permission of the request, e.g. to be
+// printed on the interchange ticket.
+func (r *ScanRequest) SetDetails(details map[string]string) {
+	if r.details == nil {
+		r.details = &requestDetails{values: map[string]string{}}
+	}
+	r.details.mu.Lock()
+	defer r.details.mu.Unlock()
+	for k, v := range details {
+		r.details.values[k] = v
+	}
+}
+
+// Details returns the details about the permission of the request.
+func (r *ScanRequest) Details() map[string]string {
+	details := map[string]string{}
+	if r.details == nil {
+		return details
+	}
+	r.details.mu.Lock()
+	defer r.details.mu.Unlock()
+	for k, v := range r.details.values {
+		details[k] = v
+	}
+	return details
+}
+
 // Fail marks the scan request as failed.
 func (r *ScanRequest) Fail(err error) {
 	r.error = err
@@ -85,11 +145,28 @@ type Agent struct {
 	ValidateHandler Callback
 	PrintHandler    Callback
 	GateHandler     Callback
+	PassHandler     Callback
 	ErrorHandler    Callback
 
+	// Verifier verifies signed tokens before they are validated. Signed tokens
+	// are rejected if no verifier is set.
+	Verifier *signedtoken.Verifier
+	// AcceptOffline lets verified signed tokens pass while Gate-Control is
+	// unavailable.
+	AcceptOffline bool
+	// Queue configures how scan requests are handled while the agent is busy.
+	// By default, they are rejected.
+	Queue QueueOptions
+	// Timeouts limit the time spent in every stage. By default, stages never
+	// time out.
+	Timeouts Timeouts
+	// TestHandler runs a test cycle of the lane on request of an operator,
+	// see TestCycle. Test cycles are refused if not set.
+	TestHandler func(context.Context) error
+
 	worker                 *worker
 	scanChan               chan ScanRequest
-	operatorChan           chan string
+	operatorChan           chan operatorRequest
 	shutdownChan, doneChan chan struct{}
 	mu                     sync.Mutex
 }
@@ -102,8 +179,8 @@ func (a *Agent) Listen() {
 		select {
 		case req := <-a.getScanChan():
 			a.HandleScanRequest(req)
-		case <-a.getOperatorChan():
-			log.Println("Operator requests are not supported yet.")
+		case req := <-a.getOperatorChan():
+			req.result <- a.operate(req.cmd)
 		case <-a.getShutdownChan():
 			return
 		}
@@ -113,8 +190,8 @@ func (a *Agent) Listen() {
 // Shutdown gracefully shuts down the server without interrupting any active
 // processes. Shutdown works by first closing all open input channels and then
 // waiting indefinitely to return to idle and then shutdown. If the provided
-// context expires before the shutdown is complete, Shutdown returns the
-// context's error, otherwise nil.
+// context expires before the shutdown is complete, the active process is
+// canceled and Shutdown returns the context's error, otherwise nil.
 func (a *Agent) Shutdown(ctx context.Context) error {
 	err := a.getWorker().Shutdown(ctx)
 
@@ -128,14 +205,52 @@ func (a *Agent) Shutdown(ctx context.Context) error {
 	}
 }
 
-// HandleScanRequest processes a scanned token.
+// HandleScanRequest processes a scanned token. Signed tokens are verified
+// first, the request fails without being validated if the verification fails.
 func (a *Agent) HandleScanRequest(req ScanRequest) {
+	if signedtoken.IsSigned(req.Token()) {
+		a.verify(&req)
+	}
+
 	err := a.getWorker().Scan(req)
 	if err != nil {
 		log.Printf("Failed to handle scan request for token %s: %v", req.Token(), err)
 	}
 }
 
+// ReentryToken returns the token a scan of token is handled as, so re-entries
+// can be matched against it: the permission ID of signed tokens, the token
+// itself otherwise. Signed tokens failing verification are returned as is.
+func (a *Agent) ReentryToken(token string) string {
+	if !signedtoken.IsSigned(token) || a.Verifier == nil {
+		return token
+	}
+	claims, err := a.Verifier.Verify(token)
+	if err != nil {
+		return token
+	}
+	return claims.PermissionID
+}
+
+func (a *Agent) verify(r *ScanRequest) {
+	if a.Verifier == nil {
+		r.Fail(fmt.Errorf("signed tokens are not accepted"))
+		return
+	}
+
+	claims, err := a.Verifier.Verify(r.Token())
+	if err != nil {
+		r.Fail(err)
+		return
+	}
+
+	log.Printf("[%s] Verified signed token for permission %s, valid until %v.",
+		claims.KeyID, claims.PermissionID, claims.Expiry)
+	r.token.Content = claims.PermissionID
+	r.claims = &claims
+	r.offline = a.AcceptOffline
+}
+
 // Subscribe subscribes msgCh to receive published messages in the future.
 func (a *Agent) Subscribe(msgCh chan interface{}) {
 	a.getWorker().Subscribe(msgCh)
@@ -147,58 +262,75 @@ func (a *Agent) Unsubscribe(msgCh chan interface{}) {
 }
 
 // Validate implements the Handler interface.
-func (a *Agent) Validate(r ScanRequest) error {
+func (a *Agent) Validate(ctx context.Context, r ScanRequest) error {
 	if a.ValidateHandler == nil {
 		log.Printf("No handler for \"Validate\". Skipping.")
 		return nil
 	}
-	return a.ValidateHandler.Call(r)
+	return a.ValidateHandler.Call(ctx, r)
 }
 
 // Print implements the Handler interface.
-func (a *Agent) Print(r ScanRequest) error {
+func (a *Agent) Print(ctx context.Context, r ScanRequest) error {
 	if a.PrintHandler == nil {
 		log.Printf("No handler for \"Print\". Skipping.")
 		return nil
 	}
-	return a.PrintHandler.Call(r)
+	return a.PrintHandler.Call(ctx, r)
 }
 
 // Gate implements the Handler interface.
-func (a *Agent) Gate(r ScanRequest) error {
+func (a *Agent) Gate(ctx context.Context, r ScanRequest) error {
 	if a.GateHandler == nil {
 		log.Printf("No handler for \"Gate\". Skipping.")
 		return nil
 	}
-	return a.GateHandler.Call(r)
+	return a.GateHandler.Call(ctx, r)
+}
+
+// Pass implements the Handler interface. Without PassHandler, i.e. without
+// passage detector, the stage is skipped.
+func (a *Agent) Pass(ctx context.Context, r ScanRequest) error {
+	if a.PassHandler == nil {
+		return nil
+	}
+	return a.PassHandler.Call(ctx, r)
 }
 
 // Error implements the Handler interface.
-func (a *Agent) Error(r ScanRequest) error {
+func (a *Agent) Error(ctx context.Context, r ScanRequest) error {
 	if a.ErrorHandler == nil {
 		log.Printf("No handler for \"Error\". Skipping.")
 		return nil
 	}
-	return a.ErrorHandler.Call(r)
+	return a.ErrorHandler.Call(ctx, r)
 }
 
 func (a *Agent) getWorker() *worker {
 	if a.worker == nil {
 		a.worker = newWorker(a)
+		a.worker.timeouts = a.Timeouts
+		if a.Queue.enabled() {
+			a.worker.queue = newQueue(a.Queue)
+		}
 	}
 	return a.worker
 }
 
 func (a *Agent) getScanChan() chan ScanRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	if a.scanChan == nil {
 		a.scanChan = make(chan ScanRequest)
 	}
 	return a.scanChan
 }
 
-func (a *Agent) getOperatorChan() chan string {
+func (a *Agent) getOperatorChan() chan operatorRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	if a.operatorChan == nil {
-		a.operatorChan = make(chan string)
+		a.operatorChan = make(chan operatorRequest)
 	}
 	return a.operatorChan
 }
@@ -1,8 +1,12 @@
 package agent
 
 import (
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatecontrol"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/signedtoken"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -44,6 +48,7 @@ func TestAgent_Listen(t *testing.T) {
 			ValidateHandler: &NopCallback,
 			PrintHandler:    &NopCallback,
 			GateHandler:     &NopCallback,
+			PassHandler:     &NopCallback,
 			ErrorHandler:    &NopCallback,
 		}
 		go agent.Listen()
@@ -54,16 +59,17 @@ func TestAgent_Listen(t *testing.T) {
 		scanRequest := ScanRequest{token: *scanner.NewToken("test-token", "scanner 1")}
 		agent.getScanChan() <- scanRequest
 
-		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateValidating}, <-ch)
+		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateValidating}, untimed(<-ch))
 	})
-	t.Run("ignores operator requests", func(t *testing.T) {
+	t.Run("handles operator commands", func(t *testing.T) {
 		agent := &Agent{}
 		go agent.Listen()
 		defer agent.Shutdown(context.Background())
 
-		agent.getOperatorChan() <- "test-token"
+		err := agent.Operate(OperatorCommand{Action: ActionLock, Operator: "operator"})
 
-		// TODO: how to assert?
+		assert.NoError(t, err)
+		assert.True(t, agent.Locked())
 	})
 }
 
@@ -99,6 +105,7 @@ func TestAgent_Subscribe(t *testing.T) {
 			ValidateHandler: &NopCallback,
 			PrintHandler:    &NopCallback,
 			GateHandler:     &NopCallback,
+			PassHandler:     &NopCallback,
 			ErrorHandler:    &NopCallback,
 		}
 		go agent.Listen()
@@ -109,10 +116,11 @@ func TestAgent_Subscribe(t *testing.T) {
 		scanRequest := ScanRequest{token: *scanner.NewToken("test-token", "scanner 1")}
 		agent.getScanChan() <- scanRequest
 
-		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateValidating}, <-ch)
-		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StatePrinting}, <-ch)
-		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateGating}, <-ch)
-		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateIdle}, <-ch)
+		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateValidating}, untimed(<-ch))
+		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StatePrinting}, untimed(<-ch))
+		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateGating}, untimed(<-ch))
+		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StatePassing}, untimed(<-ch))
+		assert.Equal(t, FsmScanRequest{ScanRequest: scanRequest, State: StateIdle}, untimed(<-ch))
 
 		agent.Unsubscribe(ch)
 		agent.getScanChan() <- ScanRequest{token: *scanner.NewToken("test-token", "scanner 1")}
@@ -125,23 +133,118 @@ func TestAgent_Subscribe(t *testing.T) {
 	})
 }
 
+func signedToken(t *testing.T, expiry time.Time) (*signedtoken.Verifier, string) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	key, err := signedtoken.NewKey("hq", signedtoken.HMACSHA256, base64.StdEncoding.EncodeToString(secret))
+	assert.NoError(t, err)
+	token, err := signedtoken.Sign(signedtoken.Claims{
+		PermissionID: "4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5",
+		Expiry:       expiry,
+		KeyID:        "hq",
+	}, signedtoken.HMACSHA256, secret)
+	assert.NoError(t, err)
+	return signedtoken.NewVerifier(key), token
+}
+
+func TestAgent_HandleScanRequest(t *testing.T) {
+	t.Run("verifies signed tokens", func(t *testing.T) {
+		verifier, token := signedToken(t, time.Now().Add(time.Hour))
+		validated := make(chan ScanRequest, 1)
+		agent := &Agent{
+			ValidateHandler: CallbackFunc(func(ctx context.Context, r ScanRequest) error {
+				validated <- r
+				return nil
+			}),
+			PrintHandler:  &NopCallback,
+			GateHandler:   &NopCallback,
+			ErrorHandler:  &NopCallback,
+			Verifier:      verifier,
+			AcceptOffline: true,
+		}
+		go agent.Listen()
+		defer agent.Shutdown(context.Background())
+
+		agent.HandleScanRequest(NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken(token, "scanner 1")))
+
+		req := <-validated
+		claims, ok := req.Claims()
+		assert.True(t, ok)
+		assert.Equal(t, "hq", claims.KeyID)
+		assert.Equal(t, "4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5", req.Token())
+		assert.True(t, req.AcceptsOffline(gatecontrol.ErrTimedOut))
+		assert.False(t, req.AcceptsOffline(errors.New("not permitted")))
+	})
+	t.Run("rejects expired tokens without validation", func(t *testing.T) {
+		verifier, token := signedToken(t, time.Now().Add(-time.Hour))
+		agent := &Agent{
+			ValidateHandler: CallbackFunc(func(ctx context.Context, r ScanRequest) error {
+				assert.Fail(t, "validated expired token")
+				return nil
+			}),
+			ErrorHandler: &NopCallback,
+			Verifier:     verifier,
+		}
+		go agent.Listen()
+		defer agent.Shutdown(context.Background())
+
+		ch := make(chan interface{}, 10)
+		agent.Subscribe(ch)
+		agent.HandleScanRequest(NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken(token, "scanner 1")))
+
+		assert.Equal(t, StateValidating, (<-ch).(FsmScanRequest).State)
+		msg := (<-ch).(FsmScanRequest)
+		assert.Equal(t, StateError, msg.State)
+		assert.True(t, errors.Is(msg.ScanRequest.Error(), signedtoken.ErrExpired))
+	})
+	t.Run("rejects signed tokens without verifier", func(t *testing.T) {
+		_, token := signedToken(t, time.Now().Add(time.Hour))
+		agent := &Agent{ErrorHandler: &NopCallback}
+		go agent.Listen()
+		defer agent.Shutdown(context.Background())
+
+		ch := make(chan interface{}, 10)
+		agent.Subscribe(ch)
+		agent.HandleScanRequest(NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken(token, "scanner 1")))
+
+		<-ch
+		assert.Equal(t, StateError, (<-ch).(FsmScanRequest).State)
+	})
+}
+
+func TestAgent_ReentryToken(t *testing.T) {
+	t.Run("returns permission of signed tokens", func(t *testing.T) {
+		verifier, token := signedToken(t, time.Now().Add(time.Hour))
+		agent := &Agent{Verifier: verifier}
+
+		assert.Equal(t, "4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5", agent.ReentryToken(token))
+	})
+	t.Run("returns other tokens as scanned", func(t *testing.T) {
+		verifier, expired := signedToken(t, time.Now().Add(-time.Hour))
+		agent := &Agent{Verifier: verifier}
+
+		assert.Equal(t, "4711", agent.ReentryToken("4711"))
+		assert.Equal(t, expired, agent.ReentryToken(expired))
+		assert.Equal(t, expired, (&Agent{}).ReentryToken(expired))
+	})
+}
+
 func TestAgent_Validate(t *testing.T) {
 	t.Run("calls configured validate handler", func(t *testing.T) {
 		called := false
 		agent := &Agent{
-			ValidateHandler: CallbackFunc(func(ScanRequest) error {
+			ValidateHandler: CallbackFunc(func(context.Context, ScanRequest) error {
 				called = true
 				return nil
 			}),
 		}
-		err := agent.Validate(ScanRequest{})
+		err := agent.Validate(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 
 		assert.True(t, called)
 	})
 	t.Run("can handle missing validate handler", func(t *testing.T) {
 		agent := &Agent{}
-		err := agent.Validate(ScanRequest{})
+		err := agent.Validate(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 	})
 }
@@ -150,19 +253,19 @@ func TestAgent_Print(t *testing.T) {
 	t.Run("calls configured print handler", func(t *testing.T) {
 		called := false
 		agent := &Agent{
-			PrintHandler: CallbackFunc(func(ScanRequest) error {
+			PrintHandler: CallbackFunc(func(context.Context, ScanRequest) error {
 				called = true
 				return nil
 			}),
 		}
-		err := agent.Print(ScanRequest{})
+		err := agent.Print(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 
 		assert.True(t, called)
 	})
 	t.Run("can handle missing print handler", func(t *testing.T) {
 		agent := &Agent{}
-		err := agent.Print(ScanRequest{})
+		err := agent.Print(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 	})
 }
@@ -171,19 +274,19 @@ func TestAgent_Gate(t *testing.T) {
 	t.Run("calls configured gate handler", func(t *testing.T) {
 		called := false
 		agent := &Agent{
-			GateHandler: CallbackFunc(func(ScanRequest) error {
+			GateHandler: CallbackFunc(func(context.Context, ScanRequest) error {
 				called = true
 				return nil
 			}),
 		}
-		err := agent.Gate(ScanRequest{})
+		err := agent.Gate(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 
 		assert.True(t, called)
 	})
 	t.Run("can handle missing gate handler", func(t *testing.T) {
 		agent := &Agent{}
-		err := agent.Gate(ScanRequest{})
+		err := agent.Gate(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 	})
 }
@@ -192,19 +295,19 @@ func TestAgent_Error(t *testing.T) {
 	t.Run("calls configured error handler", func(t *testing.T) {
 		called := false
 		agent := &Agent{
-			ErrorHandler: CallbackFunc(func(ScanRequest) error {
+			ErrorHandler: CallbackFunc(func(context.Context, ScanRequest) error {
 				called = true
 				return nil
 			}),
 		}
-		err := agent.Error(ScanRequest{})
+		err := agent.Error(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 
 		assert.True(t, called)
 	})
 	t.Run("can handle missing error handler", func(t *testing.T) {
 		agent := &Agent{}
-		err := agent.Error(ScanRequest{})
+		err := agent.Error(context.Background(), ScanRequest{})
 		assert.NoError(t, err)
 	})
 }
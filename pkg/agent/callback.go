@@ -1,108 +1,163 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatecontrol"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/input"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/printer"
 )
 
-// A Callback responds to an scan request.
+// A Callback responds to an scan request. Callbacks should return when ctx is
+// done, e.g. because the stage timed out or the agent shuts down.
 type Callback interface {
-	Call(ScanRequest) error
+	Call(context.Context, ScanRequest) error
 }
 
 // The CallbackFunc type is an adapter to allow the use of ordinary functions
 // as worker callbacks. If f is a function with the appropriate signature,
 // CallbackFunc(f) is a Callback that calls f.
-type CallbackFunc func(ScanRequest) error
+type CallbackFunc func(context.Context, ScanRequest) error
 
-// Call calls f(r).
-func (f CallbackFunc) Call(r ScanRequest) error {
-	return f(r)
+// Call calls f(ctx, r).
+func (f CallbackFunc) Call(ctx context.Context, r ScanRequest) error {
+	return f(ctx, r)
 }
 
 // NopCallback is a Callback doing nothing.
-var NopCallback = CallbackFunc(func(ScanRequest) error { return nil })
+var NopCallback = CallbackFunc(func(context.Context, ScanRequest) error { return nil })
 
 // ValidateHandler is a callback that validates the token from the scan request.
 func ValidateHandler(validator gatecontrol.PermissionValidator) Callback {
-	return CallbackFunc(func(r ScanRequest) error {
+	return CallbackFunc(func(ctx context.Context, r ScanRequest) error {
 		var (
-			permitted bool
-			err       error
+			permission gatecontrol.Permission
+			err        error
 		)
 
 		log.Printf("[%s] Validating permission for %s.", r.Token(), r.Purpose())
 
 		switch r.Purpose() {
 		case PurposeEntry:
-			permitted, err = validator.ValidateEntry(r.Location(), r.LoadingPlace(), r.Token(), r.Source())
+			permission, err = validator.ValidateEntry(ctx, r.Location(), r.LoadingPlace(), r.Token(), r.Source())
 		case PurposeExit:
-			permitted, err = validator.ValidateExit(r.Location(), r.LoadingPlace(), r.Token(), r.Source())
+			permission, err = validator.ValidateExit(ctx, r.Location(), r.LoadingPlace(), r.Token(), r.Source())
 		default:
 			return fmt.Errorf("unknown purpose: %s", r.Purpose())
 		}
 
+		if r.AcceptsOffline(err) {
+			log.Printf("[%s] Accepting signed token offline: %v", r.Token(), err)
+			return nil
+		}
+
 		if err != nil {
 			return err
 		}
 
-		if !permitted {
+		if !permission.Permitted {
 			return fmt.Errorf("not permitted")
 		}
 
+		r.SetDetails(permission.Details)
 		return nil
 	})
 }
 
 // PrintHandler is a callback that does the printing for the request.
 func PrintHandler(waitTime time.Duration) Callback {
-	return CallbackFunc(func(r ScanRequest) error {
+	return CallbackFunc(func(ctx context.Context, r ScanRequest) error {
 		log.Printf("[%s] Waiting %v for print job to be done.", r.Token(), waitTime)
-		time.Sleep(waitTime)
-		return nil
+		select {
+		case <-time.After(waitTime):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// TicketHandler is a callback that prints the interchange ticket for the
+// request on p, including the details provided by Gate-Control.
+func TicketHandler(p printer.TicketPrinter, gate *Gate, title string) Callback {
+	return CallbackFunc(func(ctx context.Context, r ScanRequest) error {
+		log.Printf("[%s] Printing interchange ticket.", r.Token())
+		return p.Print(ctx, printer.Ticket{
+			Title:        title,
+			Token:        r.Token(),
+			Location:     r.Location(),
+			LoadingPlace: r.LoadingPlace(),
+			Gate:         gate.Name,
+			Purpose:      r.Purpose().String(),
+			Time:         time.Now(),
+			Details:      r.Details(),
+		})
 	})
 }
 
 // GateHandler is a callback that tries to perform the actual gate process.
 //
 // The process consists of the following steps:
-// * Check if the request is valid
+// * Notify Gate-Control about the gate in or out
 // * Open gate
-// * Check the induction loop
-// * Close gate
 //
-// Dependent of the state of the induction loop, Gate terminates successfully
-// or with a timeout because the actual "gate" couldn't be detected.
+// Whether the vehicle actually passes the gate is checked afterwards, see
+// PassHandler.
 func GateHandler(notifier gatecontrol.ProcessNotifier, gate *Gate) Callback {
-	return CallbackFunc(func(r ScanRequest) error {
+	return CallbackFunc(func(ctx context.Context, r ScanRequest) error {
 		var err error
 
 		log.Printf("[%s] Notifying consumers about the %s.", r.Token(), r.Purpose())
 
 		switch r.Purpose() {
 		case PurposeEntry:
-			err = notifier.GatedIn(r.Location(), r.LoadingPlace(), r.Token(), r.Source())
+			err = notifier.GatedIn(ctx, r.Location(), r.LoadingPlace(), r.Token(), r.Source())
 		case PurposeExit:
-			err = notifier.GatedOut(r.Location(), r.LoadingPlace(), r.Token(), r.Source())
+			err = notifier.GatedOut(ctx, r.Location(), r.LoadingPlace(), r.Token(), r.Source())
 		default:
 			return fmt.Errorf("unknown purpose: %s", r.Purpose())
 		}
 
+		if r.AcceptsOffline(err) {
+			log.Printf("[%s] Gating signed token offline: %v", r.Token(), err)
+			err = nil
+		}
+
 		if err != nil {
 			return err
 		}
 
 		log.Printf("[%s] Open gate on Scan.", r.Token())
-		return gate.Open()
+		return gate.OpenContext(gatedriver.NewContext(ctx, gatedriver.Request{
+			Token:      r.Token(),
+			ScanSource: r.Source(),
+			Trigger:    gatedriver.TriggerScan,
+		}))
+	})
+}
+
+// PassHandler is a callback that waits for the vehicle to pass the opened gate,
+// detected by d. It fails with input.ErrNoPassage if no vehicle passed in
+// time.
+func PassHandler(d *input.Detector) Callback {
+	return CallbackFunc(func(ctx context.Context, r ScanRequest) error {
+		log.Printf("[%s] Waiting for vehicle to pass %s.", r.Token(), d.Name())
+		err := d.WaitPassage(ctx)
+		if errors.Is(err, input.ErrNoPassage) {
+			log.Printf("[%s] Gate opened, but no vehicle passed %s.", r.Token(), d.Name())
+		}
+		return err
 	})
 }
 
 // ErrorHandler is a callback that handles errors during the process.
 func ErrorHandler() Callback {
-	return CallbackFunc(func(r ScanRequest) error {
+	return CallbackFunc(func(ctx context.Context, r ScanRequest) error {
 		log.Printf("[%s] Error: %s", r.Token(), r.Error())
 		return nil
 	})
@@ -1,8 +1,14 @@
 package agent
 
 import (
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatecontrol"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/input"
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/signedtoken"
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,13 +18,104 @@ func TestCallbackFunc(t *testing.T) {
 		called := false
 		request := ScanRequest{token: *scanner.NewToken("test-token", "scanner 1")}
 
-		fn := func(r ScanRequest) error {
+		fn := func(ctx context.Context, r ScanRequest) error {
 			called = true
 			assert.Equal(t, request, r)
 			return nil
 		}
 
-		CallbackFunc(fn).Call(request)
+		CallbackFunc(fn).Call(context.Background(), request)
 		assert.True(t, called)
 	})
 }
+
+type unavailableValidator struct{}
+
+func (unavailableValidator) ValidateEntry(context.Context, string, int64, string, string) (gatecontrol.Permission, error) {
+	return gatecontrol.Permission{}, gatecontrol.ErrTimedOut
+}
+
+func (unavailableValidator) ValidateExit(context.Context, string, int64, string, string) (gatecontrol.Permission, error) {
+	return gatecontrol.Permission{}, gatecontrol.ErrTimedOut
+}
+
+type permittingValidator map[string]string
+
+func (v permittingValidator) ValidateEntry(context.Context, string, int64, string, string) (gatecontrol.Permission, error) {
+	return gatecontrol.Permission{Permitted: true, Details: v}, nil
+}
+
+func (v permittingValidator) ValidateExit(context.Context, string, int64, string, string) (gatecontrol.Permission, error) {
+	return gatecontrol.Permission{Permitted: true, Details: v}, nil
+}
+
+// waitingValidator waits for Gate-Control until ctx is done.
+type waitingValidator struct{}
+
+func (waitingValidator) ValidateEntry(ctx context.Context, _ string, _ int64, _ string, _ string) (gatecontrol.Permission, error) {
+	<-ctx.Done()
+	return gatecontrol.Permission{}, ctx.Err()
+}
+
+func (waitingValidator) ValidateExit(ctx context.Context, _ string, _ int64, _ string, _ string) (gatecontrol.Permission, error) {
+	<-ctx.Done()
+	return gatecontrol.Permission{}, ctx.Err()
+}
+
+func TestValidateHandler(t *testing.T) {
+	t.Run("passes details to later stages", func(t *testing.T) {
+		request := NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken("test-token", "scanner 1"))
+		validator := permittingValidator{"Container": "ABCU1234567"}
+
+		assert.NoError(t, ValidateHandler(validator).Call(context.Background(), request))
+
+		assert.Equal(t, map[string]string{"Container": "ABCU1234567"}, request.Details())
+	})
+	t.Run("accepts verified signed tokens offline", func(t *testing.T) {
+		request := NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken("test-token", "scanner 1"))
+		request.claims = &signedtoken.Claims{PermissionID: "test-token"}
+		request.offline = true
+
+		assert.NoError(t, ValidateHandler(unavailableValidator{}).Call(context.Background(), request))
+	})
+	t.Run("rejects unsigned tokens offline", func(t *testing.T) {
+		request := NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken("test-token", "scanner 1"))
+
+		err := ValidateHandler(unavailableValidator{}).Call(context.Background(), request)
+
+		assert.True(t, errors.Is(err, gatecontrol.ErrTimedOut))
+	})
+	t.Run("rejects signed tokens offline if not allowed", func(t *testing.T) {
+		request := NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken("test-token", "scanner 1"))
+		request.claims = &signedtoken.Claims{PermissionID: "test-token"}
+
+		assert.Error(t, ValidateHandler(unavailableValidator{}).Call(context.Background(), request))
+	})
+	t.Run("stops validating when context is done", func(t *testing.T) {
+		request := NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken("test-token", "scanner 1"))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := ValidateHandler(waitingValidator{}).Call(ctx, request)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
+
+type emptyLoop struct{}
+
+func (emptyLoop) Active() (bool, error) { return false, nil }
+func (emptyLoop) Close() error          { return nil }
+
+func TestPassHandler(t *testing.T) {
+	t.Run("reports missing passage", func(t *testing.T) {
+		request := NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken("test-token", "scanner 1"))
+		detector := input.NewDetector("loop", input.Opener(func() (input.Input, error) {
+			return emptyLoop{}, nil
+		}), time.Millisecond, 10*time.Millisecond)
+
+		err := PassHandler(detector).Call(context.Background(), request)
+
+		assert.Equal(t, input.ErrNoPassage, err)
+	})
+}
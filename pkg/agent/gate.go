@@ -1,9 +1,15 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
 )
 
 // A GatePurpose represents the purpose of a gate.
@@ -36,20 +42,285 @@ func NewGatePurpose(name string) (GatePurpose, error) {
 	return PurposeEntry, fmt.Errorf("Undefined gate purpose: %s", name)
 }
 
-// A Gate represents a physical gate.
+// A GateState is the state of a physical gate.
+type GateState string
+
+const (
+	// GateUnknown represents a gate whose state is not known yet.
+	GateUnknown GateState = "UNKNOWN"
+	// GateOpen represents an open gate.
+	GateOpen GateState = "OPEN"
+	// GateClosed represents a closed gate.
+	GateClosed GateState = "CLOSED"
+	// GateMoving represents a gate that is opening or closing.
+	GateMoving GateState = "MOVING"
+	// GateFault represents a gate that reports a fault or failed to move.
+	GateFault GateState = "FAULT"
+)
+
+// ParseGateState returns the state named by s, e.g. the output of a status
+// command. Case and surrounding white space are ignored.
+func ParseGateState(s string) (GateState, error) {
+	state := GateState(strings.ToUpper(strings.TrimSpace(s)))
+	switch state {
+	case GateOpen, GateClosed, GateMoving, GateFault:
+		return state, nil
+	}
+	return GateUnknown, fmt.Errorf("undefined gate state: %q", s)
+}
+
+// ErrCannotClose is returned when closing a gate whose driver cannot close
+// it.
+var ErrCannotClose = errors.New("gate has no close command")
+
+// A GateStatus is the state of a gate, published whenever it changes.
+type GateStatus struct {
+	Gate  string
+	State GateState
+	// Error is the reason of a fault.
+	Error error
+}
+
+// A GateOpening is sent for every attempt to open a gate.
+type GateOpening struct {
+	// Request tells what the gate was opened for, e.g. a re-entry.
+	Request gatedriver.Request
+	Time    time.Time
+	// Error tells why the gate failed to open.
+	Error error
+}
+
+// A Gate represents a physical gate, actuated by its driver. Without driver,
+// the gate is actuated by shell commands. If the driver cannot read the state
+// of the gate, it is assumed from the actions performed. Gates closing by
+// themselves are not assumed open, since it is not known when they closed.
 type Gate struct {
 	Name    string
 	Purpose GatePurpose
-	Cmd     string
+	// Driver actuates the gate. If nil, the commands are run instead.
+	Driver gatedriver.Driver
+	Cmd    string
+	// CloseCmd closes the gate. Gates without close command close by
+	// themselves.
+	CloseCmd string
+	// StatusCmd prints the state of the gate, i.e. open, closed, moving or
+	// fault.
+	StatusCmd string
+	// AutoClose closes the gate this long after it was opened, if set.
+	AutoClose time.Duration
+
+	mu          sync.Mutex
+	status      *GateStatus
+	statusChans []chan GateStatus
+	openChans   []chan GateOpening
+	autoClose   *time.Timer
 }
 
 // Open opens the gate.
 func (g *Gate) Open() error {
-	log.Printf("Open gate: %v", g)
-	return runCmd(g.Cmd)
+	return g.OpenContext(context.Background())
+}
+
+// OpenContext opens the gate. The driver is stopped if ctx is done before it
+// completes. The request the gate is opened for is taken from ctx, see
+// gatedriver.NewContext.
+func (g *Gate) OpenContext(ctx context.Context) error {
+	log.Printf("Open gate: %s", g.Name)
+	ctx = g.withRequest(ctx)
+	if err := g.driver().Open(ctx); err != nil {
+		g.notifyOpening(ctx, err)
+		g.setStatus(GateFault, err)
+		return err
+	}
+	g.notifyOpening(ctx, nil)
+	if g.CanClose() {
+		g.assumeStatus(GateOpen)
+	}
+	g.scheduleAutoClose()
+	return nil
+}
+
+// Close closes the gate.
+func (g *Gate) Close() error {
+	return g.CloseContext(context.Background())
+}
+
+// CloseContext closes the gate. The driver is stopped if ctx is done before
+// it completes.
+func (g *Gate) CloseContext(ctx context.Context) error {
+	g.stopAutoClose()
+	driver := g.driver()
+	if !driver.Supports(gatedriver.ActionClose) {
+		return ErrCannotClose
+	}
+
+	log.Printf("Close gate: %s", g.Name)
+	if err := driver.Close(g.withRequest(ctx)); err != nil {
+		g.setStatus(GateFault, err)
+		return err
+	}
+	g.assumeStatus(GateClosed)
+	return nil
+}
+
+// CanClose reports whether the gate can be closed by the agent.
+func (g *Gate) CanClose() bool {
+	return g.driver().Supports(gatedriver.ActionClose)
+}
+
+// Status queries the state of the gate from its driver. If the driver cannot
+// read the state, the last known state is returned.
+func (g *Gate) Status(ctx context.Context) (GateState, error) {
+	driver := g.driver()
+	if !driver.Supports(gatedriver.ActionStatus) {
+		return g.State(), nil
+	}
+
+	out, err := driver.Status(ctx)
+	if err != nil {
+		g.setStatus(GateFault, err)
+		return GateFault, err
+	}
+	state, err := ParseGateState(out)
+	if err != nil {
+		g.setStatus(GateFault, fmt.Errorf("status: %w", err))
+		return GateFault, err
+	}
+	g.setStatus(state, nil)
+	return state, nil
+}
+
+// State returns the last known state of the gate.
+func (g *Gate) State() GateState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.status == nil {
+		return GateUnknown
+	}
+	return g.status.State
+}
+
+// NotifyStatus registers ch to receive the status of the gate whenever it
+// changes. Statuses are dropped if ch is not ready.
+func (g *Gate) NotifyStatus(ch chan GateStatus) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.statusChans = append(g.statusChans, ch)
+}
+
+// NotifyOpening registers ch to receive every attempt to open the gate.
+// Openings are never dropped, opening the gate blocks until ch received it.
+func (g *Gate) NotifyOpening(ch chan GateOpening) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.openChans = append(g.openChans, ch)
 }
 
-func runCmd(args string) error {
-	cmd := exec.Command("sh", "-c", args)
-	return cmd.Run()
+// Monitor queries the state of the gate every interval until shutdownChan is
+// closed. It returns immediately if the driver cannot read the state.
+func (g *Gate) Monitor(interval time.Duration, shutdownChan chan struct{}) {
+	if !g.driver().Supports(gatedriver.ActionStatus) {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if _, err := g.Status(ctx); err != nil {
+			log.Printf("Failed to query state of gate %s: %v", g.Name, err)
+		}
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-shutdownChan:
+			return
+		}
+	}
+}
+
+// assumeStatus sets the state of gates whose driver cannot tell by itself.
+func (g *Gate) assumeStatus(state GateState) {
+	if !g.driver().Supports(gatedriver.ActionStatus) {
+		g.setStatus(state, nil)
+	}
+}
+
+func (g *Gate) setStatus(state GateState, err error) {
+	g.mu.Lock()
+	changed := g.status == nil || g.status.State != state || (err != nil) != (g.status.Error != nil)
+	status := GateStatus{Gate: g.Name, State: state, Error: err}
+	g.status = &status
+	chans := g.statusChans
+	g.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err != nil {
+		log.Printf("Gate %s is %s: %v", g.Name, state, err)
+	} else {
+		log.Printf("Gate %s is %s", g.Name, state)
+	}
+	for _, ch := range chans {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
+
+func (g *Gate) notifyOpening(ctx context.Context, err error) {
+	req, _ := gatedriver.FromContext(ctx)
+	opening := GateOpening{Request: req, Time: time.Now(), Error: err}
+
+	g.mu.Lock()
+	chans := g.openChans
+	g.mu.Unlock()
+	for _, ch := range chans {
+		ch <- opening
+	}
+}
+
+func (g *Gate) scheduleAutoClose() {
+	if g.AutoClose <= 0 || !g.driver().Supports(gatedriver.ActionClose) {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.autoClose != nil {
+		g.autoClose.Stop()
+	}
+	g.autoClose = time.AfterFunc(g.AutoClose, func() {
+		log.Printf("Closing gate %s automatically after %v.", g.Name, g.AutoClose)
+		if err := g.Close(); err != nil {
+			log.Printf("Failed to close gate %s: %v", g.Name, err)
+		}
+	})
+}
+
+func (g *Gate) stopAutoClose() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.autoClose != nil {
+		g.autoClose.Stop()
+		g.autoClose = nil
+	}
+}
+
+// withRequest adds the gate to the request carried by ctx.
+func (g *Gate) withRequest(ctx context.Context) context.Context {
+	req, _ := gatedriver.FromContext(ctx)
+	req.Gate = g.Name
+	req.Purpose = g.Purpose.String()
+	return gatedriver.NewContext(ctx, req)
+}
+
+func (g *Gate) driver() gatedriver.Driver {
+	if g.Driver != nil {
+		return g.Driver
+	}
+	return &gatedriver.Shell{OpenCmd: g.Cmd, CloseCmd: g.CloseCmd, StatusCmd: g.StatusCmd}
 }
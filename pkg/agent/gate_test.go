@@ -1,11 +1,35 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
 	"github.com/stretchr/testify/assert"
 )
 
+// DummyDriver reports state and fails actions with err. It records the
+// request the gate was opened for.
+type DummyDriver struct {
+	state string
+	err   error
+	req   gatedriver.Request
+}
+
+func (d *DummyDriver) Open(ctx context.Context) error {
+	d.req, _ = gatedriver.FromContext(ctx)
+	return d.err
+}
+func (d *DummyDriver) Close(ctx context.Context) error { return d.err }
+func (d *DummyDriver) Status(ctx context.Context) (string, error) {
+	return d.state, d.err
+}
+func (d *DummyDriver) Supports(action gatedriver.Action) bool {
+	return action != gatedriver.ActionStatus || d.state != ""
+}
+
 func TestNewGatePurpose(t *testing.T) {
 	t.Run("returns purpose for named purpose", func(t *testing.T) {
 		purpose, err := NewGatePurpose("entry")
@@ -29,4 +53,148 @@ func TestGate_Open(t *testing.T) {
 		err := gate.Open()
 		assert.Error(t, err)
 	})
+	t.Run("prefers driver over command", func(t *testing.T) {
+		gate := Gate{Cmd: "/bin/false", Driver: &DummyDriver{}}
+		assert.NoError(t, gate.Open())
+		assert.Equal(t, GateOpen, gate.State())
+	})
+	t.Run("passes request to driver", func(t *testing.T) {
+		driver := &DummyDriver{}
+		gate := Gate{Name: "gate", Purpose: PurposeExit, Driver: driver}
+		ctx := gatedriver.NewContext(context.Background(), gatedriver.Request{Token: "token", Trigger: gatedriver.TriggerScan})
+
+		assert.NoError(t, gate.OpenContext(ctx))
+
+		assert.Equal(t, gatedriver.Request{
+			Gate:    "gate",
+			Purpose: "exit",
+			Token:   "token",
+			Trigger: gatedriver.TriggerScan,
+		}, driver.req)
+	})
+	t.Run("reports driver errors as fault", func(t *testing.T) {
+		statuses := make(chan GateStatus, 10)
+		driverErr := &gatedriver.DriverError{Driver: "modbus", Action: gatedriver.ActionOpen, Err: errors.New("timeout")}
+		gate := Gate{Name: "gate", Driver: &DummyDriver{err: driverErr}}
+		gate.NotifyStatus(statuses)
+
+		assert.Equal(t, driverErr, gate.Open())
+		assert.Equal(t, GateStatus{Gate: "gate", State: GateFault, Error: driverErr}, <-statuses)
+	})
+	t.Run("does not assume gates closing by themselves to be open", func(t *testing.T) {
+		gate := Gate{Cmd: "/bin/true"}
+		assert.NoError(t, gate.Open())
+		assert.Equal(t, GateUnknown, gate.State())
+	})
+	t.Run("notifies openings", func(t *testing.T) {
+		openings := make(chan GateOpening, 10)
+		gate := Gate{Name: "gate", Driver: &DummyDriver{}}
+		gate.NotifyOpening(openings)
+		ctx := gatedriver.NewContext(context.Background(), gatedriver.Request{Trigger: gatedriver.TriggerManual})
+
+		assert.NoError(t, gate.OpenContext(ctx))
+
+		opening := <-openings
+		assert.Equal(t, gatedriver.Request{Gate: "gate", Purpose: "entry", Trigger: gatedriver.TriggerManual}, opening.Request)
+		assert.NoError(t, opening.Error)
+	})
+	t.Run("waits for openings to be received", func(t *testing.T) {
+		openings := make(chan GateOpening)
+		gate := Gate{Name: "gate", Driver: &DummyDriver{}}
+		gate.NotifyOpening(openings)
+
+		go func() {
+			for i := 0; i < 3; i++ {
+				gate.Open()
+			}
+		}()
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, (<-openings).Error)
+		}
+	})
+}
+
+func TestGate_Close(t *testing.T) {
+	t.Run("close runs configured command", func(t *testing.T) {
+		gate := Gate{Cmd: "/bin/true", CloseCmd: "/bin/true"}
+		assert.NoError(t, gate.Open())
+		assert.Equal(t, GateOpen, gate.State())
+
+		assert.NoError(t, gate.Close())
+		assert.Equal(t, GateClosed, gate.State())
+	})
+	t.Run("returns error without close command", func(t *testing.T) {
+		gate := Gate{Cmd: "/bin/true"}
+		assert.Equal(t, ErrCannotClose, gate.Close())
+	})
+	t.Run("closes automatically", func(t *testing.T) {
+		statuses := make(chan GateStatus, 10)
+		gate := Gate{Name: "gate", Cmd: "/bin/true", CloseCmd: "/bin/true", AutoClose: 10 * time.Millisecond}
+		gate.NotifyStatus(statuses)
+
+		assert.NoError(t, gate.Open())
+
+		assert.Equal(t, GateStatus{Gate: "gate", State: GateOpen}, <-statuses)
+		assert.Equal(t, GateStatus{Gate: "gate", State: GateClosed}, <-statuses)
+	})
+}
+
+func TestGate_Status(t *testing.T) {
+	t.Run("queries status command", func(t *testing.T) {
+		gate := Gate{Cmd: "/bin/true", StatusCmd: "echo moving"}
+
+		state, err := gate.Status(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, GateMoving, state)
+		assert.Equal(t, GateMoving, gate.State())
+	})
+	t.Run("prefers status command over assumed state", func(t *testing.T) {
+		gate := Gate{Cmd: "/bin/true", StatusCmd: "echo closed"}
+
+		assert.NoError(t, gate.Open())
+
+		assert.Equal(t, GateUnknown, gate.State())
+	})
+	t.Run("reports faults", func(t *testing.T) {
+		statuses := make(chan GateStatus, 10)
+		gate := Gate{Name: "gate", Cmd: "/bin/false", StatusCmd: "echo jammed"}
+		gate.NotifyStatus(statuses)
+
+		state, err := gate.Status(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, GateFault, state)
+		assert.Equal(t, GateFault, (<-statuses).State)
+
+		assert.Error(t, gate.Open())
+		assert.Empty(t, statuses)
+	})
+	t.Run("queries driver", func(t *testing.T) {
+		gate := Gate{Driver: &DummyDriver{state: "closed"}}
+
+		state, err := gate.Status(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, GateClosed, state)
+	})
+	t.Run("returns last known state without status command", func(t *testing.T) {
+		gate := Gate{Cmd: "/bin/true"}
+
+		state, err := gate.Status(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, GateUnknown, state)
+	})
+}
+
+func TestParseGateState(t *testing.T) {
+	t.Run("parses states", func(t *testing.T) {
+		state, err := ParseGateState(" Open\n")
+		assert.NoError(t, err)
+		assert.Equal(t, GateOpen, state)
+	})
+	t.Run("rejects unknown states", func(t *testing.T) {
+		_, err := ParseGateState("ajar")
+		assert.Error(t, err)
+	})
 }
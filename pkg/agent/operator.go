@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+)
+
+// An OperatorAction is an action an operator asks the agent to take.
+type OperatorAction string
+
+const (
+	// ActionApprove permits the request being validated without waiting for
+	// Gate-Control, e.g. after checking the papers of the driver.
+	ActionApprove OperatorAction = "approve"
+	// ActionReject fails the request being handled.
+	ActionReject OperatorAction = "reject"
+	// ActionReset ends the error handling of a failed request, so the agent
+	// returns to idle.
+	ActionReset OperatorAction = "reset"
+	// ActionLock rejects all scan requests until the lane is unlocked.
+	ActionLock OperatorAction = "lock"
+	// ActionUnlock accepts scan requests again.
+	ActionUnlock OperatorAction = "unlock"
+	// ActionTest runs a test cycle of the lane while it is idle, see
+	// Agent.TestHandler.
+	ActionTest OperatorAction = "test"
+)
+
+// NewOperatorAction returns the action of the given name.
+func NewOperatorAction(action string) (OperatorAction, error) {
+	switch OperatorAction(action) {
+	case ActionApprove, ActionReject, ActionReset, ActionLock, ActionUnlock, ActionTest:
+		return OperatorAction(action), nil
+	}
+	return "", fmt.Errorf("undefined operator action: %s", action)
+}
+
+var (
+	// ErrNoOperator is returned for commands that do not name an operator.
+	ErrNoOperator = errors.New("operator command requires an operator")
+	// ErrRejected is the error of requests rejected by an operator.
+	ErrRejected = errors.New("rejected by operator")
+	// ErrInterrupted is returned when the stage of the current request was
+	// already ended by another command.
+	ErrInterrupted = errors.New("stage was already ended by an operator")
+	// ErrNoTestCycle is returned for test cycles of agents without test
+	// handler.
+	ErrNoTestCycle = errors.New("no test cycle configured")
+)
+
+// A NotApplicableError is returned for commands that do not apply to the
+// state of the agent, e.g. approving a request while idle.
+type NotApplicableError struct {
+	State string
+}
+
+func (e *NotApplicableError) Error() string {
+	return fmt.Sprintf("command does not apply in state %s", e.State)
+}
+
+// DefaultTestHold is the time a gate is held open during a test cycle before
+// it is closed again.
+const DefaultTestHold = 10 * time.Second
+
+// An OperatorCommand asks the agent to take action on behalf of an operator.
+type OperatorCommand struct {
+	Action OperatorAction
+	// Operator identifies the operator, e.g. by user name.
+	Operator string
+	// Reason is an optional note of the operator, e.g. why a request was
+	// rejected.
+	Reason string
+}
+
+// An OperatorEvent is published to subscribers for every operator command,
+// whether it was applied or not.
+type OperatorEvent struct {
+	Command OperatorCommand
+	Time    time.Time
+	// State is the state of the agent when the command was received.
+	State string
+	// Token is the token of the request the command applied to, if any.
+	Token string
+	// Error tells why the command was not applied.
+	Error error
+}
+
+type operatorRequest struct {
+	cmd    OperatorCommand
+	result chan error
+}
+
+// Operate passes cmd to the listening agent and returns once it was handled.
+// An error is returned if the command was not applied.
+func (a *Agent) Operate(cmd OperatorCommand) error {
+	if cmd.Operator == "" {
+		return ErrNoOperator
+	}
+
+	req := operatorRequest{cmd: cmd, result: make(chan error, 1)}
+	select {
+	case a.getOperatorChan() <- req:
+	case <-a.getShutdownChan():
+		return ErrShutdown
+	}
+	return <-req.result
+}
+
+// Locked reports whether the lane of the agent is locked.
+func (a *Agent) Locked() bool {
+	return a.getWorker().Locked()
+}
+
+// operate applies cmd, logs it for auditing and publishes it.
+func (a *Agent) operate(cmd OperatorCommand) error {
+	w := a.getWorker()
+	state, req := w.Current()
+	token := ""
+	if state != StateIdle {
+		token = req.Token()
+	}
+
+	var err error
+	switch cmd.Action {
+	case ActionApprove:
+		err = w.Interrupt(nil, StateValidating)
+	case ActionReject:
+		err = w.Interrupt(fmt.Errorf("%w %s", ErrRejected, cmd.Operator),
+			StateValidating, StatePrinting, StateGating, StatePassing)
+	case ActionReset:
+		err = w.Interrupt(nil, StateError)
+	case ActionLock:
+		w.Lock()
+	case ActionUnlock:
+		w.Unlock()
+	case ActionTest:
+		err = ErrNoTestCycle
+		if a.TestHandler != nil {
+			err = w.Test(a.TestHandler)
+		}
+	default:
+		_, err = NewOperatorAction(string(cmd.Action))
+	}
+
+	audit := fmt.Sprintf("[%s] Operator %s requested %s in state %s", token, cmd.Operator, cmd.Action, state)
+	if cmd.Reason != "" {
+		audit += fmt.Sprintf(" (%s)", cmd.Reason)
+	}
+	if err != nil {
+		log.Printf("%s, not applied: %v", audit, err)
+	} else {
+		log.Printf("%s, applied.", audit)
+	}
+	w.publish(OperatorEvent{
+		Command: cmd,
+		Time:    time.Now(),
+		State:   state,
+		Token:   token,
+		Error:   err,
+	})
+	return err
+}
+
+// TestCycle is a test handler opening gate, e.g. to test the barrier after
+// maintenance. Gates the agent can close are closed again after hold.
+func TestCycle(gate *Gate, hold time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		log.Printf("Test cycle of gate %s.", gate.Name)
+		if err := gate.OpenContext(gatedriver.NewContext(ctx, gatedriver.Request{Trigger: gatedriver.TriggerTest})); err != nil {
+			return err
+		}
+		if !gate.CanClose() {
+			return nil
+		}
+
+		select {
+		case <-time.After(hold):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return gate.CloseContext(ctx)
+	}
+}
@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+// BlockingCallback blocks until its stage ends.
+var BlockingCallback = CallbackFunc(func(ctx context.Context, r ScanRequest) error {
+	<-ctx.Done()
+	return ctx.Err()
+})
+
+var FailingCallback = CallbackFunc(func(context.Context, ScanRequest) error {
+	return errors.New("failed")
+})
+
+// operatedAgent starts an agent and returns channels receiving its state
+// changes and operator events.
+func operatedAgent(agent *Agent) (chan interface{}, chan interface{}) {
+	go agent.Listen()
+	states, events := make(chan interface{}, 20), make(chan interface{}, 20)
+	agent.Subscribe(states)
+	agent.Subscribe(events)
+	return states, events
+}
+
+// waitForStage waits until agent handles stage.
+func waitForStage(t *testing.T, agent *Agent, stage string) {
+	w := agent.getWorker()
+	assert.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.stage == stage
+	}, time.Second, time.Millisecond)
+}
+
+func nextState(ch chan interface{}) FsmScanRequest {
+	for msg := range ch {
+		if r, ok := msg.(FsmScanRequest); ok {
+			return r
+		}
+	}
+	return FsmScanRequest{}
+}
+
+func nextOperatorEvent(ch chan interface{}) OperatorEvent {
+	for msg := range ch {
+		if e, ok := msg.(OperatorEvent); ok {
+			return e
+		}
+	}
+	return OperatorEvent{}
+}
+
+func nextRejected(ch chan interface{}) RejectedScanRequest {
+	for msg := range ch {
+		if r, ok := msg.(RejectedScanRequest); ok {
+			return r
+		}
+	}
+	return RejectedScanRequest{}
+}
+
+func nextLockStatus(ch chan interface{}) LockStatus {
+	for msg := range ch {
+		if s, ok := msg.(LockStatus); ok {
+			return s
+		}
+	}
+	return LockStatus{}
+}
+
+func TestAgent_Operate(t *testing.T) {
+	scanRequest := NewScanRequest("location", 42, PurposeEntry, *scanner.NewToken("token", "scanner 1"))
+
+	t.Run("requires operator", func(t *testing.T) {
+		agent := &Agent{}
+		go agent.Listen()
+		defer agent.Shutdown(context.Background())
+
+		err := agent.Operate(OperatorCommand{Action: ActionLock})
+
+		assert.Equal(t, ErrNoOperator, err)
+		assert.False(t, agent.Locked())
+	})
+	t.Run("approves request being validated", func(t *testing.T) {
+		agent := &Agent{ValidateHandler: BlockingCallback}
+		states, events := operatedAgent(agent)
+		defer agent.Shutdown(context.Background())
+
+		agent.HandleScanRequest(scanRequest)
+		waitForStage(t, agent, StateValidating)
+
+		err := agent.Operate(OperatorCommand{Action: ActionApprove, Operator: "alice"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, StateValidating, nextState(states).State)
+		assert.Equal(t, StatePrinting, nextState(states).State)
+		event := nextOperatorEvent(events)
+		assert.Equal(t, OperatorCommand{Action: ActionApprove, Operator: "alice"}, event.Command)
+		assert.Equal(t, StateValidating, event.State)
+		assert.Equal(t, "token", event.Token)
+		assert.NoError(t, event.Error)
+	})
+	t.Run("rejects request being handled", func(t *testing.T) {
+		agent := &Agent{GateHandler: BlockingCallback}
+		states, _ := operatedAgent(agent)
+		defer agent.Shutdown(context.Background())
+
+		agent.HandleScanRequest(scanRequest)
+		waitForStage(t, agent, StateGating)
+
+		err := agent.Operate(OperatorCommand{Action: ActionReject, Operator: "alice", Reason: "wrong container"})
+
+		assert.NoError(t, err)
+		for nextState(states).State != StateGating {
+		}
+		failed := nextState(states)
+		assert.Equal(t, StateError, failed.State)
+		assert.True(t, errors.Is(failed.ScanRequest.Error(), ErrRejected))
+		assert.EqualError(t, failed.ScanRequest.Error(), "rejected by operator alice")
+		assert.Equal(t, StateIdle, nextState(states).State)
+	})
+	t.Run("resets from error", func(t *testing.T) {
+		agent := &Agent{ValidateHandler: FailingCallback, ErrorHandler: BlockingCallback}
+		states, _ := operatedAgent(agent)
+		defer agent.Shutdown(context.Background())
+
+		agent.HandleScanRequest(scanRequest)
+		waitForStage(t, agent, StateError)
+
+		err := agent.Operate(OperatorCommand{Action: ActionReset, Operator: "alice"})
+
+		assert.NoError(t, err)
+		for nextState(states).State != StateError {
+		}
+		assert.Equal(t, StateIdle, nextState(states).State)
+	})
+	t.Run("refuses commands not applying to state", func(t *testing.T) {
+		agent := &Agent{}
+		_, events := operatedAgent(agent)
+		defer agent.Shutdown(context.Background())
+
+		err := agent.Operate(OperatorCommand{Action: ActionApprove, Operator: "alice"})
+
+		var notApplicable *NotApplicableError
+		assert.True(t, errors.As(err, &notApplicable))
+		assert.Equal(t, StateIdle, notApplicable.State)
+		assert.Equal(t, err, nextOperatorEvent(events).Error)
+	})
+	t.Run("refuses undefined actions", func(t *testing.T) {
+		agent := &Agent{}
+		go agent.Listen()
+		defer agent.Shutdown(context.Background())
+
+		err := agent.Operate(OperatorCommand{Action: "open", Operator: "alice"})
+
+		assert.EqualError(t, err, "undefined operator action: open")
+	})
+	t.Run("locks and unlocks lane", func(t *testing.T) {
+		agent := &Agent{}
+		states, rejected := operatedAgent(agent)
+		defer agent.Shutdown(context.Background())
+
+		assert.NoError(t, agent.Operate(OperatorCommand{Action: ActionLock, Operator: "alice"}))
+		agent.HandleScanRequest(scanRequest)
+		locked := nextRejected(rejected)
+		assert.Equal(t, ErrLocked, locked.ScanRequest.Error())
+
+		assert.NoError(t, agent.Operate(OperatorCommand{Action: ActionUnlock, Operator: "alice"}))
+		agent.HandleScanRequest(scanRequest)
+		assert.Equal(t, StateValidating, nextState(states).State)
+	})
+	t.Run("publishes lock changes", func(t *testing.T) {
+		agent := &Agent{}
+		statuses, _ := operatedAgent(agent)
+		defer agent.Shutdown(context.Background())
+
+		assert.NoError(t, agent.Operate(OperatorCommand{Action: ActionLock, Operator: "alice"}))
+		assert.NoError(t, agent.Operate(OperatorCommand{Action: ActionLock, Operator: "bob"}))
+		assert.NoError(t, agent.Operate(OperatorCommand{Action: ActionUnlock, Operator: "alice"}))
+
+		assert.Equal(t, LockStatus{Locked: true}, nextLockStatus(statuses))
+		assert.Equal(t, LockStatus{Locked: false}, nextLockStatus(statuses))
+	})
+	t.Run("runs test cycle while idle", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		agent := &Agent{TestHandler: func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		}}
+		_, rejected := operatedAgent(agent)
+		defer agent.Shutdown(context.Background())
+
+		assert.NoError(t, agent.Operate(OperatorCommand{Action: ActionTest, Operator: "alice"}))
+		<-started
+
+		assert.Equal(t, ErrBusy, agent.Operate(OperatorCommand{Action: ActionTest, Operator: "alice"}))
+		agent.HandleScanRequest(scanRequest)
+		busy := nextRejected(rejected)
+		assert.Equal(t, ErrBusy, busy.ScanRequest.Error())
+
+		close(release)
+		assert.Eventually(t, func() bool {
+			return agent.getWorker().Scan(scanRequest) == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("refuses test cycle without test handler", func(t *testing.T) {
+		agent := &Agent{}
+		go agent.Listen()
+		defer agent.Shutdown(context.Background())
+
+		err := agent.Operate(OperatorCommand{Action: ActionTest, Operator: "alice"})
+
+		assert.Equal(t, ErrNoTestCycle, err)
+	})
+}
+
+func TestTestCycle(t *testing.T) {
+	t.Run("opens and closes gate", func(t *testing.T) {
+		statuses := make(chan GateStatus, 10)
+		gate := &Gate{Name: "gate", Cmd: "/bin/true", CloseCmd: "/bin/true"}
+		gate.NotifyStatus(statuses)
+
+		err := TestCycle(gate, time.Millisecond)(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, GateOpen, (<-statuses).State)
+		assert.Equal(t, GateClosed, (<-statuses).State)
+	})
+	t.Run("leaves gates closing by themselves", func(t *testing.T) {
+		gate := &Gate{Name: "gate", Cmd: "/bin/true"}
+
+		err := TestCycle(gate, time.Hour)(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, GateUnknown, gate.State())
+	})
+}
@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A QueuePolicy defines how scan requests are handled while the agent is busy
+// handling another request.
+type QueuePolicy string
+
+const (
+	// PolicyDropNewest rejects scan requests while the agent is busy.
+	PolicyDropNewest QueuePolicy = "drop-newest"
+	// PolicyQueue queues scan requests while the agent is busy. Requests are
+	// rejected if the queue is full.
+	PolicyQueue QueuePolicy = "queue"
+	// PolicyReplace queues scan requests like PolicyQueue, but a request for a
+	// token already pending replaces the pending request.
+	PolicyReplace QueuePolicy = "replace"
+)
+
+// NewQueuePolicy returns the policy of the given name.
+func NewQueuePolicy(policy string) (QueuePolicy, error) {
+	switch QueuePolicy(policy) {
+	case PolicyDropNewest, PolicyQueue, PolicyReplace:
+		return QueuePolicy(policy), nil
+	}
+	return "", fmt.Errorf("undefined queue policy: %s", policy)
+}
+
+var (
+	// ErrQueueFull is returned when the worker is busy and no more requests
+	// can be queued.
+	ErrQueueFull = errors.New("scan queue is full")
+	// ErrQueueExpired is the error of a queued request that waited too long
+	// to be handled.
+	ErrQueueExpired = errors.New("scan request expired in queue")
+)
+
+// QueueOptions configure the scan queue of an agent. The zero value rejects
+// scan requests while the agent is busy.
+type QueueOptions struct {
+	Policy QueuePolicy
+	// Size is the maximum number of pending requests.
+	Size int
+	// MaxAge is the time a request may wait in the queue, zero for no limit.
+	MaxAge time.Duration
+}
+
+func (o QueueOptions) enabled() bool {
+	return o.Policy != "" && o.Policy != PolicyDropNewest && o.Size > 0
+}
+
+// QueueStatus is published to subscribers whenever the number of pending
+// scan requests changes, e.g. to ask drivers to wait.
+type QueueStatus struct {
+	Depth int
+	Size  int
+}
+
+// RejectedScanRequest is published to subscribers for a scan request that is
+// not handled at all, e.g. because the queue is full or it expired. The error
+// of the request tells why.
+type RejectedScanRequest struct {
+	ScanRequest ScanRequest
+	// Time is when the request was rejected.
+	Time time.Time
+}
+
+type queuedRequest struct {
+	req    ScanRequest
+	queued time.Time
+}
+
+// queue holds the scan requests pending while the worker is busy. It is not
+// safe for concurrent use.
+type queue struct {
+	options QueueOptions
+	pending []queuedRequest
+	now     func() time.Time
+}
+
+func newQueue(options QueueOptions) *queue {
+	return &queue{options: options, now: time.Now}
+}
+
+// push queues req, or returns an error if it cannot be queued.
+func (q *queue) push(req ScanRequest) error {
+	if q.options.Policy == PolicyReplace {
+		for i, p := range q.pending {
+			if p.req.Token() == req.Token() {
+				q.pending[i] = queuedRequest{req, q.now()}
+				return nil
+			}
+		}
+	}
+	if len(q.pending) >= q.options.Size {
+		return ErrQueueFull
+	}
+	q.pending = append(q.pending, queuedRequest{req, q.now()})
+	return nil
+}
+
+// pop returns the oldest pending request, if any. Requests that expired on
+// the way are returned as failed.
+func (q *queue) pop() (next ScanRequest, ok bool, expired []ScanRequest) {
+	for len(q.pending) > 0 {
+		p := q.pending[0]
+		q.pending = q.pending[1:]
+		if q.options.MaxAge > 0 && q.now().Sub(p.queued) > q.options.MaxAge {
+			p.req.Fail(ErrQueueExpired)
+			expired = append(expired, p.req)
+			continue
+		}
+		return p.req, true, expired
+	}
+	return ScanRequest{}, false, expired
+}
+
+// clear removes all pending requests and returns them as failed with err.
+func (q *queue) clear(err error) []ScanRequest {
+	var rejected []ScanRequest
+	for _, p := range q.pending {
+		p.req.Fail(err)
+		rejected = append(rejected, p.req)
+	}
+	q.pending = nil
+	return rejected
+}
+
+func (q *queue) status() QueueStatus {
+	return QueueStatus{Depth: len(q.pending), Size: q.options.Size}
+}
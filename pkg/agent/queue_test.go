@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+func scanRequest(token, scannerName string) ScanRequest {
+	return ScanRequest{token: *scanner.NewToken(token, scannerName)}
+}
+
+func TestQueue(t *testing.T) {
+	t.Run("returns requests in order", func(t *testing.T) {
+		q := newQueue(QueueOptions{Policy: PolicyQueue, Size: 2})
+
+		assert.NoError(t, q.push(scanRequest("token 1", "scanner 1")))
+		assert.NoError(t, q.push(scanRequest("token 2", "scanner 1")))
+		assert.Equal(t, QueueStatus{Depth: 2, Size: 2}, q.status())
+
+		next, ok, _ := q.pop()
+		assert.True(t, ok)
+		assert.Equal(t, "token 1", next.Token())
+		next, ok, _ = q.pop()
+		assert.True(t, ok)
+		assert.Equal(t, "token 2", next.Token())
+		_, ok, _ = q.pop()
+		assert.False(t, ok)
+	})
+	t.Run("rejects requests when full", func(t *testing.T) {
+		q := newQueue(QueueOptions{Policy: PolicyQueue, Size: 1})
+
+		assert.NoError(t, q.push(scanRequest("token 1", "scanner 1")))
+		assert.Equal(t, ErrQueueFull, q.push(scanRequest("token 1", "scanner 1")))
+	})
+	t.Run("replaces pending requests for the same token", func(t *testing.T) {
+		q := newQueue(QueueOptions{Policy: PolicyReplace, Size: 2})
+
+		assert.NoError(t, q.push(scanRequest("token 1", "scanner 1")))
+		assert.NoError(t, q.push(scanRequest("token 2", "scanner 1")))
+		assert.NoError(t, q.push(scanRequest("token 1", "scanner 2")))
+		assert.Equal(t, 2, q.status().Depth)
+
+		next, _, _ := q.pop()
+		assert.Equal(t, "scanner 2", next.ScannerName())
+	})
+	t.Run("expires old requests", func(t *testing.T) {
+		now := time.Now()
+		q := newQueue(QueueOptions{Policy: PolicyQueue, Size: 2, MaxAge: time.Minute})
+		q.now = func() time.Time { return now }
+
+		q.push(scanRequest("token 1", "scanner 1"))
+		now = now.Add(30 * time.Second)
+		q.push(scanRequest("token 2", "scanner 1"))
+		now = now.Add(45 * time.Second)
+
+		next, ok, expired := q.pop()
+		assert.True(t, ok)
+		assert.Equal(t, "token 2", next.Token())
+		assert.Len(t, expired, 1)
+		assert.Equal(t, "token 1", expired[0].Token())
+		assert.Equal(t, ErrQueueExpired, expired[0].Error())
+	})
+}
+
+func TestNewQueuePolicy(t *testing.T) {
+	for _, policy := range []string{"drop-newest", "queue", "replace"} {
+		p, err := NewQueuePolicy(policy)
+		assert.NoError(t, err)
+		assert.Equal(t, QueuePolicy(policy), p)
+	}
+	_, err := NewQueuePolicy("drop-oldest")
+	assert.Error(t, err)
+}
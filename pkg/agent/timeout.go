@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Timeouts limit the time spent in the stages of a scan request. A zero
+// timeout never expires.
+type Timeouts struct {
+	Validate time.Duration
+	Print    time.Duration
+	Gate     time.Duration
+	Pass     time.Duration
+	Error    time.Duration
+}
+
+// A StageTimeoutError is the error of a scan request whose stage did not
+// complete in time.
+type StageTimeoutError struct {
+	Stage   string
+	Timeout time.Duration
+}
+
+func (e *StageTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %v", e.Stage, e.Timeout)
+}
+
+// Is reports whether target is context.DeadlineExceeded, so timeouts can be
+// told apart regardless of the stage.
+func (e *StageTimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// callStage calls fn with a context that is done after timeout or when parent
+// is done. An operator may end the stage early by sending its outcome on
+// interrupt. Once the context is done or the stage was interrupted, callStage
+// waits for fn to return, so no stage drives the gate while the next one runs.
+// fn must therefore return once its context is done.
+func callStage(parent context.Context, stage string, timeout time.Duration, fn func(context.Context, ScanRequest) error, req ScanRequest, interrupt <-chan error) error {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx, req)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && parent.Err() == nil && ctx.Err() == context.DeadlineExceeded {
+			return &StageTimeoutError{Stage: stage, Timeout: timeout}
+		}
+		return err
+	case err := <-interrupt:
+		cancel()
+		<-done
+		return err
+	case <-ctx.Done():
+		<-done
+		if parent.Err() != nil {
+			return fmt.Errorf("%s canceled: %w", stage, ErrShutdown)
+		}
+		return &StageTimeoutError{Stage: stage, Timeout: timeout}
+	}
+}
@@ -3,7 +3,9 @@ package agent
 import (
 	"context"
 	"errors"
+	"log"
 	"sync"
+	"time"
 
 	"github.com/looplab/fsm"
 )
@@ -15,6 +17,8 @@ const (
 	EventValidated = "validated"
 	// EventPrinted gets fired when the interchange for a request has been printed successfully.
 	EventPrinted = "printed"
+	// EventOpened gets fired when the gate has been opened for a request.
+	EventOpened = "opened"
 	// EventFinished gets fired when handling a request has finished.
 	EventFinished = "finished"
 	// EventFailed gets fired when handling a request has failed.
@@ -30,6 +34,9 @@ const (
 	StatePrinting = "printing"
 	// StateGating represents the gating state.
 	StateGating = "gating"
+	// StatePassing represents the passing state, waiting for the vehicle to
+	// pass the opened gate.
+	StatePassing = "passing"
 	// StateError represents the error state.
 	StateError = "error"
 )
@@ -39,6 +46,9 @@ var (
 	ErrBusy = errors.New("worker is busy")
 	// ErrShutdown is returned when the worker is shutting down.
 	ErrShutdown = errors.New("worker is shutting down")
+	// ErrLocked is the error of scan requests rejected while the lane is
+	// locked.
+	ErrLocked = errors.New("lane is locked")
 )
 
 type worker struct {
@@ -47,11 +57,44 @@ type worker struct {
 	mu                     sync.Mutex
 	subs                   map[chan interface{}]struct{}
 	shutdownChan, doneChan chan struct{}
+
+	// queue holds the requests pending while busy, nil if requests are
+	// rejected instead.
+	queue *queue
+	// busy is set from starting to handle a request until returning to idle.
+	busy bool
+	// locked is set while scan requests are rejected on request of an
+	// operator.
+	locked bool
+
+	// current is the request handled in the current or last stage.
+	current ScanRequest
+	// stage is the stage being handled, if any. An operator may end it early
+	// by sending its outcome on interrupt.
+	stage     string
+	interrupt chan error
+
+	timeouts Timeouts
+	// entered is the time the state was entered, which is published only
+	// once its stage was handled.
+	entered time.Time
+	now     func() time.Time
+	// ctx is canceled if shutting down takes too long.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 type FsmScanRequest struct {
 	ScanRequest ScanRequest
 	State       string
+	// Time is when State was entered.
+	Time time.Time
+}
+
+// LockStatus is published to subscribers whenever the lane is locked or
+// unlocked, e.g. to show the lane is closed for maintenance.
+type LockStatus struct {
+	Locked bool
 }
 
 func newWorker(handler Handler) *worker {
@@ -60,23 +103,28 @@ func newWorker(handler Handler) *worker {
 		subs:         map[chan interface{}]struct{}{},
 		shutdownChan: make(chan struct{}),
 		doneChan:     make(chan struct{}),
+		now:          time.Now,
 	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
 	w.fsm = fsm.NewFSM(
 		StateIdle,
 		fsm.Events{
 			{Name: EventScanned, Src: []string{StateIdle}, Dst: StateValidating},
 			{Name: EventValidated, Src: []string{StateValidating}, Dst: StatePrinting},
 			{Name: EventPrinted, Src: []string{StatePrinting}, Dst: StateGating},
-			{Name: EventFinished, Src: []string{StateGating}, Dst: StateIdle},
-			{Name: EventFailed, Src: []string{StateValidating, StatePrinting, StateGating}, Dst: StateError},
+			{Name: EventOpened, Src: []string{StateGating}, Dst: StatePassing},
+			{Name: EventFinished, Src: []string{StatePassing}, Dst: StateIdle},
+			{Name: EventFailed, Src: []string{StateValidating, StatePrinting, StateGating, StatePassing}, Dst: StateError},
 			{Name: EventReset, Src: []string{StateError}, Dst: StateIdle},
 		},
 		fsm.Callbacks{
+			"leave_state":   w.leaveState,
 			"enter_state":   w.enterState,
 			StateIdle:       w.onIdle,
 			StateValidating: w.onValidating,
 			StatePrinting:   w.onPrinting,
 			StateGating:     w.onGating,
+			StatePassing:    w.onPassing,
 			StateError:      w.onError,
 		},
 	)
@@ -87,33 +135,62 @@ func newWorker(handler Handler) *worker {
 // Shutdown gracefully shuts down the worker without interrupting any active
 // processes. Shutdown works by waiting indefinitely to return to idle and then
 // shutdown. If the provided context expires before the shutdown is complete,
-// Shutdown returns the context's error, otherwise nil.
+// the active process is canceled and Shutdown returns the context's error,
+// otherwise nil.
 func (w *worker) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
 	close(w.shutdownChan)
 
 	// Worker is idle, therefore we can shutdown immediatly.
-	if w.fsm.Current() == StateIdle {
+	if w.fsm.Current() == StateIdle && !w.busy {
 		close(w.doneChan)
 	}
+	w.mu.Unlock()
 
 	select {
 	case <-w.doneChan:
 		return nil
 	case <-ctx.Done():
+		w.cancel()
 		return ctx.Err()
 	}
 }
 
+// Scan starts handling req. If the worker is busy, req is queued or rejected,
+// depending on the queue of the worker. Rejected requests are published.
 func (w *worker) Scan(req ScanRequest) error {
 	w.mu.Lock()
-	defer w.mu.Unlock()
 	if w.isShutdown() {
+		w.mu.Unlock()
 		return ErrShutdown
 	}
-	if w.fsm.Cannot(EventScanned) {
-		return ErrBusy
+	if w.locked {
+		w.mu.Unlock()
+		req.Fail(ErrLocked)
+		w.publish(RejectedScanRequest{ScanRequest: req, Time: w.now()})
+		return ErrLocked
+	}
+	if !w.busy && w.fsm.Current() == StateIdle {
+		w.busy = true
+		w.mu.Unlock()
+		go w.fsm.Event(EventScanned, req)
+		return nil
 	}
-	go w.fsm.Event(EventScanned, req)
+
+	err := ErrBusy
+	var status QueueStatus
+	if w.queue != nil {
+		err = w.queue.push(req)
+		status = w.queue.status()
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		req.Fail(err)
+		w.publish(RejectedScanRequest{ScanRequest: req, Time: w.now()})
+		return err
+	}
+	w.publish(status)
 	return nil
 }
 
@@ -131,6 +208,106 @@ func (w *worker) Unsubscribe(msgCh chan interface{}) {
 	delete(w.subs, msgCh)
 }
 
+// Lock rejects scan requests, including pending ones, until the worker is
+// unlocked. The request being handled is not affected.
+func (w *worker) Lock() {
+	w.mu.Lock()
+	changed := !w.locked
+	w.locked = true
+	var rejected []ScanRequest
+	var status QueueStatus
+	if w.queue != nil {
+		rejected = w.queue.clear(ErrLocked)
+		status = w.queue.status()
+	}
+	w.mu.Unlock()
+
+	if changed {
+		w.publish(LockStatus{Locked: true})
+	}
+	for _, req := range rejected {
+		w.publish(RejectedScanRequest{ScanRequest: req, Time: w.now()})
+	}
+	if len(rejected) > 0 {
+		w.publish(status)
+	}
+}
+
+// Unlock accepts scan requests again.
+func (w *worker) Unlock() {
+	w.mu.Lock()
+	changed := w.locked
+	w.locked = false
+	w.mu.Unlock()
+
+	if changed {
+		w.publish(LockStatus{Locked: false})
+	}
+}
+
+// Locked reports whether scan requests are rejected.
+func (w *worker) Locked() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.locked
+}
+
+// Current returns the state of the worker and the request handled last.
+func (w *worker) Current() (string, ScanRequest) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.fsm.Current(), w.current
+}
+
+// Interrupt ends the stage being handled with err, if it is one of stages.
+// A nil error completes the stage successfully.
+func (w *worker) Interrupt(err error, stages ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, stage := range stages {
+		if w.stage != stage {
+			continue
+		}
+		select {
+		case w.interrupt <- err:
+			return nil
+		default:
+			return ErrInterrupted
+		}
+	}
+	return &NotApplicableError{State: w.fsm.Current()}
+}
+
+// Test runs fn while the worker is idle, so no request is handled meanwhile.
+// Scan requests are queued or rejected until fn returns, as if a request was
+// handled. Test returns once fn was started.
+func (w *worker) Test(fn func(context.Context) error) error {
+	w.mu.Lock()
+	if w.isShutdown() {
+		w.mu.Unlock()
+		return ErrShutdown
+	}
+	if w.busy || w.fsm.Current() != StateIdle {
+		w.mu.Unlock()
+		return ErrBusy
+	}
+	w.busy = true
+	w.mu.Unlock()
+
+	go func() {
+		err := callStage(w.ctx, "test", 0, func(ctx context.Context, _ ScanRequest) error {
+			return fn(ctx)
+		}, ScanRequest{}, nil)
+		if err != nil {
+			log.Printf("Test cycle failed: %v", err)
+		} else {
+			log.Printf("Test cycle completed.")
+		}
+		w.next()
+	}()
+	return nil
+}
+
 func (w *worker) isShutdown() bool {
 	select {
 	case <-w.shutdownChan:
@@ -147,25 +324,70 @@ func (w *worker) publish(msg interface{}) {
 	}
 	w.mu.Unlock()
 }
+
+// leaveState notes the time of the transition. Transitions do not overlap, the
+// next one waits for the state entered to be published.
+func (w *worker) leaveState(e *fsm.Event) {
+	w.entered = w.now()
+}
+
 func (w *worker) enterState(e *fsm.Event) {
 	req := e.Args[0].(ScanRequest)
 
 	toBePublished := FsmScanRequest{
 		ScanRequest: req,
 		State:       w.fsm.Current(),
+		Time:        w.entered,
 	}
 	w.publish(toBePublished)
 }
 
 func (w *worker) onIdle(e *fsm.Event) {
+	w.next()
+}
+
+// next starts handling the next pending request, if any. Otherwise the worker
+// becomes idle and, if shutting down, done.
+func (w *worker) next() {
+	w.mu.Lock()
+	w.busy = false
+	var rejected []ScanRequest
 	if w.isShutdown() {
+		if w.queue != nil {
+			rejected = w.queue.clear(ErrShutdown)
+		}
 		close(w.doneChan)
+	} else if w.queue != nil {
+		next, ok, expired := w.queue.pop()
+		if ok {
+			w.busy = true
+			go w.fsm.Event(EventScanned, next)
+		}
+		rejected = expired
+	}
+	changed := w.queue != nil && (w.busy || len(rejected) > 0)
+	var status QueueStatus
+	if changed {
+		status = w.queue.status()
+	}
+	w.mu.Unlock()
+
+	for _, req := range rejected {
+		w.publish(RejectedScanRequest{ScanRequest: req, Time: w.now()})
+	}
+	if changed {
+		w.publish(status)
 	}
 }
 
 func (w *worker) onValidating(e *fsm.Event) {
 	req := e.Args[0].(ScanRequest)
-	if err := w.handler.Validate(req); err != nil {
+	// Requests may fail before validation, e.g. on forged signed tokens.
+	if req.Error() != nil {
+		go w.fsm.Event(EventFailed, req)
+		return
+	}
+	if err := w.callStage(StateValidating, w.timeouts.Validate, w.handler.Validate, req); err != nil {
 		req.Fail(err)
 		go w.fsm.Event(EventFailed, req)
 	} else {
@@ -175,7 +397,7 @@ func (w *worker) onValidating(e *fsm.Event) {
 
 func (w *worker) onPrinting(e *fsm.Event) {
 	req := e.Args[0].(ScanRequest)
-	if err := w.handler.Print(req); err != nil {
+	if err := w.callStage(StatePrinting, w.timeouts.Print, w.handler.Print, req); err != nil {
 		req.Fail(err)
 		go w.fsm.Event(EventFailed, req)
 	} else {
@@ -185,7 +407,17 @@ func (w *worker) onPrinting(e *fsm.Event) {
 
 func (w *worker) onGating(e *fsm.Event) {
 	req := e.Args[0].(ScanRequest)
-	if err := w.handler.Gate(req); err != nil {
+	if err := w.callStage(StateGating, w.timeouts.Gate, w.handler.Gate, req); err != nil {
+		req.Fail(err)
+		go w.fsm.Event(EventFailed, req)
+	} else {
+		go w.fsm.Event(EventOpened, req)
+	}
+}
+
+func (w *worker) onPassing(e *fsm.Event) {
+	req := e.Args[0].(ScanRequest)
+	if err := w.callStage(StatePassing, w.timeouts.Pass, w.handler.Pass, req); err != nil {
 		req.Fail(err)
 		go w.fsm.Event(EventFailed, req)
 	} else {
@@ -195,6 +427,27 @@ func (w *worker) onGating(e *fsm.Event) {
 
 func (w *worker) onError(e *fsm.Event) {
 	req := e.Args[0].(ScanRequest)
-	w.handler.Error(req)
+	if err := w.callStage(StateError, w.timeouts.Error, w.handler.Error, req); err != nil {
+		log.Printf("[%s] Failed to handle error: %v", req.Token(), err)
+	}
 	go w.fsm.Event(EventReset, req)
 }
+
+// callStage calls fn for stage, which may be interrupted by an operator
+// meanwhile.
+func (w *worker) callStage(stage string, timeout time.Duration, fn func(context.Context, ScanRequest) error, req ScanRequest) error {
+	w.mu.Lock()
+	w.current = req
+	w.stage = stage
+	w.interrupt = make(chan error, 1)
+	interrupt := w.interrupt
+	w.mu.Unlock()
+
+	err := callStage(w.ctx, stage, timeout, fn, req, interrupt)
+
+	w.mu.Lock()
+	w.stage = ""
+	w.interrupt = nil
+	w.mu.Unlock()
+	return err
+}
@@ -18,10 +18,25 @@ func (a *DummyAgent) Step()  { a.errors <- nil }
 func (a *DummyAgent) Fail()  { a.errors <- errors.New("failed") }
 func (a *DummyAgent) Close() { close(a.errors) }
 
-func (a *DummyAgent) Validate(ScanRequest) error { return <-a.errors }
-func (a *DummyAgent) Print(ScanRequest) error    { return <-a.errors }
-func (a *DummyAgent) Gate(ScanRequest) error     { return <-a.errors }
-func (a *DummyAgent) Error(ScanRequest) error    { return <-a.errors }
+func (a *DummyAgent) Validate(context.Context, ScanRequest) error { return <-a.errors }
+func (a *DummyAgent) Print(context.Context, ScanRequest) error    { return <-a.errors }
+func (a *DummyAgent) Gate(context.Context, ScanRequest) error     { return <-a.errors }
+func (a *DummyAgent) Pass(context.Context, ScanRequest) error     { return <-a.errors }
+func (a *DummyAgent) Error(context.Context, ScanRequest) error    { return <-a.errors }
+
+// untimed strips the time from published scan requests, so they equal the
+// requests expected.
+func untimed(msg interface{}) interface{} {
+	switch m := msg.(type) {
+	case FsmScanRequest:
+		m.Time = time.Time{}
+		return m
+	case RejectedScanRequest:
+		m.Time = time.Time{}
+		return m
+	}
+	return msg
+}
 
 func fsmScanValidating(scanRequest ScanRequest) FsmScanRequest {
 	return FsmScanRequest{ScanRequest: scanRequest, State: StateValidating}
@@ -46,6 +61,9 @@ func fsmScanPrinting(scanRequest ScanRequest) FsmScanRequest {
 func fsmScanGating(scanRequest ScanRequest) FsmScanRequest {
 	return FsmScanRequest{ScanRequest: scanRequest, State: StateGating}
 }
+func fsmScanPassing(scanRequest ScanRequest) FsmScanRequest {
+	return FsmScanRequest{ScanRequest: scanRequest, State: StatePassing}
+}
 
 func TestWorker_FSM(t *testing.T) {
 	t.Run("defaults to state idle", func(t *testing.T) {
@@ -66,6 +84,33 @@ func TestWorker_FSM(t *testing.T) {
 		err := w.Scan(ScanRequest{token: *scanner.NewToken("token", "scanner 1")})
 		assert.EqualError(t, err, ErrShutdown.Error())
 	})
+	t.Run("publishes time states were entered", func(t *testing.T) {
+		a := DummyAgent{make(chan error)}
+		ch := make(chan interface{}, 10)
+		w := newWorker(&a)
+		w.Subscribe(ch)
+
+		assert.NoError(t, w.Scan(ScanRequest{token: *scanner.NewToken("token", "scanner 1")}))
+		time.Sleep(10 * time.Millisecond)
+		validated := time.Now()
+		a.Step()
+
+		assert.True(t, (<-ch).(FsmScanRequest).Time.Before(validated))
+		a.Close()
+		assert.Equal(t, StatePrinting, (<-ch).(FsmScanRequest).State)
+	})
+	t.Run("publishes time requests were rejected", func(t *testing.T) {
+		ch := make(chan interface{}, 10)
+		w := newWorker(&DummyAgent{})
+		w.Subscribe(ch)
+		w.Lock()
+		assert.Equal(t, LockStatus{Locked: true}, <-ch)
+
+		before := time.Now()
+		w.Scan(ScanRequest{token: *scanner.NewToken("token", "scanner 1")})
+
+		assert.False(t, (<-ch).(RejectedScanRequest).Time.Before(before))
+	})
 	t.Run("scan without errors leads to idle", func(t *testing.T) {
 		a := DummyAgent{make(chan error)}
 		defer a.Close()
@@ -79,15 +124,18 @@ func TestWorker_FSM(t *testing.T) {
 		assert.NoError(t, err)
 
 		go a.Step()
-		assert.Equal(t, fsmScanValidating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanValidating(scanRequest), untimed(<-ch))
+
+		go a.Step()
+		assert.Equal(t, fsmScanPrinting(scanRequest), untimed(<-ch))
 
 		go a.Step()
-		assert.Equal(t, fsmScanPrinting(scanRequest), <-ch)
+		assert.Equal(t, fsmScanGating(scanRequest), untimed(<-ch))
 
 		go a.Step()
-		assert.Equal(t, fsmScanGating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanPassing(scanRequest), untimed(<-ch))
 
-		assert.Equal(t, fsmScanIdle(scanRequest), <-ch)
+		assert.Equal(t, fsmScanIdle(scanRequest), untimed(<-ch))
 		assert.Equal(t, StateIdle, w.fsm.Current())
 	})
 	t.Run("invalid scan leads to error", func(t *testing.T) {
@@ -102,12 +150,32 @@ func TestWorker_FSM(t *testing.T) {
 		assert.NoError(t, err)
 
 		go a.Fail()
-		assert.Equal(t, fsmScanValidating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanValidating(scanRequest), untimed(<-ch))
+
+		go a.Step()
+		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), untimed(<-ch))
+
+		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), untimed(<-ch))
+		assert.Equal(t, StateIdle, w.fsm.Current())
+	})
+	t.Run("failed scan leads to error without validation", func(t *testing.T) {
+		a := DummyAgent{make(chan error)}
+		defer a.Close()
+
+		ch := make(chan interface{}, 1)
+		w := newWorker(&a)
+		w.Subscribe(ch)
+		scanRequest := ScanRequest{token: *scanner.NewToken("token", "scanner 1")}
+		scanRequest.Fail(errors.New("forged"))
+		err := w.Scan(scanRequest)
+		assert.NoError(t, err)
+
+		assert.Equal(t, fsmScanValidating(scanRequest), untimed(<-ch))
 
 		go a.Step()
-		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanError(scanRequest, errors.New("forged")), untimed(<-ch))
 
-		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("forged")), untimed(<-ch))
 		assert.Equal(t, StateIdle, w.fsm.Current())
 	})
 	t.Run("invalid print leads to error", func(t *testing.T) {
@@ -122,15 +190,15 @@ func TestWorker_FSM(t *testing.T) {
 		assert.NoError(t, err)
 
 		go a.Step()
-		assert.Equal(t, fsmScanValidating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanValidating(scanRequest), untimed(<-ch))
 
 		go a.Fail()
-		assert.Equal(t, fsmScanPrinting(scanRequest), <-ch)
+		assert.Equal(t, fsmScanPrinting(scanRequest), untimed(<-ch))
 
 		go a.Step()
-		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), untimed(<-ch))
 
-		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), untimed(<-ch))
 		assert.Equal(t, StateIdle, w.fsm.Current())
 	})
 	t.Run("invalid gate leads to error", func(t *testing.T) {
@@ -145,18 +213,47 @@ func TestWorker_FSM(t *testing.T) {
 		assert.NoError(t, err)
 
 		go a.Step()
-		assert.Equal(t, fsmScanValidating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanValidating(scanRequest), untimed(<-ch))
+
+		go a.Step()
+		assert.Equal(t, fsmScanPrinting(scanRequest), untimed(<-ch))
+
+		go a.Fail()
+		assert.Equal(t, fsmScanGating(scanRequest), untimed(<-ch))
+
+		go a.Step()
+		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), untimed(<-ch))
+
+		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), untimed(<-ch))
+		assert.Equal(t, StateIdle, w.fsm.Current())
+	})
+	t.Run("missing passage leads to error", func(t *testing.T) {
+		a := DummyAgent{make(chan error)}
+		defer a.Close()
+
+		ch := make(chan interface{}, 1)
+		w := newWorker(&a)
+		w.Subscribe(ch)
+		scanRequest := ScanRequest{token: *scanner.NewToken("token", "scanner 1")}
+		err := w.Scan(scanRequest)
+		assert.NoError(t, err)
+
+		go a.Step()
+		assert.Equal(t, fsmScanValidating(scanRequest), untimed(<-ch))
+
+		go a.Step()
+		assert.Equal(t, fsmScanPrinting(scanRequest), untimed(<-ch))
 
 		go a.Step()
-		assert.Equal(t, fsmScanPrinting(scanRequest), <-ch)
+		assert.Equal(t, fsmScanGating(scanRequest), untimed(<-ch))
 
 		go a.Fail()
-		assert.Equal(t, fsmScanGating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanPassing(scanRequest), untimed(<-ch))
 
 		go a.Step()
-		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), untimed(<-ch))
 
-		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), untimed(<-ch))
 		assert.Equal(t, StateIdle, w.fsm.Current())
 	})
 	t.Run("can handle errors in error handler", func(t *testing.T) {
@@ -172,18 +269,18 @@ func TestWorker_FSM(t *testing.T) {
 		assert.NoError(t, err)
 
 		go a.Step()
-		assert.Equal(t, fsmScanValidating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanValidating(scanRequest), untimed(<-ch))
 
 		go a.Step()
-		assert.Equal(t, fsmScanPrinting(scanRequest), <-ch)
+		assert.Equal(t, fsmScanPrinting(scanRequest), untimed(<-ch))
 
 		go a.Fail()
-		assert.Equal(t, fsmScanGating(scanRequest), <-ch)
+		assert.Equal(t, fsmScanGating(scanRequest), untimed(<-ch))
 
 		go a.Fail()
-		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanError(scanRequest, errors.New("failed")), untimed(<-ch))
 
-		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), <-ch)
+		assert.Equal(t, fsmScanIdleError(scanRequest, errors.New("failed")), untimed(<-ch))
 		assert.Equal(t, StateIdle, w.fsm.Current())
 	})
 }
@@ -213,7 +310,7 @@ func TestWorker_Shutdown(t *testing.T) {
 	})
 	t.Run("terminates on idle", func(t *testing.T) {
 		w := newWorker(nil)
-		w.fsm.SetState(StateGating)
+		w.fsm.SetState(StatePassing)
 
 		go func() {
 			time.Sleep(100 * time.Millisecond)
@@ -242,3 +339,125 @@ func TestWorker_Subscribe(t *testing.T) {
 	w.Unsubscribe(ch)
 	assert.Equal(t, 0, len(w.subs))
 }
+
+func TestWorker_Queue(t *testing.T) {
+	t.Run("rejects scans while busy without queue", func(t *testing.T) {
+		a := DummyAgent{make(chan error)}
+		defer a.Close()
+
+		ch := make(chan interface{}, 10)
+		w := newWorker(&a)
+		w.Subscribe(ch)
+
+		first := ScanRequest{token: *scanner.NewToken("token 1", "scanner 1")}
+		second := ScanRequest{token: *scanner.NewToken("token 2", "scanner 2")}
+		assert.NoError(t, w.Scan(first))
+
+		assert.Equal(t, ErrBusy, w.Scan(second))
+		second.Fail(ErrBusy)
+		assert.Equal(t, RejectedScanRequest{ScanRequest: second}, untimed(<-ch))
+	})
+	t.Run("handles queued scans when idle", func(t *testing.T) {
+		a := DummyAgent{make(chan error)}
+		defer a.Close()
+
+		ch := make(chan interface{}, 10)
+		w := newWorker(&a)
+		w.queue = newQueue(QueueOptions{Policy: PolicyQueue, Size: 1})
+		w.Subscribe(ch)
+
+		first := ScanRequest{token: *scanner.NewToken("token 1", "scanner 1")}
+		second := ScanRequest{token: *scanner.NewToken("token 2", "scanner 2")}
+		third := ScanRequest{token: *scanner.NewToken("token 3", "scanner 2")}
+		assert.NoError(t, w.Scan(first))
+
+		assert.NoError(t, w.Scan(second))
+		assert.Equal(t, QueueStatus{Depth: 1, Size: 1}, <-ch)
+		assert.Equal(t, ErrQueueFull, w.Scan(third))
+		third.Fail(ErrQueueFull)
+		assert.Equal(t, RejectedScanRequest{ScanRequest: third}, untimed(<-ch))
+
+		go a.Step()
+		assert.Equal(t, fsmScanValidating(first), untimed(<-ch))
+		go a.Step()
+		assert.Equal(t, fsmScanPrinting(first), untimed(<-ch))
+		go a.Step()
+		assert.Equal(t, fsmScanGating(first), untimed(<-ch))
+		go a.Step()
+		assert.Equal(t, fsmScanPassing(first), untimed(<-ch))
+		assert.Equal(t, QueueStatus{Depth: 0, Size: 1}, <-ch)
+		assert.Equal(t, fsmScanIdle(first), untimed(<-ch))
+
+		go a.Step()
+		assert.Equal(t, fsmScanValidating(second), untimed(<-ch))
+	})
+}
+
+// HangingAgent never completes validation, unless the context is done.
+type HangingAgent struct{}
+
+func (HangingAgent) Validate(ctx context.Context, _ ScanRequest) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+func (HangingAgent) Print(context.Context, ScanRequest) error { return nil }
+func (HangingAgent) Gate(context.Context, ScanRequest) error  { return nil }
+func (HangingAgent) Pass(context.Context, ScanRequest) error  { return nil }
+func (HangingAgent) Error(context.Context, ScanRequest) error { return nil }
+
+func TestWorker_Timeouts(t *testing.T) {
+	t.Run("fails stages that time out", func(t *testing.T) {
+		ch := make(chan interface{}, 10)
+		w := newWorker(HangingAgent{})
+		w.timeouts = Timeouts{Validate: 10 * time.Millisecond}
+		w.Subscribe(ch)
+
+		assert.NoError(t, w.Scan(ScanRequest{token: *scanner.NewToken("token", "scanner 1")}))
+
+		assert.Equal(t, StateValidating, (<-ch).(FsmScanRequest).State)
+		failed := (<-ch).(FsmScanRequest)
+		assert.Equal(t, StateError, failed.State)
+		assert.Equal(t, &StageTimeoutError{Stage: StateValidating, Timeout: 10 * time.Millisecond}, failed.ScanRequest.Error())
+		assert.True(t, errors.Is(failed.ScanRequest.Error(), context.DeadlineExceeded))
+	})
+	t.Run("waits for callbacks ignoring the context", func(t *testing.T) {
+		a := DummyAgent{make(chan error)}
+
+		ch := make(chan interface{}, 10)
+		w := newWorker(&a)
+		w.timeouts = Timeouts{Validate: 10 * time.Millisecond, Error: 10 * time.Millisecond}
+		w.Subscribe(ch)
+
+		assert.NoError(t, w.Scan(ScanRequest{token: *scanner.NewToken("token", "scanner 1")}))
+
+		select {
+		case msg := <-ch:
+			t.Fatalf("stage ended before its callback returned: %v", msg)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		a.Step()
+		assert.Equal(t, StateValidating, (<-ch).(FsmScanRequest).State)
+		a.Close()
+		failed := (<-ch).(FsmScanRequest)
+		assert.Equal(t, StateError, failed.State)
+		assert.IsType(t, &StageTimeoutError{}, failed.ScanRequest.Error())
+		assert.Equal(t, StateIdle, (<-ch).(FsmScanRequest).State)
+	})
+	t.Run("cancels stages when shutdown times out", func(t *testing.T) {
+		ch := make(chan interface{}, 10)
+		w := newWorker(HangingAgent{})
+		w.Subscribe(ch)
+
+		assert.NoError(t, w.Scan(ScanRequest{token: *scanner.NewToken("token", "scanner 1")}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, w.Shutdown(ctx))
+
+		assert.Equal(t, StateValidating, (<-ch).(FsmScanRequest).State)
+		failed := (<-ch).(FsmScanRequest)
+		assert.True(t, errors.Is(failed.ScanRequest.Error(), ErrShutdown))
+		<-w.doneChan
+	})
+}
@@ -0,0 +1,163 @@
+// Package evdev reads keyboard-wedge scanners through the Linux input event
+// interface.
+package evdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"syscall"
+	"unicode/utf8"
+	"unsafe"
+)
+
+const (
+	evKey = 0x01
+
+	keyReleased = 0
+	keyPressed  = 1
+	keyRepeated = 2
+
+	keyLeftShift  = 42
+	keyRightShift = 54
+	keyCapsLock   = 58
+	keyRightAlt   = 100
+
+	// eviocgrab is EVIOCGRAB, _IOW('E', 0x90, int).
+	eviocgrab = 0x40044590
+)
+
+// inputEvent mirrors struct input_event of linux/input.h.
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+var eventSize = int(unsafe.Sizeof(inputEvent{}))
+
+// A Device is a keyboard input device whose key events are decoded into
+// characters. Reading from a Device returns the typed characters as UTF-8.
+type Device struct {
+	rc     io.ReadCloser
+	layout Layout
+	ungrab func() error
+
+	shift, altGr, capsLock bool
+
+	pending []byte
+	events  []byte
+}
+
+// Open opens the input device at path and takes an exclusive grab, so key
+// events of the device are not delivered to any other application.
+func Open(path string, layout Layout) (*Device, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := grab(f, 1); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "grab", Path: path, Err: err}
+	}
+
+	ungrab := func() error {
+		return grab(f, 0)
+	}
+
+	return newDevice(f, layout, ungrab), nil
+}
+
+func newDevice(rc io.ReadCloser, layout Layout, ungrab func() error) *Device {
+	return &Device{
+		rc:     rc,
+		layout: layout,
+		ungrab: ungrab,
+		events: make([]byte, 64*eventSize),
+	}
+}
+
+// Read reads decoded characters into p. It blocks until at least one
+// character is available.
+func (d *Device) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		n, err := io.ReadAtLeast(d.rc, d.events, eventSize)
+		for i := 0; i+eventSize <= n; i += eventSize {
+			var ev inputEvent
+			if err := binary.Read(bytes.NewReader(d.events[i:i+eventSize]), binary.LittleEndian, &ev); err != nil {
+				return 0, err
+			}
+			d.handle(ev)
+		}
+		if err != nil && len(d.pending) == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// Close releases the grab and closes the device.
+func (d *Device) Close() error {
+	if d.ungrab != nil {
+		d.ungrab()
+	}
+	return d.rc.Close()
+}
+
+func (d *Device) handle(ev inputEvent) {
+	if ev.Type != evKey {
+		return
+	}
+
+	pressed := ev.Value == keyPressed || ev.Value == keyRepeated
+
+	switch ev.Code {
+	case keyLeftShift, keyRightShift:
+		d.shift = pressed
+		return
+	case keyRightAlt:
+		d.altGr = pressed
+		return
+	case keyCapsLock:
+		if ev.Value == keyPressed {
+			d.capsLock = !d.capsLock
+		}
+		return
+	}
+
+	if !pressed {
+		return
+	}
+
+	if r, ok := d.layout.char(ev.Code, d.shift, d.altGr, d.capsLock); ok {
+		buf := make([]byte, utf8.UTFMax)
+		d.pending = append(d.pending, buf[:utf8.EncodeRune(buf, r)]...)
+	}
+}
+
+// grab takes or releases the exclusive grab of f. The descriptor is used
+// through its raw connection, since f.Fd would switch f to blocking mode, so
+// closing f would no longer interrupt a pending read.
+func grab(f *os.File, enable int) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, eviocgrab, uintptr(enable))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package evdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func keyEvents(keys ...uint16) []inputEvent {
+	var events []inputEvent
+	for _, k := range keys {
+		events = append(events,
+			inputEvent{Type: evKey, Code: k, Value: keyPressed},
+			inputEvent{Type: evKey, Code: k, Value: keyReleased},
+		)
+	}
+	return events
+}
+
+func shifted(keys ...uint16) []inputEvent {
+	events := []inputEvent{{Type: evKey, Code: keyLeftShift, Value: keyPressed}}
+	events = append(events, keyEvents(keys...)...)
+	return append(events, inputEvent{Type: evKey, Code: keyLeftShift, Value: keyReleased})
+}
+
+func device(layout Layout, events ...[]inputEvent) *Device {
+	buf := &bytes.Buffer{}
+	for _, e := range events {
+		for _, ev := range e {
+			binary.Write(buf, binary.LittleEndian, ev)
+		}
+	}
+	return newDevice(ioutil.NopCloser(buf), layout, nil)
+}
+
+func TestDevice_Read(t *testing.T) {
+	t.Run("decodes key presses", func(t *testing.T) {
+		d := device(LayoutUS, keyEvents(20, 20, 12, 2, 3, 28))
+		out, err := ioutil.ReadAll(d)
+		assert.NoError(t, err)
+		assert.Equal(t, "tt-12\n", string(out))
+	})
+	t.Run("respects shift state", func(t *testing.T) {
+		d := device(LayoutUS, keyEvents(16), shifted(16, 2), keyEvents(2))
+		out, _ := ioutil.ReadAll(d)
+		assert.Equal(t, "qQ!1", string(out))
+	})
+	t.Run("respects caps lock", func(t *testing.T) {
+		d := device(LayoutUS, keyEvents(keyCapsLock, 30, 2), shifted(30))
+		out, _ := ioutil.ReadAll(d)
+		assert.Equal(t, "A1a", string(out))
+	})
+	t.Run("uses configured layout", func(t *testing.T) {
+		d := device(LayoutDE, keyEvents(21, 44, 53), shifted(8))
+		out, _ := ioutil.ReadAll(d)
+		assert.Equal(t, "zy-/", string(out))
+	})
+	t.Run("decodes alt gr", func(t *testing.T) {
+		events := []inputEvent{{Type: evKey, Code: keyRightAlt, Value: keyPressed}}
+		events = append(events, keyEvents(16)...)
+		d := device(LayoutDE, events)
+		out, _ := ioutil.ReadAll(d)
+		assert.Equal(t, "@", string(out))
+	})
+	t.Run("ignores other events", func(t *testing.T) {
+		d := device(LayoutUS, []inputEvent{{Type: 0x04, Code: 4, Value: 458756}}, keyEvents(30))
+		out, _ := ioutil.ReadAll(d)
+		assert.Equal(t, "a", string(out))
+	})
+	t.Run("returns eof at the end", func(t *testing.T) {
+		d := device(LayoutUS)
+		_, err := d.Read(make([]byte, 8))
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestLayoutByName(t *testing.T) {
+	t.Run("returns named layout", func(t *testing.T) {
+		layout, err := LayoutByName("de")
+		assert.NoError(t, err)
+		assert.Equal(t, "de", layout.Name)
+	})
+	t.Run("defaults to us", func(t *testing.T) {
+		layout, err := LayoutByName("")
+		assert.NoError(t, err)
+		assert.Equal(t, "us", layout.Name)
+	})
+	t.Run("returns error for undefined layout", func(t *testing.T) {
+		_, err := LayoutByName("x")
+		assert.Error(t, err)
+	})
+}
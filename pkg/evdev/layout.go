@@ -0,0 +1,171 @@
+package evdev
+
+import (
+	"fmt"
+	"unicode"
+)
+
+type key struct {
+	normal, shift, altGr rune
+}
+
+// A Layout maps key codes to characters.
+type Layout struct {
+	Name string
+	keys map[uint16]key
+}
+
+func (l Layout) char(code uint16, shift, altGr, capsLock bool) (rune, bool) {
+	k, ok := l.keys[code]
+	if !ok {
+		return 0, false
+	}
+
+	r := k.normal
+	switch {
+	case altGr:
+		r = k.altGr
+	case shift:
+		r = k.shift
+	}
+	if r == 0 {
+		return 0, false
+	}
+
+	if capsLock && !altGr && unicode.IsLetter(k.normal) {
+		if shift {
+			r = unicode.ToLower(r)
+		} else {
+			r = unicode.ToUpper(r)
+		}
+	}
+
+	return r, true
+}
+
+// LayoutByName returns the keyboard layout identified by name.
+func LayoutByName(name string) (Layout, error) {
+	switch name {
+	case "", "us":
+		return LayoutUS, nil
+	case "de":
+		return LayoutDE, nil
+	}
+	return Layout{}, fmt.Errorf("undefined keyboard layout: %s", name)
+}
+
+// keys shared by all layouts, e.g. the numeric keypad.
+var commonKeys = map[uint16]key{
+	15: {'\t', '\t', 0},
+	28: {'\n', '\n', 0},
+	57: {' ', ' ', 0},
+	55: {'*', '*', 0},
+	71: {'7', '7', 0},
+	72: {'8', '8', 0},
+	73: {'9', '9', 0},
+	74: {'-', '-', 0},
+	75: {'4', '4', 0},
+	76: {'5', '5', 0},
+	77: {'6', '6', 0},
+	78: {'+', '+', 0},
+	79: {'1', '1', 0},
+	80: {'2', '2', 0},
+	81: {'3', '3', 0},
+	82: {'0', '0', 0},
+	83: {'.', '.', 0},
+	96: {'\n', '\n', 0},
+	98: {'/', '/', 0},
+}
+
+func newLayout(name string, keys map[uint16]key) Layout {
+	l := Layout{Name: name, keys: map[uint16]key{}}
+	for code, k := range commonKeys {
+		l.keys[code] = k
+	}
+	for code, k := range keys {
+		l.keys[code] = k
+	}
+	return l
+}
+
+func letters(codes string, row uint16) map[uint16]key {
+	keys := map[uint16]key{}
+	for i, r := range codes {
+		keys[row+uint16(i)] = key{r, unicode.ToUpper(r), 0}
+	}
+	return keys
+}
+
+func merge(maps ...map[uint16]key) map[uint16]key {
+	keys := map[uint16]key{}
+	for _, m := range maps {
+		for code, k := range m {
+			keys[code] = k
+		}
+	}
+	return keys
+}
+
+// LayoutUS is the US (QWERTY) keyboard layout.
+var LayoutUS = newLayout("us", merge(
+	letters("qwertyuiop", 16),
+	letters("asdfghjkl", 30),
+	letters("zxcvbnm", 44),
+	map[uint16]key{
+		2:  {'1', '!', 0},
+		3:  {'2', '@', 0},
+		4:  {'3', '#', 0},
+		5:  {'4', '$', 0},
+		6:  {'5', '%', 0},
+		7:  {'6', '^', 0},
+		8:  {'7', '&', 0},
+		9:  {'8', '*', 0},
+		10: {'9', '(', 0},
+		11: {'0', ')', 0},
+		12: {'-', '_', 0},
+		13: {'=', '+', 0},
+		26: {'[', '{', 0},
+		27: {']', '}', 0},
+		39: {';', ':', 0},
+		40: {'\'', '"', 0},
+		41: {'`', '~', 0},
+		43: {'\\', '|', 0},
+		51: {',', '<', 0},
+		52: {'.', '>', 0},
+		53: {'/', '?', 0},
+	},
+))
+
+// LayoutDE is the German (QWERTZ) keyboard layout.
+var LayoutDE = newLayout("de", merge(
+	letters("qwertzuiop", 16),
+	letters("asdfghjkl", 30),
+	letters("yxcvbnm", 44),
+	map[uint16]key{
+		2:  {'1', '!', 0},
+		3:  {'2', '"', '²'},
+		4:  {'3', '§', '³'},
+		5:  {'4', '$', 0},
+		6:  {'5', '%', 0},
+		7:  {'6', '&', 0},
+		8:  {'7', '/', '{'},
+		9:  {'8', '(', '['},
+		10: {'9', ')', ']'},
+		11: {'0', '=', '}'},
+		12: {'ß', '?', '\\'},
+		13: {'´', '`', 0},
+		16: {'q', 'Q', '@'},
+		18: {'e', 'E', '€'},
+		26: {'ü', 'Ü', 0},
+		27: {'+', '*', '~'},
+		39: {'ö', 'Ö', 0},
+		40: {'ä', 'Ä', 0},
+		41: {'^', '°', 0},
+		43: {'#', '\'', 0},
+		50: {'m', 'M', 'µ'},
+		51: {',', ';', 0},
+		52: {'.', ':', 0},
+		53: {'-', '_', 0},
+		86: {'<', '>', '|'},
+	},
+))
@@ -0,0 +1,91 @@
+// Package feedback signals the outcome of scan requests at the scanner the
+// token was read from.
+package feedback
+
+import (
+	"log"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+)
+
+// A Notifier listens for state changes of scan requests and sends good-read
+// or bad-read feedback to the originating scanner.
+type Notifier struct {
+	scanners        map[string]scanner.Feedback
+	dataChan        chan interface{}
+	shutdownChannel chan struct{}
+
+	// handling is the token of the scan request being handled, if any.
+	handling string
+}
+
+// NewNotifier creates a notifier for the named scanners.
+func NewNotifier(scanners map[string]scanner.Feedback, dataChan chan interface{}, shutdownChannel chan struct{}) *Notifier {
+	return &Notifier{
+		scanners:        scanners,
+		dataChan:        dataChan,
+		shutdownChannel: shutdownChannel,
+	}
+}
+
+// Listen sends feedback for scan requests entering the gating or error state,
+// and bad-read feedback for rejected scan requests. Tokens scanned again while
+// being handled get no feedback, the outcome of the first scan is signaled.
+func (n *Notifier) Listen() {
+	for {
+		select {
+		case fsmData := <-n.dataChan:
+			switch data := fsmData.(type) {
+			case agent.FsmScanRequest:
+				n.notify(data)
+			case agent.RejectedScanRequest:
+				n.reject(data)
+			}
+		case <-n.shutdownChannel:
+			return
+		}
+	}
+}
+
+func (n *Notifier) notify(r agent.FsmScanRequest) {
+	if r.State == agent.StateIdle {
+		n.handling = ""
+	} else {
+		n.handling = r.ScanRequest.Token()
+	}
+
+	s, ok := n.scanners[r.ScanRequest.ScannerName()]
+	if !ok {
+		return
+	}
+
+	var err error
+	switch r.State {
+	case agent.StateGating:
+		err = s.Good()
+	case agent.StateError:
+		err = s.Bad()
+	default:
+		return
+	}
+
+	if err != nil {
+		log.Printf("Failed to send %s feedback to scanner %s: %v", r.State, r.ScanRequest.ScannerName(), err)
+	}
+}
+
+func (n *Notifier) reject(r agent.RejectedScanRequest) {
+	if n.handling != "" && r.ScanRequest.Token() == n.handling {
+		return
+	}
+
+	s, ok := n.scanners[r.ScanRequest.ScannerName()]
+	if !ok {
+		return
+	}
+
+	if err := s.Bad(); err != nil {
+		log.Printf("Failed to send rejected feedback to scanner %s: %v", r.ScanRequest.ScannerName(), err)
+	}
+}
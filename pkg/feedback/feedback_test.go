@@ -0,0 +1,101 @@
+package feedback
+
+import (
+	"testing"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+type DummyFeedback struct {
+	calls []string
+}
+
+func (f *DummyFeedback) Good() error {
+	f.calls = append(f.calls, "good")
+	return nil
+}
+
+func (f *DummyFeedback) Bad() error {
+	f.calls = append(f.calls, "bad")
+	return nil
+}
+
+func fsmScanRequest(scannerName, state string) agent.FsmScanRequest {
+	return agent.FsmScanRequest{
+		ScanRequest: agent.NewScanRequest("", 0, agent.PurposeEntry, *scanner.NewToken("token", scannerName)),
+		State:       state,
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	t.Run("sends feedback for outcome of scan requests", func(t *testing.T) {
+		s1 := &DummyFeedback{}
+		s2 := &DummyFeedback{}
+		dataChan := make(chan interface{})
+		shutdownChan := make(chan struct{})
+		n := NewNotifier(map[string]scanner.Feedback{"1": s1, "2": s2}, dataChan, shutdownChan)
+
+		done := make(chan struct{})
+		go func() {
+			n.Listen()
+			close(done)
+		}()
+
+		for _, state := range []string{agent.StateValidating, agent.StatePrinting, agent.StateGating, agent.StateIdle} {
+			dataChan <- fsmScanRequest("1", state)
+		}
+		dataChan <- fsmScanRequest("2", agent.StateError)
+		dataChan <- fsmScanRequest("unknown", agent.StateError)
+		close(shutdownChan)
+		<-done
+
+		assert.Equal(t, []string{"good"}, s1.calls)
+		assert.Equal(t, []string{"bad"}, s2.calls)
+	})
+}
+
+func TestNotifier_Rejected(t *testing.T) {
+	t.Run("sends bad feedback for rejected scan requests", func(t *testing.T) {
+		s1 := &DummyFeedback{}
+		dataChan := make(chan interface{})
+		shutdownChan := make(chan struct{})
+		n := NewNotifier(map[string]scanner.Feedback{"1": s1}, dataChan, shutdownChan)
+
+		done := make(chan struct{})
+		go func() {
+			n.Listen()
+			close(done)
+		}()
+
+		dataChan <- agent.RejectedScanRequest{ScanRequest: fsmScanRequest("1", "").ScanRequest}
+		dataChan <- agent.QueueStatus{Depth: 1, Size: 3}
+		close(shutdownChan)
+		<-done
+
+		assert.Equal(t, []string{"bad"}, s1.calls)
+	})
+	t.Run("sends no feedback for tokens scanned again while handled", func(t *testing.T) {
+		s1 := &DummyFeedback{}
+		dataChan := make(chan interface{})
+		shutdownChan := make(chan struct{})
+		n := NewNotifier(map[string]scanner.Feedback{"1": s1}, dataChan, shutdownChan)
+
+		done := make(chan struct{})
+		go func() {
+			n.Listen()
+			close(done)
+		}()
+
+		dataChan <- fsmScanRequest("1", agent.StateValidating)
+		dataChan <- agent.RejectedScanRequest{ScanRequest: fsmScanRequest("1", "").ScanRequest}
+		dataChan <- fsmScanRequest("1", agent.StateGating)
+		dataChan <- fsmScanRequest("1", agent.StateIdle)
+		dataChan <- agent.RejectedScanRequest{ScanRequest: fsmScanRequest("1", "").ScanRequest}
+		close(shutdownChan)
+		<-done
+
+		assert.Equal(t, []string{"good", "bad"}, s1.calls)
+	})
+}
@@ -1,6 +1,7 @@
 package gatecontrol
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,27 +12,49 @@ import (
 	"github.com/streadway/amqp"
 )
 
-const (
-	timeout = 5 * time.Second
-)
+// Timeout is the time Gate-Control has to reply to a command.
+const Timeout = 5 * time.Second
 
 var (
-	errTimedOut = errors.New("timed out waiting for reply")
+	// ErrTimedOut is returned when Gate-Control did not reply in time.
+	ErrTimedOut = errors.New("timed out waiting for reply")
+	// ErrUnreachable is returned when a command could not be sent to
+	// Gate-Control.
+	ErrUnreachable = errors.New("gate-control is unreachable")
 )
 
-// A PermissionValidator validates a token.
+// IsUnavailable reports whether err means that Gate-Control could not be
+// asked, as opposed to an answer denying the request.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrTimedOut) || errors.Is(err, ErrUnreachable)
+}
+
+// A Permission is the result of validating a token.
+type Permission struct {
+	Permitted bool
+	// Details about the permission, e.g. the container to pick up, to be
+	// printed on the interchange ticket.
+	Details map[string]string
+}
+
+// A PermissionValidator validates a token. Validation is abandoned when ctx is
+// done.
 type PermissionValidator interface {
-	ValidateEntry(location string, loadingplace int64, token string, scanSource string) (bool, error)
-	ValidateExit(location string, loadingplace int64, token string, scanSource string) (bool, error)
+	ValidateEntry(ctx context.Context, location string, loadingplace int64, token string, scanSource string) (Permission, error)
+	ValidateExit(ctx context.Context, location string, loadingplace int64, token string, scanSource string) (Permission, error)
 }
 
-// A ProcessNotifier notifies about the process of a vehicle for token.
+// A ProcessNotifier notifies about the process of a vehicle for token. The
+// notification is abandoned when ctx is done.
 type ProcessNotifier interface {
-	GatedIn(location string, loadingplace int64, token string, scanSource string) error
-	GatedOut(location string, loadingplace int64, token string, scanSource string) error
+	GatedIn(ctx context.Context, location string, loadingplace int64, token string, scanSource string) error
+	GatedOut(ctx context.Context, location string, loadingplace int64, token string, scanSource string) error
 }
 
-// A Client acts as a command sender and receiver for Gate-Control.
+// A Client acts as a command sender and receiver for Gate-Control. Replies
+// are received on the channel of the client and replies to other commands are
+// dropped, so a client must not send commands concurrently, e.g. for several
+// lanes.
 type Client struct {
 	ch         *chamqp.Channel
 	replyQueue <-chan amqp.Delivery
@@ -48,37 +71,37 @@ func NewClient(conn *chamqp.Connection) *Client {
 
 // ValidateEntry implements the PermissionValidator interface. It sends a
 // validate entry permission command to Gate-Control and returns the result.
-func (c *Client) ValidateEntry(location string, loadingplace int64, token string, scanSource string) (bool, error) {
-	return c.validate(validateEntry, permissionRequest{location, loadingplace, token, scanSource})
+func (c *Client) ValidateEntry(ctx context.Context, location string, loadingplace int64, token string, scanSource string) (Permission, error) {
+	return c.validate(ctx, validateEntry, permissionRequest{location, loadingplace, token, scanSource})
 }
 
 // ValidateExit implements the PermissionValidator interface. It sends a
 // validate exit permission command to Gate-Control and returns the result.
-func (c *Client) ValidateExit(location string, loadingplace int64, token string, scanSource string) (bool, error) {
-	return c.validate(validateExit, permissionRequest{location, loadingplace, token, scanSource})
+func (c *Client) ValidateExit(ctx context.Context, location string, loadingplace int64, token string, scanSource string) (Permission, error) {
+	return c.validate(ctx, validateExit, permissionRequest{location, loadingplace, token, scanSource})
 }
 
 // GatedIn implements the ProcessNotifier interface. It sends an use entry
 // permission command to Gate-Control that actually triggers a Gate In.
-func (c *Client) GatedIn(location string, loadingplace int64, token string, scanSource string) error {
-	_, err := c.use(useEntry, permissionRequest{location, loadingplace, token, scanSource})
+func (c *Client) GatedIn(ctx context.Context, location string, loadingplace int64, token string, scanSource string) error {
+	_, err := c.use(ctx, useEntry, permissionRequest{location, loadingplace, token, scanSource})
 	return err
 }
 
 // GatedOut implements the ProcessNotifier interface. It sends an use exit
 // permission command to Gate-Control that actually triggers a Gate Out.
-func (c *Client) GatedOut(location string, loadingplace int64, token string, scanSource string) error {
-	_, err := c.use(useExit, permissionRequest{location, loadingplace, token, scanSource})
+func (c *Client) GatedOut(ctx context.Context, location string, loadingplace int64, token string, scanSource string) error {
+	_, err := c.use(ctx, useExit, permissionRequest{location, loadingplace, token, scanSource})
 	return err
 }
 
-func (c *Client) validate(purpose validatePurpose, req permissionRequest) (bool, error) {
+func (c *Client) validate(ctx context.Context, purpose validatePurpose, req permissionRequest) (Permission, error) {
 	log.Printf("gatecontrol: Send validate permission command for token %s (%s)",
 		req.Token, purpose.Rk())
 
 	payload, err := json.Marshal(req)
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal json: %v", err)
+		return Permission{}, fmt.Errorf("failed to marshal json: %v", err)
 	}
 
 	msg := amqp.Publishing{
@@ -94,10 +117,10 @@ func (c *Client) validate(purpose validatePurpose, req permissionRequest) (bool,
 
 	err = c.ch.Publish("gatecontrol.terminalpermission.command", purpose.Rk(), false, false, msg)
 	if err != nil {
-		return false, err
+		return Permission{}, fmt.Errorf("%w: %v", ErrUnreachable, err)
 	}
 
-	timer := time.NewTimer(timeout)
+	timer := time.NewTimer(Timeout)
 	for {
 		select {
 		case reply := <-c.replyQueue:
@@ -114,14 +137,16 @@ func (c *Client) validate(purpose validatePurpose, req permissionRequest) (bool,
 			if response.Message != nil {
 				err = errors.New(response.Message.MessageCode)
 			}
-			return response.Permitted, err
+			return Permission{Permitted: response.Permitted, Details: response.Details}, err
 		case <-timer.C:
-			return false, errTimedOut
+			return Permission{}, ErrTimedOut
+		case <-ctx.Done():
+			return Permission{}, ctx.Err()
 		}
 	}
 }
 
-func (c *Client) use(purpose usePurpose, req permissionRequest) (bool, error) {
+func (c *Client) use(ctx context.Context, purpose usePurpose, req permissionRequest) (bool, error) {
 	log.Printf("gatecontrol: Send use permission command for token %s (%s)",
 		req.Token, purpose.Rk())
 
@@ -143,10 +168,10 @@ func (c *Client) use(purpose usePurpose, req permissionRequest) (bool, error) {
 
 	err = c.ch.Publish("gatecontrol.terminalpermission.command", purpose.Rk(), false, false, msg)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("%w: %v", ErrUnreachable, err)
 	}
 
-	timer := time.NewTimer(timeout)
+	timer := time.NewTimer(Timeout)
 	for {
 		select {
 		case reply := <-c.replyQueue:
@@ -165,7 +190,9 @@ func (c *Client) use(purpose usePurpose, req permissionRequest) (bool, error) {
 			}
 			return response.Permitted, err
 		case <-timer.C:
-			return false, errTimedOut
+			return false, ErrTimedOut
+		case <-ctx.Done():
+			return false, ctx.Err()
 		}
 	}
 }
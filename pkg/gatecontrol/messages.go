@@ -12,8 +12,9 @@ type message struct {
 }
 
 type permissionResponse struct {
-	Permitted bool     `json:"permitted"`
-	Message   *message `json:"message"`
+	Permitted bool              `json:"permitted"`
+	Message   *message          `json:"message"`
+	Details   map[string]string `json:"details"`
 }
 
 type validatePurpose int
@@ -0,0 +1,53 @@
+// Package gatedriver actuates gates, either through shell commands or by
+// switching relays of I/O modules, relay boards and GPIO lines.
+package gatedriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// An Action is something a driver does with a gate.
+type Action string
+
+const (
+	// ActionOpen opens the gate.
+	ActionOpen Action = "open"
+	// ActionClose closes the gate.
+	ActionClose Action = "close"
+	// ActionStatus reads the state of the gate.
+	ActionStatus Action = "status"
+)
+
+// ErrNotSupported is returned for actions a driver is not configured for.
+var ErrNotSupported = errors.New("action not supported")
+
+// A DriverError is returned when a driver fails to perform an action.
+type DriverError struct {
+	Driver string
+	Action Action
+	Err    error
+}
+
+func (e *DriverError) Error() string {
+	return fmt.Sprintf("%s driver failed to %s gate: %v", e.Driver, e.Action, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *DriverError) Unwrap() error {
+	return e.Err
+}
+
+// A Driver actuates a gate.
+type Driver interface {
+	// Open opens the gate.
+	Open(ctx context.Context) error
+	// Close closes the gate.
+	Close(ctx context.Context) error
+	// Status returns the state of the gate, i.e. open, closed, moving or
+	// fault.
+	Status(ctx context.Context) (string, error)
+	// Supports reports whether the driver is able to perform action.
+	Supports(action Action) bool
+}
@@ -0,0 +1,34 @@
+package gatedriver
+
+import (
+	"context"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gpio"
+)
+
+type gpioLine struct {
+	line      *gpio.Line
+	activeLow bool
+}
+
+// GPIOLine connects to line of a Linux GPIO character device, e.g.
+// /dev/gpiochip0. Relays of active low lines are switched on by driving the
+// line low. Latched relays keep the line requested while on, since the kernel
+// may reset lines once released.
+func GPIOLine(chip string, line uint32, activeLow bool) SwitchOpener {
+	return SwitchOpener(func() (Switch, error) {
+		l, err := gpio.RequestOutput(chip, line, activeLow)
+		if err != nil {
+			return nil, err
+		}
+		return &gpioLine{line: l, activeLow: activeLow}, nil
+	})
+}
+
+func (g *gpioLine) Switch(ctx context.Context, on bool) error {
+	return g.line.SetValue(on != g.activeLow)
+}
+
+func (g *gpioLine) Close() error {
+	return g.line.Close()
+}
@@ -0,0 +1,56 @@
+package gatedriver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type httpRelay struct {
+	client *http.Client
+	method string
+	url    string
+	relay  int
+}
+
+// HTTPRelay switches a relay of an HTTP relay board. The placeholders {relay}
+// and {state} in url are replaced by the number of the relay and 1 or 0 for
+// on or off, e.g. http://relays/relay.cgi?relay={relay}&state={state}.
+// Responses other than 2xx fail.
+func HTTPRelay(client *http.Client, method, url string, relay int) SwitchOpener {
+	return SwitchOpener(func() (Switch, error) {
+		return &httpRelay{client: client, method: method, url: url, relay: relay}, nil
+	})
+}
+
+func (h *httpRelay) Switch(ctx context.Context, on bool) error {
+	state := "0"
+	if on {
+		state = "1"
+	}
+	url := strings.NewReplacer("{relay}", strconv.Itoa(h.relay), "{state}", state).Replace(h.url)
+
+	req, err := http.NewRequest(h.method, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("relay board answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *httpRelay) Close() error {
+	return nil
+}
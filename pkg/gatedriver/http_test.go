@@ -0,0 +1,45 @@
+package gatedriver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPRelay(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	board := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Query().Get("relay") == "9" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer board.Close()
+
+	t.Run("switches relay", func(t *testing.T) {
+		s, err := HTTPRelay(board.Client(), "POST", board.URL+"/relay?relay={relay}&state={state}", 2)()
+		assert.NoError(t, err)
+
+		assert.NoError(t, s.Switch(context.Background(), true))
+		assert.NoError(t, s.Switch(context.Background(), false))
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"POST /relay?relay=2&state=1", "POST /relay?relay=2&state=0"}, requests)
+	})
+	t.Run("fails on error responses", func(t *testing.T) {
+		s, err := HTTPRelay(board.Client(), "GET", board.URL+"/relay?relay={relay}&state={state}", 9)()
+		assert.NoError(t, err)
+
+		assert.EqualError(t, s.Switch(context.Background(), true), "relay board answered 404 Not Found")
+	})
+}
@@ -0,0 +1,32 @@
+package gatedriver
+
+import (
+	"context"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/modbus"
+)
+
+type modbusCoil struct {
+	client *modbus.Client
+	coil   uint16
+}
+
+// ModbusCoil connects to the coil of the unit of the Modbus TCP module at
+// address.
+func ModbusCoil(address string, unit byte, coil uint16) SwitchOpener {
+	return SwitchOpener(func() (Switch, error) {
+		client, err := modbus.Dial(address, unit, modbus.DefaultTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return &modbusCoil{client: client, coil: coil}, nil
+	})
+}
+
+func (m *modbusCoil) Switch(ctx context.Context, on bool) error {
+	return m.client.WriteSingleCoil(m.coil, on)
+}
+
+func (m *modbusCoil) Close() error {
+	return m.client.Close()
+}
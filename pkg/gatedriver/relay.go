@@ -0,0 +1,199 @@
+package gatedriver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/input"
+)
+
+// DefaultPulse is the time a relay is switched on to open or close a gate,
+// unless configured otherwise.
+const DefaultPulse = 500 * time.Millisecond
+
+// A Switch is a digital output switching a relay.
+type Switch interface {
+	Switch(ctx context.Context, on bool) error
+	Close() error
+}
+
+// A SwitchOpener connects to a switch.
+type SwitchOpener func() (Switch, error)
+
+// Limits are the limit switches of a gate, active while the gate is fully
+// opened or closed respectively.
+type Limits struct {
+	Opened input.Opener
+	Closed input.Opener
+}
+
+// Relays drives a gate by switching relays. The open relay is pulsed to open
+// the gate, the close relay, if any, to close it. Without pulse, the relays
+// are latched instead: the open relay is switched on to open and off to close
+// the gate, the close relay the other way round, so never both are on.
+// Switches are connected for every pulse and stay connected while latched on.
+type Relays struct {
+	name   string
+	open   SwitchOpener
+	close  SwitchOpener
+	pulse  time.Duration
+	limits *Limits
+
+	mu sync.Mutex
+	// openOn and closeOn are the switches latched on.
+	openOn  Switch
+	closeOn Switch
+}
+
+// NewRelays creates a driver named name switching the relays connected by
+// open and close, which may be nil. The state of the gate is read from
+// limits, if not nil.
+func NewRelays(name string, open, close SwitchOpener, pulse time.Duration, limits *Limits) *Relays {
+	return &Relays{name: name, open: open, close: close, pulse: pulse, limits: limits}
+}
+
+// Open implements the Driver interface.
+func (r *Relays) Open(ctx context.Context) error {
+	var err error
+	switch {
+	case r.pulse > 0:
+		err = r.pulseSwitch(ctx, r.open)
+	case r.close != nil:
+		err = r.latch(ctx, r.close, &r.closeOn, false)
+		if err == nil {
+			err = r.latch(ctx, r.open, &r.openOn, true)
+		}
+	default:
+		err = r.latch(ctx, r.open, &r.openOn, true)
+	}
+	if err != nil {
+		return &DriverError{Driver: r.name, Action: ActionOpen, Err: err}
+	}
+	return nil
+}
+
+// Close implements the Driver interface.
+func (r *Relays) Close(ctx context.Context) error {
+	var err error
+	switch {
+	case r.close != nil:
+		if r.pulse > 0 {
+			err = r.pulseSwitch(ctx, r.close)
+		} else {
+			err = r.latch(ctx, r.open, &r.openOn, false)
+			if err == nil {
+				err = r.latch(ctx, r.close, &r.closeOn, true)
+			}
+		}
+	case r.pulse <= 0:
+		err = r.latch(ctx, r.open, &r.openOn, false)
+	default:
+		err = ErrNotSupported
+	}
+	if err != nil {
+		return &DriverError{Driver: r.name, Action: ActionClose, Err: err}
+	}
+	return nil
+}
+
+// Status implements the Driver interface. It requires limit switches.
+func (r *Relays) Status(ctx context.Context) (string, error) {
+	if r.limits == nil {
+		return "", &DriverError{Driver: r.name, Action: ActionStatus, Err: ErrNotSupported}
+	}
+	opened, err := readLimit(r.limits.Opened)
+	if err != nil {
+		return "", &DriverError{Driver: r.name, Action: ActionStatus, Err: err}
+	}
+	closed, err := readLimit(r.limits.Closed)
+	if err != nil {
+		return "", &DriverError{Driver: r.name, Action: ActionStatus, Err: err}
+	}
+
+	switch {
+	case opened && closed:
+		return "fault", nil
+	case opened:
+		return "open", nil
+	case closed:
+		return "closed", nil
+	}
+	return "moving", nil
+}
+
+// Supports implements the Driver interface.
+func (r *Relays) Supports(action Action) bool {
+	switch action {
+	case ActionClose:
+		return r.close != nil || r.pulse <= 0
+	case ActionStatus:
+		return r.limits != nil
+	}
+	return true
+}
+
+// pulseSwitch switches the relay on for the pulse length. The relay is
+// switched off even if ctx is done meanwhile.
+func (r *Relays) pulseSwitch(ctx context.Context, open SwitchOpener) error {
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := s.Switch(ctx, true); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(r.pulse)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	if err := s.Switch(context.Background(), false); err != nil {
+		return fmt.Errorf("relay stuck on: %w", err)
+	}
+	return ctx.Err()
+}
+
+// latch switches the relay connected by open on or off. The switch stays
+// connected in held while the relay is on, e.g. so GPIO lines are not
+// released.
+func (r *Relays) latch(ctx context.Context, open SwitchOpener, held *Switch, on bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := *held
+	if s == nil {
+		var err error
+		if s, err = open(); err != nil {
+			return err
+		}
+	}
+	if err := s.Switch(ctx, on); err != nil {
+		if *held == nil {
+			s.Close()
+		}
+		return err
+	}
+
+	if on {
+		*held = s
+		return nil
+	}
+	*held = nil
+	return s.Close()
+}
+
+func readLimit(open input.Opener) (bool, error) {
+	in, err := open()
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	return in.Active()
+}
@@ -0,0 +1,171 @@
+package gatedriver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/input"
+	"github.com/stretchr/testify/assert"
+)
+
+// RecordingSwitch records the states it is switched to and counts its open
+// connections.
+type RecordingSwitch struct {
+	mu        sync.Mutex
+	states    []bool
+	err       error
+	connected int
+}
+
+func (r *RecordingSwitch) Opener() SwitchOpener {
+	return SwitchOpener(func() (Switch, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.connected++
+		return r, nil
+	})
+}
+
+func (r *RecordingSwitch) Switch(ctx context.Context, on bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.states = append(r.states, on)
+	return nil
+}
+
+func (r *RecordingSwitch) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connected--
+	return nil
+}
+
+func (r *RecordingSwitch) Connected() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connected
+}
+
+func (r *RecordingSwitch) States() []bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.states
+}
+
+type staticInput bool
+
+func (s staticInput) Active() (bool, error) { return bool(s), nil }
+func (s staticInput) Close() error          { return nil }
+
+func staticOpener(active bool) input.Opener {
+	return input.Opener(func() (input.Input, error) {
+		return staticInput(active), nil
+	})
+}
+
+func TestRelays(t *testing.T) {
+	t.Run("pulses relays", func(t *testing.T) {
+		open, close := &RecordingSwitch{}, &RecordingSwitch{}
+		r := NewRelays("test", open.Opener(), close.Opener(), time.Millisecond, nil)
+
+		assert.NoError(t, r.Open(context.Background()))
+		assert.Equal(t, []bool{true, false}, open.States())
+		assert.Empty(t, close.States())
+
+		assert.NoError(t, r.Close(context.Background()))
+		assert.Equal(t, []bool{true, false}, close.States())
+		assert.True(t, r.Supports(ActionClose))
+	})
+	t.Run("latches relay without pulse", func(t *testing.T) {
+		open := &RecordingSwitch{}
+		r := NewRelays("test", open.Opener(), nil, 0, nil)
+
+		assert.NoError(t, r.Open(context.Background()))
+		assert.NoError(t, r.Close(context.Background()))
+
+		assert.Equal(t, []bool{true, false}, open.States())
+	})
+	t.Run("latches either relay without pulse", func(t *testing.T) {
+		open, close := &RecordingSwitch{}, &RecordingSwitch{}
+		r := NewRelays("test", open.Opener(), close.Opener(), 0, nil)
+
+		assert.NoError(t, r.Open(context.Background()))
+		assert.Equal(t, 1, open.Connected())
+		assert.NoError(t, r.Close(context.Background()))
+		assert.Equal(t, 0, open.Connected())
+		assert.Equal(t, 1, close.Connected())
+		assert.NoError(t, r.Open(context.Background()))
+
+		assert.Equal(t, []bool{true, false, true}, open.States())
+		assert.Equal(t, []bool{false, true, false}, close.States())
+		assert.Equal(t, 1, open.Connected())
+		assert.Equal(t, 0, close.Connected())
+	})
+	t.Run("cannot close pulsed gates without close relay", func(t *testing.T) {
+		open := &RecordingSwitch{}
+		r := NewRelays("test", open.Opener(), nil, time.Millisecond, nil)
+
+		err := r.Close(context.Background())
+
+		assert.False(t, r.Supports(ActionClose))
+		assert.True(t, errors.Is(err, ErrNotSupported))
+	})
+	t.Run("switches relay off when canceled", func(t *testing.T) {
+		open := &RecordingSwitch{}
+		r := NewRelays("test", open.Opener(), nil, time.Hour, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := r.Open(ctx)
+
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, []bool{true, false}, open.States())
+	})
+	t.Run("returns driver errors", func(t *testing.T) {
+		open := &RecordingSwitch{err: errors.New("connection refused")}
+		r := NewRelays("modbus", open.Opener(), nil, time.Millisecond, nil)
+
+		err := r.Open(context.Background())
+
+		var driverErr *DriverError
+		assert.True(t, errors.As(err, &driverErr))
+		assert.Equal(t, "modbus", driverErr.Driver)
+		assert.Equal(t, ActionOpen, driverErr.Action)
+		assert.EqualError(t, err, "modbus driver failed to open gate: connection refused")
+	})
+	t.Run("reads state from limit switches", func(t *testing.T) {
+		for _, c := range []struct {
+			opened, closed bool
+			state          string
+		}{
+			{true, false, "open"},
+			{false, true, "closed"},
+			{false, false, "moving"},
+			{true, true, "fault"},
+		} {
+			r := NewRelays("test", (&RecordingSwitch{}).Opener(), nil, 0, &Limits{
+				Opened: staticOpener(c.opened),
+				Closed: staticOpener(c.closed),
+			})
+
+			state, err := r.Status(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.state, state)
+		}
+	})
+	t.Run("cannot read state without limit switches", func(t *testing.T) {
+		r := NewRelays("test", (&RecordingSwitch{}).Opener(), nil, 0, nil)
+
+		_, err := r.Status(context.Background())
+
+		assert.False(t, r.Supports(ActionStatus))
+		assert.True(t, errors.Is(err, ErrNotSupported))
+	})
+}
@@ -0,0 +1,40 @@
+package gatedriver
+
+import "context"
+
+// A Trigger tells why a gate is opened.
+type Trigger string
+
+const (
+	// TriggerScan opens the gate for a permitted scan.
+	TriggerScan Trigger = "scan"
+	// TriggerManual opens the gate on request of an operator.
+	TriggerManual Trigger = "manual"
+	// TriggerReentry opens the gate again for the token scanned last.
+	TriggerReentry Trigger = "reentry"
+	// TriggerTest opens the gate for a test cycle requested by an operator.
+	TriggerTest Trigger = "test"
+)
+
+// A Request describes what a gate is actuated for. Drivers may pass it on,
+// e.g. the shell driver to its commands.
+type Request struct {
+	Gate       string
+	Purpose    string
+	Token      string
+	ScanSource string
+	Trigger    Trigger
+}
+
+type requestKey struct{}
+
+// NewContext returns a copy of ctx carrying req.
+func NewContext(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// FromContext returns the request carried by ctx, if any.
+func FromContext(ctx context.Context) (Request, bool) {
+	req, ok := ctx.Value(requestKey{}).(Request)
+	return req, ok
+}
@@ -0,0 +1,38 @@
+package gatedriver
+
+import (
+	"context"
+	"io"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+)
+
+type serialRelay struct {
+	port    io.ReadWriteCloser
+	on, off []byte
+}
+
+// SerialRelay switches a relay of a serial relay card by sending the on and
+// off commands to the card on device.
+func SerialRelay(device string, opts scanner.SerialOptions, on, off []byte) SwitchOpener {
+	return SwitchOpener(func() (Switch, error) {
+		port, err := scanner.OpenSerialPort(device, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &serialRelay{port: port, on: on, off: off}, nil
+	})
+}
+
+func (s *serialRelay) Switch(ctx context.Context, on bool) error {
+	cmd := s.off
+	if on {
+		cmd = s.on
+	}
+	_, err := s.port.Write(cmd)
+	return err
+}
+
+func (s *serialRelay) Close() error {
+	return s.port.Close()
+}
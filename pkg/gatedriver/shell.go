@@ -0,0 +1,175 @@
+package gatedriver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultCommandTimeout is the time a command has to complete, unless
+// configured otherwise.
+const DefaultCommandTimeout = 30 * time.Second
+
+// A CommandError is returned for commands that could not be run, failed or
+// timed out.
+type CommandError struct {
+	Command string
+	// ExitCode is the exit code of the command, or -1 if it did not exit,
+	// e.g. because it was killed.
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	var msg string
+	switch {
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		msg = fmt.Sprintf("command %q timed out", e.Command)
+	case e.ExitCode >= 0:
+		msg = fmt.Sprintf("command %q exited with code %d", e.Command, e.ExitCode)
+	default:
+		msg = fmt.Sprintf("command %q failed: %v", e.Command, e.Err)
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// Unwrap returns the cause of the error.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Shell drives a gate with shell commands, e.g. helper scripts. The request
+// the gate is actuated for is passed to the commands in the environment
+// variables GATE_NAME, GATE_PURPOSE, GATE_TOKEN, GATE_SCAN_SOURCE and
+// GATE_TRIGGER, i.e. scan, manual, reentry or test.
+type Shell struct {
+	// OpenCmd opens the gate.
+	OpenCmd string
+	// CloseCmd closes the gate, if set.
+	CloseCmd string
+	// StatusCmd prints the state of the gate, if set.
+	StatusCmd string
+	// Timeout is the time a command has to complete, zero for no limit.
+	// Commands are killed with all their child processes on timeout.
+	Timeout time.Duration
+}
+
+// Open implements the Driver interface.
+func (s *Shell) Open(ctx context.Context) error {
+	if _, err := s.run(ctx, ActionOpen, s.OpenCmd); err != nil {
+		return &DriverError{Driver: "shell", Action: ActionOpen, Err: err}
+	}
+	return nil
+}
+
+// Close implements the Driver interface.
+func (s *Shell) Close(ctx context.Context) error {
+	if s.CloseCmd == "" {
+		return &DriverError{Driver: "shell", Action: ActionClose, Err: ErrNotSupported}
+	}
+	if _, err := s.run(ctx, ActionClose, s.CloseCmd); err != nil {
+		return &DriverError{Driver: "shell", Action: ActionClose, Err: err}
+	}
+	return nil
+}
+
+// Status implements the Driver interface.
+func (s *Shell) Status(ctx context.Context) (string, error) {
+	if s.StatusCmd == "" {
+		return "", &DriverError{Driver: "shell", Action: ActionStatus, Err: ErrNotSupported}
+	}
+	out, err := s.run(ctx, ActionStatus, s.StatusCmd)
+	if err != nil {
+		return "", &DriverError{Driver: "shell", Action: ActionStatus, Err: err}
+	}
+	return out, nil
+}
+
+// Supports implements the Driver interface.
+func (s *Shell) Supports(action Action) bool {
+	switch action {
+	case ActionClose:
+		return s.CloseCmd != ""
+	case ActionStatus:
+		return s.StatusCmd != ""
+	}
+	return true
+}
+
+// run runs command in its own process group, which is killed when ctx is
+// done or the command timed out. It returns the output of the command. The
+// output is logged, except for status commands that succeeded, as they are
+// run periodically.
+func (s *Shell) run(ctx context.Context, action Action, command string) (string, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), requestEnv(ctx)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return "", &CommandError{Command: command, ExitCode: -1, Err: err}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		err = ctx.Err()
+	}
+
+	exitCode := cmd.ProcessState.ExitCode()
+	if action != ActionStatus || err != nil {
+		log.Printf("Command %q to %s gate exited with code %d, stdout: %q, stderr: %q",
+			command, action, exitCode, stdout.String(), stderr.String())
+	}
+	if err != nil {
+		return stdout.String(), &CommandError{
+			Command:  command,
+			ExitCode: exitCode,
+			Stdout:   stdout.String(),
+			Stderr:   stderr.String(),
+			Err:      err,
+		}
+	}
+	return stdout.String(), nil
+}
+
+func requestEnv(ctx context.Context) []string {
+	req, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []string{
+		"GATE_NAME=" + req.Gate,
+		"GATE_PURPOSE=" + req.Purpose,
+		"GATE_TOKEN=" + req.Token,
+		"GATE_SCAN_SOURCE=" + req.ScanSource,
+		"GATE_TRIGGER=" + string(req.Trigger),
+	}
+}
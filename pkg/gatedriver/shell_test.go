@@ -0,0 +1,81 @@
+package gatedriver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShell(t *testing.T) {
+	t.Run("runs commands", func(t *testing.T) {
+		s := &Shell{OpenCmd: "/bin/true", CloseCmd: "/bin/true", StatusCmd: "echo open"}
+
+		assert.NoError(t, s.Open(context.Background()))
+		assert.NoError(t, s.Close(context.Background()))
+		state, err := s.Status(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "open\n", state)
+	})
+	t.Run("returns driver errors", func(t *testing.T) {
+		s := &Shell{OpenCmd: "/bin/false"}
+
+		err := s.Open(context.Background())
+
+		var driverErr *DriverError
+		assert.True(t, errors.As(err, &driverErr))
+		assert.Equal(t, ActionOpen, driverErr.Action)
+	})
+	t.Run("supports configured commands only", func(t *testing.T) {
+		s := &Shell{OpenCmd: "/bin/true"}
+
+		assert.True(t, s.Supports(ActionOpen))
+		assert.False(t, s.Supports(ActionClose))
+		assert.False(t, s.Supports(ActionStatus))
+		assert.True(t, errors.Is(s.Close(context.Background()), ErrNotSupported))
+	})
+}
+
+func TestShell_Commands(t *testing.T) {
+	t.Run("passes request in environment", func(t *testing.T) {
+		s := &Shell{StatusCmd: `echo "$GATE_NAME $GATE_PURPOSE $GATE_TOKEN $GATE_SCAN_SOURCE $GATE_TRIGGER"`}
+		ctx := NewContext(context.Background(), Request{
+			Gate:       "entry-1",
+			Purpose:    "entry",
+			Token:      "token",
+			ScanSource: "QR",
+			Trigger:    TriggerReentry,
+		})
+
+		out, err := s.Status(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "entry-1 entry token QR reentry\n", out)
+	})
+	t.Run("captures output and exit code of failed commands", func(t *testing.T) {
+		s := &Shell{OpenCmd: "echo moving; echo jammed >&2; exit 3"}
+
+		err := s.Open(context.Background())
+
+		var cmdErr *CommandError
+		assert.True(t, errors.As(err, &cmdErr))
+		assert.Equal(t, 3, cmdErr.ExitCode)
+		assert.Equal(t, "moving\n", cmdErr.Stdout)
+		assert.Equal(t, "jammed\n", cmdErr.Stderr)
+		assert.EqualError(t, err, `shell driver failed to open gate: command "echo moving; echo jammed >&2; exit 3" exited with code 3: jammed`)
+	})
+	t.Run("kills process group on timeout", func(t *testing.T) {
+		s := &Shell{OpenCmd: "sleep 10 & sleep 10", Timeout: 50 * time.Millisecond}
+
+		start := time.Now()
+		err := s.Open(context.Background())
+
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.True(t, time.Since(start) < 5*time.Second)
+		var cmdErr *CommandError
+		assert.True(t, errors.As(err, &cmdErr))
+		assert.Equal(t, -1, cmdErr.ExitCode)
+	})
+}
@@ -0,0 +1,105 @@
+// Package gpio requests lines of Linux GPIO character devices, e.g.
+// /dev/gpiochip0, as inputs or outputs.
+package gpio
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// gpioGetLineHandle is GPIO_GET_LINEHANDLE_IOCTL,
+	// _IOWR(0xB4, 0x03, struct gpiohandle_request).
+	gpioGetLineHandle = 0xc16cb403
+	// gpioGetLineValues is GPIOHANDLE_GET_LINE_VALUES_IOCTL,
+	// _IOWR(0xB4, 0x08, struct gpiohandle_data).
+	gpioGetLineValues = 0xc040b408
+	// gpioSetLineValues is GPIOHANDLE_SET_LINE_VALUES_IOCTL,
+	// _IOWR(0xB4, 0x09, struct gpiohandle_data).
+	gpioSetLineValues = 0xc040b409
+
+	gpioHandleRequestInput  = 1 << 0
+	gpioHandleRequestOutput = 1 << 1
+)
+
+// gpioHandleRequest is struct gpiohandle_request of the Linux GPIO character
+// device ABI.
+type gpioHandleRequest struct {
+	lineOffsets   [64]uint32
+	flags         uint32
+	defaultValues [64]uint8
+	consumerLabel [32]byte
+	lines         uint32
+	fd            int32
+}
+
+// gpioHandleData is struct gpiohandle_data.
+type gpioHandleData struct {
+	values [64]uint8
+}
+
+// A Line is a requested line of a GPIO chip.
+type Line struct {
+	fd uintptr
+}
+
+// RequestInput requests line of the GPIO chip as input.
+func RequestInput(chip string, line uint32) (*Line, error) {
+	return request(chip, line, gpioHandleRequestInput, false)
+}
+
+// RequestOutput requests line of the GPIO chip as output, set to value.
+func RequestOutput(chip string, line uint32, value bool) (*Line, error) {
+	return request(chip, line, gpioHandleRequestOutput, value)
+}
+
+func request(chip string, line uint32, flags uint32, value bool) (*Line, error) {
+	f, err := os.Open(chip)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	req := gpioHandleRequest{flags: flags, lines: 1}
+	req.lineOffsets[0] = line
+	if value {
+		req.defaultValues[0] = 1
+	}
+	copy(req.consumerLabel[:], "gatecontrol-agent")
+	if err := ioctl(f.Fd(), gpioGetLineHandle, unsafe.Pointer(&req)); err != nil {
+		return nil, &os.PathError{Op: "request line", Path: chip, Err: err}
+	}
+	return &Line{fd: uintptr(req.fd)}, nil
+}
+
+// Value reports whether the line is high.
+func (l *Line) Value() (bool, error) {
+	var data gpioHandleData
+	if err := ioctl(l.fd, gpioGetLineValues, unsafe.Pointer(&data)); err != nil {
+		return false, err
+	}
+	return data.values[0] != 0, nil
+}
+
+// SetValue sets an output line high or low.
+func (l *Line) SetValue(value bool) error {
+	var data gpioHandleData
+	if value {
+		data.values[0] = 1
+	}
+	return ioctl(l.fd, gpioSetLineValues, unsafe.Pointer(&data))
+}
+
+// Close releases the line.
+func (l *Line) Close() error {
+	return syscall.Close(int(l.fd))
+}
+
+func ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
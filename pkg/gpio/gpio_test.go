@@ -0,0 +1,13 @@
+package gpio
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGPIOABI(t *testing.T) {
+	assert.Equal(t, uintptr(364), unsafe.Sizeof(gpioHandleRequest{}))
+	assert.Equal(t, uintptr(64), unsafe.Sizeof(gpioHandleData{}))
+}
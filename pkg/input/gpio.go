@@ -0,0 +1,30 @@
+package input
+
+import (
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gpio"
+)
+
+// A GPIO is an input line of a Linux GPIO character device, e.g.
+// /dev/gpiochip0.
+type GPIO struct {
+	line *gpio.Line
+}
+
+// OpenGPIO requests line of the GPIO chip as input.
+func OpenGPIO(chip string, line uint32) (*GPIO, error) {
+	l, err := gpio.RequestInput(chip, line)
+	if err != nil {
+		return nil, err
+	}
+	return &GPIO{line: l}, nil
+}
+
+// Active implements the Input interface. The line is active while high.
+func (g *GPIO) Active() (bool, error) {
+	return g.line.Value()
+}
+
+// Close releases the line.
+func (g *GPIO) Close() error {
+	return g.line.Close()
+}
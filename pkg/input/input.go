@@ -0,0 +1,121 @@
+// Package input reads digital inputs, e.g. the induction loops and light
+// barriers detecting vehicles passing a gate.
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultPollInterval is the interval inputs are read at while waiting for a
+// passage, unless configured otherwise.
+const DefaultPollInterval = 50 * time.Millisecond
+
+// DefaultPassageTimeout is the time a vehicle has to pass the gate, unless
+// configured otherwise.
+const DefaultPassageTimeout = time.Minute
+
+// ErrNoPassage is returned if no vehicle passed the gate in time.
+var ErrNoPassage = errors.New("gate opened but no vehicle passed")
+
+// An Input is a digital input.
+type Input interface {
+	// Active reports whether the input is active, e.g. a vehicle is on the
+	// induction loop or interrupts the light barrier.
+	Active() (bool, error)
+	Close() error
+}
+
+// An Opener opens an input.
+type Opener func() (Input, error)
+
+// Invert returns an input that is active while in is inactive, e.g. for
+// detectors with normally closed contacts.
+func Invert(in Input) Input {
+	return inverted{in}
+}
+
+type inverted struct {
+	Input
+}
+
+func (i inverted) Active() (bool, error) {
+	active, err := i.Input.Active()
+	return !active, err
+}
+
+// A Detector detects vehicles passing a gate through an input.
+type Detector struct {
+	name     string
+	open     Opener
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// NewDetector creates a detector reading the input opened by open every
+// interval. A vehicle has to pass within timeout.
+func NewDetector(name string, open Opener, interval, timeout time.Duration) *Detector {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultPassageTimeout
+	}
+	return &Detector{name: name, open: open, interval: interval, timeout: timeout}
+}
+
+// Name returns the name of the detector.
+func (d *Detector) Name() string {
+	return d.name
+}
+
+// WaitPassage waits for a vehicle to pass, i.e. for the input to become
+// active and inactive again. A vehicle already on the input when waiting
+// starts passes once it left. Failed reads are logged and retried, the input
+// keeps its last state meanwhile. WaitPassage returns ErrNoPassage if no
+// vehicle passed in time, or the error of ctx if it is done first.
+func (d *Detector) WaitPassage(ctx context.Context) error {
+	in, err := d.open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	timer := time.NewTimer(d.timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	entered := false
+	var readErr error
+	for {
+		active, err := in.Active()
+		switch {
+		case err != nil:
+			if readErr == nil {
+				log.Printf("Failed to read %s, retrying: %v", d.name, err)
+			}
+		case active && !entered:
+			log.Printf("Vehicle entered %s.", d.name)
+			entered = true
+		case !active && entered:
+			log.Printf("Vehicle left %s.", d.name)
+			return nil
+		}
+		readErr = err
+
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			if readErr != nil {
+				return fmt.Errorf("%w: %v", ErrNoPassage, readErr)
+			}
+			return ErrNoPassage
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RecordedInput plays back recorded states, staying in the last one.
+type RecordedInput struct {
+	mu     sync.Mutex
+	states []bool
+	closed bool
+}
+
+func (r *RecordedInput) Active() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.states[0]
+	if len(r.states) > 1 {
+		r.states = r.states[1:]
+	}
+	return state, nil
+}
+
+func (r *RecordedInput) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+// FailingInput fails all reads.
+type FailingInput struct{}
+
+func (FailingInput) Active() (bool, error) { return false, errors.New("read timeout") }
+
+func (FailingInput) Close() error { return nil }
+
+// FlakyInput fails every other read of the recorded input.
+type FlakyInput struct {
+	*RecordedInput
+	reads int
+}
+
+func (f *FlakyInput) Active() (bool, error) {
+	f.reads++
+	if f.reads%2 == 1 {
+		return false, errors.New("read timeout")
+	}
+	return f.RecordedInput.Active()
+}
+
+func RecordedOpener(in *RecordedInput) Opener {
+	return Opener(func() (Input, error) {
+		return in, nil
+	})
+}
+
+func TestDetector_WaitPassage(t *testing.T) {
+	t.Run("detects passage", func(t *testing.T) {
+		in := &RecordedInput{states: []bool{false, false, true, true, false}}
+		d := NewDetector("loop", RecordedOpener(in), time.Millisecond, time.Second)
+
+		assert.NoError(t, d.WaitPassage(context.Background()))
+		assert.True(t, in.closed)
+	})
+	t.Run("waits for vehicle on input to leave", func(t *testing.T) {
+		in := &RecordedInput{states: []bool{true, false}}
+		d := NewDetector("loop", RecordedOpener(in), time.Millisecond, time.Second)
+
+		assert.NoError(t, d.WaitPassage(context.Background()))
+	})
+	t.Run("fails without passage", func(t *testing.T) {
+		in := &RecordedInput{states: []bool{false}}
+		d := NewDetector("loop", RecordedOpener(in), time.Millisecond, 20*time.Millisecond)
+
+		assert.Equal(t, ErrNoPassage, d.WaitPassage(context.Background()))
+	})
+	t.Run("fails if vehicle does not leave", func(t *testing.T) {
+		in := &RecordedInput{states: []bool{false, true}}
+		d := NewDetector("loop", RecordedOpener(in), time.Millisecond, 20*time.Millisecond)
+
+		assert.Equal(t, ErrNoPassage, d.WaitPassage(context.Background()))
+	})
+	t.Run("respects context", func(t *testing.T) {
+		in := &RecordedInput{states: []bool{false}}
+		d := NewDetector("loop", RecordedOpener(in), time.Millisecond, time.Second)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.Equal(t, context.Canceled, d.WaitPassage(ctx))
+	})
+	t.Run("retries failed reads", func(t *testing.T) {
+		in := &FlakyInput{RecordedInput: &RecordedInput{states: []bool{false, true, false}}}
+		d := NewDetector("loop", Opener(func() (Input, error) { return in, nil }), time.Millisecond, time.Second)
+
+		assert.NoError(t, d.WaitPassage(context.Background()))
+	})
+	t.Run("fails without passage if input cannot be read", func(t *testing.T) {
+		d := NewDetector("loop", Opener(func() (Input, error) { return FailingInput{}, nil }), time.Millisecond, 20*time.Millisecond)
+
+		err := d.WaitPassage(context.Background())
+
+		assert.True(t, errors.Is(err, ErrNoPassage))
+		assert.EqualError(t, err, "gate opened but no vehicle passed: read timeout")
+	})
+	t.Run("fails if input cannot be opened", func(t *testing.T) {
+		errOpen := errors.New("no such device")
+		d := NewDetector("loop", Opener(func() (Input, error) { return nil, errOpen }), 0, 0)
+
+		assert.Equal(t, errOpen, d.WaitPassage(context.Background()))
+	})
+}
+
+func TestInvert(t *testing.T) {
+	in := Invert(&RecordedInput{states: []bool{true, false}})
+
+	active, err := in.Active()
+	assert.NoError(t, err)
+	assert.False(t, active)
+	active, err = in.Active()
+	assert.NoError(t, err)
+	assert.True(t, active)
+}
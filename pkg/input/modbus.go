@@ -0,0 +1,35 @@
+package input
+
+import (
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/modbus"
+)
+
+// A ModbusInput is a discrete input of a Modbus TCP I/O module.
+type ModbusInput struct {
+	client  *modbus.Client
+	address uint16
+}
+
+// OpenModbus connects to the discrete input at address of the unit of the
+// module at host.
+func OpenModbus(host string, unit byte, address uint16) (*ModbusInput, error) {
+	client, err := modbus.Dial(host, unit, modbus.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &ModbusInput{client: client, address: address}, nil
+}
+
+// Active implements the Input interface.
+func (m *ModbusInput) Active() (bool, error) {
+	inputs, err := m.client.ReadDiscreteInputs(m.address, 1)
+	if err != nil {
+		return false, err
+	}
+	return inputs[0], nil
+}
+
+// Close closes the connection to the module.
+func (m *ModbusInput) Close() error {
+	return m.client.Close()
+}
@@ -0,0 +1,137 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultResponseTimeout is the time a serial I/O module has to answer a
+// query.
+const DefaultResponseTimeout = time.Second
+
+// ErrNoResponse is returned if a serial I/O module does not answer a query.
+var ErrNoResponse = errors.New("serial I/O module does not answer")
+
+// A SerialQuery reads an input of an ASCII serial I/O module, e.g. "$016\r"
+// answered by "!00FE00" for an ADAM-4050. The first submatch of Response is
+// the state of the inputs in hex, Bit selects the input.
+type SerialQuery struct {
+	Command  []byte
+	Response *regexp.Regexp
+	Bit      uint
+}
+
+// NewSerialQuery creates a query. The command may contain escape sequences
+// like \r and \x1b.
+func NewSerialQuery(command, response string, bit uint) (SerialQuery, error) {
+	cmd, err := strconv.Unquote(`"` + command + `"`)
+	if err != nil {
+		return SerialQuery{}, fmt.Errorf("invalid command %q: %v", command, err)
+	}
+	if cmd == "" {
+		return SerialQuery{}, errors.New("query requires a command")
+	}
+	re, err := regexp.Compile(response)
+	if err != nil {
+		return SerialQuery{}, fmt.Errorf("invalid response: %v", err)
+	}
+	if re.NumSubexp() < 1 {
+		return SerialQuery{}, errors.New("response requires a submatch for the state of the inputs")
+	}
+	return SerialQuery{Command: []byte(cmd), Response: re, Bit: bit}, nil
+}
+
+// A SerialModule is an input of an ASCII serial I/O module.
+type SerialModule struct {
+	rw      io.ReadWriteCloser
+	query   SerialQuery
+	timeout time.Duration
+	lines   chan []byte
+}
+
+// NewSerialModule reads the input selected by q from the module connected
+// through rw. Answers are terminated by CR or LF.
+func NewSerialModule(rw io.ReadWriteCloser, q SerialQuery) *SerialModule {
+	m := &SerialModule{
+		rw:      rw,
+		query:   q,
+		timeout: DefaultResponseTimeout,
+		lines:   make(chan []byte, 1),
+	}
+	go m.read()
+	return m
+}
+
+func (m *SerialModule) read() {
+	s := bufio.NewScanner(m.rw)
+	s.Split(scanLines)
+	for s.Scan() {
+		line := append([]byte(nil), s.Bytes()...)
+		// Keep the latest answer only, stale answers are of no use.
+		select {
+		case <-m.lines:
+		default:
+		}
+		m.lines <- line
+	}
+	close(m.lines)
+}
+
+// scanLines splits at CR and LF, skipping empty lines.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := 0
+	for start < len(data) && (data[start] == '\r' || data[start] == '\n') {
+		start++
+	}
+	if i := bytes.IndexAny(data[start:], "\r\n"); i >= 0 {
+		return start + i + 1, data[start : start+i], nil
+	}
+	if atEOF && start < len(data) {
+		return len(data), data[start:], nil
+	}
+	return start, nil, nil
+}
+
+// Active implements the Input interface.
+func (m *SerialModule) Active() (bool, error) {
+	select {
+	case <-m.lines:
+	default:
+	}
+	if _, err := m.rw.Write(m.query.Command); err != nil {
+		return false, err
+	}
+
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-m.lines:
+			if !ok {
+				return false, io.ErrUnexpectedEOF
+			}
+			match := m.query.Response.FindSubmatch(line)
+			if match == nil {
+				continue
+			}
+			state, err := strconv.ParseUint(string(match[1]), 16, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid input state %q: %v", match[1], err)
+			}
+			return state&(1<<m.query.Bit) != 0, nil
+		case <-timer.C:
+			return false, ErrNoResponse
+		}
+	}
+}
+
+// Close closes the connection to the module.
+func (m *SerialModule) Close() error {
+	return m.rw.Close()
+}
@@ -0,0 +1,76 @@
+package input
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// StandInModule answers every query with answer.
+type StandInModule struct {
+	*io.PipeReader
+	w      *io.PipeWriter
+	answer string
+}
+
+func NewStandInModule(answer string) *StandInModule {
+	r, w := io.Pipe()
+	return &StandInModule{PipeReader: r, w: w, answer: answer}
+}
+
+func (m *StandInModule) Write(p []byte) (int, error) {
+	if m.answer != "" {
+		go m.w.Write([]byte(m.answer))
+	}
+	return len(p), nil
+}
+
+func (m *StandInModule) Close() error {
+	m.w.Close()
+	return m.PipeReader.Close()
+}
+
+func TestSerialModule(t *testing.T) {
+	t.Run("reads input", func(t *testing.T) {
+		for bit, want := range map[uint]bool{0: false, 1: true, 8: false, 9: true} {
+			q, err := NewSerialQuery(`$016\r`, "^!([0-9A-F]{4})00$", bit)
+			assert.NoError(t, err)
+			m := NewSerialModule(NewStandInModule("!02FE00\r"), q)
+
+			active, err := m.Active()
+
+			assert.NoError(t, err)
+			assert.Equal(t, want, active, "bit %d", bit)
+			m.Close()
+		}
+	})
+	t.Run("fails without answer", func(t *testing.T) {
+		q, err := NewSerialQuery(`$016\r`, "^!([0-9A-F]{4})00$", 0)
+		assert.NoError(t, err)
+		m := NewSerialModule(NewStandInModule(""), q)
+		m.timeout = 10 * time.Millisecond
+		defer m.Close()
+
+		_, err = m.Active()
+
+		assert.Equal(t, ErrNoResponse, err)
+	})
+}
+
+func TestNewSerialQuery(t *testing.T) {
+	t.Run("unescapes command", func(t *testing.T) {
+		q, err := NewSerialQuery(`$016\r`, "^!([0-9A-F]+)$", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("$016\r"), q.Command)
+	})
+	t.Run("requires submatch", func(t *testing.T) {
+		_, err := NewSerialQuery(`$016\r`, "^!.*$", 0)
+		assert.Error(t, err)
+	})
+	t.Run("requires command", func(t *testing.T) {
+		_, err := NewSerialQuery("", "^!(.*)$", 0)
+		assert.Error(t, err)
+	})
+}
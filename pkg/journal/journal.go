@@ -0,0 +1,243 @@
+// Package journal keeps an append-only record of the scan requests handled
+// at the gates of an agent and of the gates opened without scan, e.g. for
+// disputes with hauliers. Entries are stored as JSON lines, in one file per
+// day.
+package journal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An Outcome tells how the handling of a scan request or an opening ended.
+type Outcome string
+
+const (
+	// OutcomeGranted is recorded when the gate is opened for a scan.
+	OutcomeGranted Outcome = "granted"
+	// OutcomeCompleted is recorded when the agent returns to idle after a
+	// granted scan, e.g. once the vehicle passed.
+	OutcomeCompleted Outcome = "completed"
+	// OutcomeFailed is recorded for scans that failed and gates that failed
+	// to open.
+	OutcomeFailed Outcome = "failed"
+	// OutcomeRejected is recorded for scans that were not handled at all,
+	// e.g. while the lane was locked.
+	OutcomeRejected Outcome = "rejected"
+	// OutcomeOpened is recorded for gates opened without scan, e.g. manually
+	// or for a re-entry.
+	OutcomeOpened Outcome = "opened"
+)
+
+// NewOutcome returns the outcome of the given name.
+func NewOutcome(name string) (Outcome, error) {
+	switch Outcome(name) {
+	case OutcomeGranted, OutcomeCompleted, OutcomeFailed, OutcomeRejected, OutcomeOpened:
+		return Outcome(name), nil
+	}
+	return "", fmt.Errorf("undefined outcome: %s", name)
+}
+
+// An Entry is a state transition of a scan request or an opening of a gate.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Gate    string    `json:"gate"`
+	Purpose string    `json:"purpose,omitempty"`
+	// Trigger is what the gate was opened for, i.e. scan, manual, reentry
+	// or test.
+	Trigger string `json:"trigger"`
+	// State is the state the agent entered, empty for openings and rejected
+	// scans.
+	State string `json:"state,omitempty"`
+	// Scanner is the name of the scanner that read the token, Source the
+	// kind of token, e.g. PAPER.
+	Scanner string `json:"scanner,omitempty"`
+	Source  string `json:"source,omitempty"`
+	// Token is the token scanned, hashed if the journal hashes tokens.
+	Token string `json:"token,omitempty"`
+	// Outcome is empty while the scan request is being handled.
+	Outcome Outcome `json:"outcome,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Options limit the retention of the journal and protect the tokens stored.
+type Options struct {
+	// MaxAge is the time entries are kept, forever if 0.
+	MaxAge time.Duration
+	// MaxSize is the size in bytes of the files kept before the current one,
+	// unlimited if 0. The oldest files are removed first.
+	MaxSize int64
+	// TokenKey hashes the tokens recorded, see HashToken. Tokens are recorded
+	// as scanned if empty.
+	TokenKey string
+}
+
+// HashToken returns the HMAC-SHA256 of token, keyed with key. Unlike plain
+// hashes, short tokens cannot be recovered by hashing all possible tokens
+// without the key.
+func HashToken(key, token string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+const (
+	filePrefix = "journal-"
+	fileSuffix = ".jsonl"
+	dayLayout  = "2006-01-02"
+)
+
+// A Journal appends entries to the files in its directory. It is safe for
+// concurrent use.
+type Journal struct {
+	dir     string
+	options Options
+	now     func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	day  string
+}
+
+// Open opens the journal in dir, creating dir if needed. Files exceeding the
+// retention limits are removed on opening and whenever a new file is started.
+func Open(dir string, options Options) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	j := &Journal{dir: dir, options: options, now: time.Now}
+	if err := j.prune(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Record appends e to the file of the day of e.Time.
+func (j *Journal) Record(e Entry) error {
+	if e.Token != "" && j.options.TokenKey != "" {
+		e.Token = HashToken(j.options.TokenKey, e.Token)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, err := j.fileOf(e.Time.Local().Format(dayLayout))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Close closes the current file of the journal.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file, j.day = nil, ""
+	return err
+}
+
+// fileOf returns the file of day, opening it if needed.
+func (j *Journal) fileOf(day string) (*os.File, error) {
+	if j.file != nil && j.day == day {
+		return j.file, nil
+	}
+	if j.file != nil {
+		j.file.Close()
+		j.file, j.day = nil, ""
+	}
+
+	f, err := os.OpenFile(filepath.Join(j.dir, filePrefix+day+fileSuffix), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	j.file, j.day = f, day
+	if err := j.prune(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// prune removes the files exceeding the retention limits. The current file is
+// kept.
+func (j *Journal) prune() error {
+	files, err := journalFiles(j.dir)
+	if err != nil {
+		return err
+	}
+
+	if j.options.MaxAge > 0 {
+		oldest := j.now().Add(-j.options.MaxAge).Format(dayLayout)
+		for len(files) > 0 && files[0].day < oldest && files[0].day != j.day {
+			if err := os.Remove(files[0].path); err != nil {
+				return err
+			}
+			files = files[1:]
+		}
+	}
+
+	if j.options.MaxSize > 0 {
+		var size int64
+		for _, f := range files {
+			if f.day != j.day {
+				size += f.size
+			}
+		}
+		for len(files) > 0 && size > j.options.MaxSize && files[0].day != j.day {
+			if err := os.Remove(files[0].path); err != nil {
+				return err
+			}
+			size -= files[0].size
+			files = files[1:]
+		}
+	}
+	return nil
+}
+
+type journalFile struct {
+	path string
+	day  string
+	size int64
+}
+
+// journalFiles returns the files of the journal in dir, oldest first.
+func journalFiles(dir string) ([]journalFile, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []journalFile
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		day := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			continue
+		}
+		files = append(files, journalFile{path: filepath.Join(dir, name), day: day, size: info.Size()})
+	}
+	sort.Slice(files, func(i, k int) bool { return files[i].day < files[k].day })
+	return files, nil
+}
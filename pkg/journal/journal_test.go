@@ -0,0 +1,155 @@
+package journal
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, dir, day, content string) string {
+	path := filepath.Join(dir, filePrefix+day+fileSuffix)
+	if err := ioutil.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJournal_Record(t *testing.T) {
+	t.Run("appends entries to file of day", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		j, err := Open(dir, Options{})
+		assert.NoError(t, err)
+		defer j.Close()
+		day := time.Date(2021, 3, 1, 8, 15, 0, 0, time.Local)
+
+		assert.NoError(t, j.Record(Entry{Time: day, Gate: "entry-1", Token: "4711", Outcome: OutcomeGranted}))
+		assert.NoError(t, j.Record(Entry{Time: day.Add(time.Minute), Gate: "entry-1", Token: "4711", Outcome: OutcomeCompleted}))
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, "journal-2021-03-01.jsonl"))
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"token":"4711","outcome":"granted"`)
+	})
+	t.Run("hashes tokens", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		j, err := Open(dir, Options{TokenKey: "secret"})
+		assert.NoError(t, err)
+		defer j.Close()
+		day := time.Date(2021, 3, 1, 8, 15, 0, 0, time.Local)
+
+		assert.NoError(t, j.Record(Entry{Time: day, Gate: "entry-1", Token: "4711"}))
+
+		entries, err := Query(dir, Filter{Token: HashToken("secret", "4711")})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.NotEqual(t, HashToken("other", "4711"), entries[0].Token)
+	})
+}
+
+func TestJournal_prune(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.Local)
+
+	t.Run("removes files older than max age", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		old := writeFile(t, dir, "2021-03-01", "{}\n")
+		recent := writeFile(t, dir, "2021-03-05", "{}\n")
+		j := &Journal{dir: dir, options: Options{MaxAge: 7 * 24 * time.Hour}, now: func() time.Time { return now }}
+
+		assert.NoError(t, j.prune())
+
+		assert.NoFileExists(t, old)
+		assert.FileExists(t, recent)
+	})
+	t.Run("removes oldest files exceeding max size", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		oldest := writeFile(t, dir, "2021-03-01", "0123456789\n")
+		older := writeFile(t, dir, "2021-03-02", "0123456789\n")
+		current := writeFile(t, dir, "2021-03-10", "0123456789\n")
+		j := &Journal{dir: dir, options: Options{MaxSize: 15}, now: func() time.Time { return now }, day: "2021-03-10"}
+
+		assert.NoError(t, j.prune())
+
+		assert.NoFileExists(t, oldest)
+		assert.FileExists(t, older)
+		assert.FileExists(t, current)
+	})
+	t.Run("ignores other files", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		other := filepath.Join(dir, "notes.txt")
+		assert.NoError(t, ioutil.WriteFile(other, []byte("keep"), 0640))
+		j := &Journal{dir: dir, options: Options{MaxAge: time.Hour, MaxSize: 1}, now: func() time.Time { return now }}
+
+		assert.NoError(t, j.prune())
+
+		assert.FileExists(t, other)
+	})
+}
+
+func TestJournal_Listen(t *testing.T) {
+	token := *scanner.NewToken("4711", "scanner 1")
+	req := agent.NewScanRequest("DEKOB", 42, agent.PurposeEntry, token)
+	failed := agent.NewScanRequest("DEKOB", 42, agent.PurposeEntry, token)
+	failed.Fail(errors.New("permission not found"))
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	j, err := Open(dir, Options{})
+	assert.NoError(t, err)
+	defer j.Close()
+	events, openings, stop := make(chan interface{}), make(chan agent.GateOpening), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		j.Listen("entry-1", events, openings, stop)
+		close(done)
+	}()
+
+	at := time.Date(2021, 3, 1, 8, 15, 0, 0, time.Local)
+	events <- agent.FsmScanRequest{ScanRequest: req, State: agent.StateValidating, Time: at}
+	events <- agent.FsmScanRequest{ScanRequest: req, State: agent.StateGating, Time: at.Add(time.Second)}
+	events <- agent.FsmScanRequest{ScanRequest: req, State: agent.StateIdle, Time: at.Add(2 * time.Second)}
+	events <- agent.FsmScanRequest{ScanRequest: failed, State: agent.StateError, Time: at.Add(3 * time.Second)}
+	events <- agent.FsmScanRequest{ScanRequest: failed, State: agent.StateIdle, Time: at.Add(4 * time.Second)}
+	events <- agent.QueueStatus{Depth: 1}
+	events <- agent.RejectedScanRequest{ScanRequest: failed, Time: at.Add(5 * time.Second)}
+	openings <- agent.GateOpening{Request: gatedriver.Request{Token: "4711", Trigger: gatedriver.TriggerScan}, Time: at.Add(6 * time.Second)}
+	openings <- agent.GateOpening{Request: gatedriver.Request{Token: "4711", Trigger: gatedriver.TriggerReentry}, Time: at.Add(7 * time.Second)}
+	close(stop)
+	<-done
+
+	entries, err := Query(dir, Filter{})
+	assert.NoError(t, err)
+	var outcomes []Outcome
+	for _, e := range entries {
+		assert.Equal(t, "entry-1", e.Gate)
+		outcomes = append(outcomes, e.Outcome)
+	}
+	assert.Equal(t, []Outcome{"", OutcomeGranted, OutcomeCompleted, OutcomeFailed, "", OutcomeRejected, OutcomeOpened}, outcomes)
+	assert.True(t, at.Equal(entries[0].Time))
+	assert.True(t, at.Add(5*time.Second).Equal(entries[5].Time))
+	assert.Equal(t, "scanner 1", entries[0].Scanner)
+	assert.Equal(t, "permission not found", entries[3].Error)
+	assert.Equal(t, string(gatedriver.TriggerReentry), entries[6].Trigger)
+}
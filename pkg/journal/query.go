@@ -0,0 +1,119 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// A Filter selects the entries of a query. Zero fields match all entries.
+type Filter struct {
+	// From and To limit the time of entries, To is excluded.
+	From, To time.Time
+	Gate     string
+	// Token is compared to the tokens recorded, so it must be hashed if the
+	// journal hashes tokens, see HashToken.
+	Token   string
+	Outcome Outcome
+}
+
+// Match reports whether e is selected by f.
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case !f.From.IsZero() && e.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.Time.Before(f.To):
+		return false
+	case f.Gate != "" && e.Gate != f.Gate:
+		return false
+	case f.Token != "" && e.Token != f.Token:
+		return false
+	case f.Outcome != "" && e.Outcome != f.Outcome:
+		return false
+	}
+	return true
+}
+
+// Query returns the entries of the journal in dir selected by f, oldest
+// first. Lines that cannot be read, e.g. written partially on power loss, are
+// logged and skipped.
+func Query(dir string, f Filter) ([]Entry, error) {
+	files, err := journalFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files are named by the local day of their entries.
+	var from, to string
+	if !f.From.IsZero() {
+		from = f.From.Local().Format(dayLayout)
+	}
+	if !f.To.IsZero() {
+		to = f.To.Local().Format(dayLayout)
+	}
+
+	entries := []Entry{}
+	for _, file := range files {
+		if (from != "" && file.day < from) || (to != "" && file.day > to) {
+			continue
+		}
+		if entries, err = queryFile(file.path, f, entries); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func queryFile(path string, f Filter, entries []Entry) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := bufio.NewScanner(file)
+	for n := 1; lines.Scan(); n++ {
+		var e Entry
+		if err := json.Unmarshal(lines.Bytes(), &e); err != nil {
+			log.Printf("Skipping %s:%d: %v", path, n, err)
+			continue
+		}
+		if f.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, lines.Err()
+}
+
+// WriteJSON writes entries to w as JSON array.
+func WriteJSON(w io.Writer, entries []Entry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// WriteCSV writes entries to w as CSV with header line.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"time", "gate", "purpose", "trigger", "state", "scanner", "source", "token", "outcome", "error"})
+	for _, e := range entries {
+		out.Write([]string{
+			e.Time.Format(time.RFC3339Nano),
+			e.Gate,
+			e.Purpose,
+			e.Trigger,
+			e.State,
+			e.Scanner,
+			e.Source,
+			e.Token,
+			string(e.Outcome),
+			e.Error,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_Match(t *testing.T) {
+	at := time.Date(2021, 3, 1, 8, 15, 0, 0, time.UTC)
+	e := Entry{Time: at, Gate: "entry-1", Token: "4711", Outcome: OutcomeFailed}
+
+	t.Run("matches all entries without criteria", func(t *testing.T) {
+		assert.True(t, Filter{}.Match(e))
+	})
+	t.Run("matches time range", func(t *testing.T) {
+		assert.True(t, Filter{From: at, To: at.Add(time.Second)}.Match(e))
+		assert.False(t, Filter{From: at.Add(time.Second)}.Match(e))
+		assert.False(t, Filter{To: at}.Match(e))
+	})
+	t.Run("matches gate, token and outcome", func(t *testing.T) {
+		assert.True(t, Filter{Gate: "entry-1", Token: "4711", Outcome: OutcomeFailed}.Match(e))
+		assert.False(t, Filter{Gate: "exit-1"}.Match(e))
+		assert.False(t, Filter{Token: "0815"}.Match(e))
+		assert.False(t, Filter{Outcome: OutcomeGranted}.Match(e))
+	})
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("returns entries of days in range", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		writeFile(t, dir, "2021-03-01", `{"time":"2021-03-01T12:00:00+01:00","gate":"entry-1","token":"1"}`+"\n")
+		writeFile(t, dir, "2021-03-02", `{"time":"2021-03-02T12:00:00+01:00","gate":"entry-1","token":"2"}`+"\n"+
+			`{"time":"2021-03-02T13:00:00+01:00","gate":"entry-1","token":"3"}`+"\n")
+		writeFile(t, dir, "2021-03-03", `{"time":"2021-03-03T12:00:00+01:00","gate":"entry-1","token":"4"}`+"\n")
+		from, _ := time.Parse(time.RFC3339, "2021-03-01T18:00:00+01:00")
+		to, _ := time.Parse(time.RFC3339, "2021-03-02T12:30:00+01:00")
+
+		entries, err := Query(dir, Filter{From: from, To: to})
+
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "2", entries[0].Token)
+	})
+	t.Run("skips partially written lines", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		writeFile(t, dir, "2021-03-01", `{"time":"2021-03-01T12:00:00+01:00","gate":"ent`+"\n"+
+			`{"time":"2021-03-01T13:00:00+01:00","gate":"entry-1","token":"2"}`+"\n")
+
+		entries, err := Query(dir, Filter{})
+
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Run("writes header and entries", func(t *testing.T) {
+		var buf bytes.Buffer
+		at := time.Date(2021, 3, 1, 8, 15, 0, 0, time.UTC)
+
+		err := WriteCSV(&buf, []Entry{{
+			Time: at, Gate: "entry-1", Purpose: "entry", Trigger: "scan", State: "error",
+			Scanner: "scanner 1", Source: "PAPER", Token: "4711", Outcome: OutcomeFailed, Error: "no permission, sorry",
+		}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "time,gate,purpose,trigger,state,scanner,source,token,outcome,error\n"+
+			"2021-03-01T08:15:00Z,entry-1,entry,scan,error,scanner 1,PAPER,4711,failed,\"no permission, sorry\"\n", buf.String())
+	})
+}
+
+func TestWriteJSON(t *testing.T) {
+	t.Run("writes empty array without entries", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.NoError(t, WriteJSON(&buf, []Entry{}))
+
+		assert.Equal(t, "[]\n", buf.String())
+	})
+}
@@ -0,0 +1,93 @@
+package journal
+
+import (
+	"log"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+)
+
+// Listen records the scan requests published by the agent of gate and the
+// openings of the gate not triggered by scans until stop is closed. It should
+// be stopped after the agent was shut down, so the last requests are
+// recorded.
+func (j *Journal) Listen(gate string, events chan interface{}, openings chan agent.GateOpening, stop chan struct{}) {
+	for {
+		select {
+		case msg := <-events:
+			var e Entry
+			switch data := msg.(type) {
+			case agent.FsmScanRequest:
+				e = transitionEntry(data)
+			case agent.RejectedScanRequest:
+				e = requestEntry(data.ScanRequest, data.Time)
+				e.Outcome = OutcomeRejected
+			default:
+				continue
+			}
+			e.Gate = gate
+			j.record(e)
+		case opening := <-openings:
+			// Openings for scans are recorded as transitions.
+			if opening.Request.Trigger == gatedriver.TriggerScan {
+				continue
+			}
+			e := Entry{
+				Time:    opening.Time,
+				Gate:    gate,
+				Purpose: opening.Request.Purpose,
+				Trigger: string(opening.Request.Trigger),
+				Source:  opening.Request.ScanSource,
+				Token:   opening.Request.Token,
+				Outcome: OutcomeOpened,
+			}
+			if opening.Error != nil {
+				e.Outcome = OutcomeFailed
+				e.Error = opening.Error.Error()
+			}
+			j.record(e)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (j *Journal) record(e Entry) {
+	if err := j.Record(e); err != nil {
+		log.Printf("[%s] Failed to record journal entry: %v", e.Token, err)
+	}
+}
+
+// transitionEntry returns the entry of the state the agent entered for a
+// scan request.
+func transitionEntry(r agent.FsmScanRequest) Entry {
+	e := requestEntry(r.ScanRequest, r.Time)
+	e.State = r.State
+	switch {
+	case r.State == agent.StateGating:
+		e.Outcome = OutcomeGranted
+	case r.State == agent.StateError:
+		e.Outcome = OutcomeFailed
+	// Failed requests return to idle with their error.
+	case r.State == agent.StateIdle && e.Error == "":
+		e.Outcome = OutcomeCompleted
+	}
+	return e
+}
+
+// requestEntry returns the entry of r at the time of the event recorded.
+func requestEntry(r agent.ScanRequest, at time.Time) Entry {
+	e := Entry{
+		Time:    at,
+		Purpose: r.Purpose().String(),
+		Trigger: string(gatedriver.TriggerScan),
+		Scanner: r.ScannerName(),
+		Source:  r.Source(),
+		Token:   r.Token(),
+	}
+	if err := r.Error(); err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
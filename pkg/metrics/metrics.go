@@ -14,6 +14,7 @@ var stateToNumerical = map[string]int{
 	worker.StatePrinting:   2,
 	worker.StateGating:     3,
 	worker.StateError:      4,
+	worker.StatePassing:    5,
 }
 
 func convertToLineProtocol(stateName, usedScanner string, err error) string {
@@ -25,7 +26,10 @@ func Listen(influxClient InfluxClient, metricsChannel chan interface{}, shutdown
 	for {
 		select {
 		case fsmData := <-metricsChannel:
-			fsmDataCasted := fsmData.(worker.FsmScanRequest)
+			fsmDataCasted, ok := fsmData.(worker.FsmScanRequest)
+			if !ok {
+				continue
+			}
 			log.Println(convertToLineProtocol(fsmDataCasted.State, fsmDataCasted.ScanRequest.ScannerName(), fsmDataCasted.ScanRequest.Error()))
 			err := influxClient.Write(convertToLineProtocol(fsmDataCasted.State, fsmDataCasted.ScanRequest.ScannerName(), fsmDataCasted.ScanRequest.Error()))
 			if err != nil {
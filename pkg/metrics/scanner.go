@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// A ScannerCounters provides the counters of a scanner.
+type ScannerCounters interface {
+	Name() string
+	Suppressed() uint64
+}
+
+func convertCountersToLineProtocol(counters ScannerCounters) string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("go-gateagent-scanner host=\"%s\",scanner=\"%s\",suppressed=%d %d\n", hostname, counters.Name(), counters.Suppressed(), time.Now().UnixNano())
+}
+
+// ListenScanners periodically writes the counters of scanners to influx.
+func ListenScanners(influxClient InfluxClient, scanners []ScannerCounters, interval time.Duration, shutdownChannel chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, s := range scanners {
+				if err := influxClient.Write(convertCountersToLineProtocol(s)); err != nil {
+					log.Println("got err response", err)
+				}
+			}
+		case <-shutdownChannel:
+			return
+		}
+	}
+}
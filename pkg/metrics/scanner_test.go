@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ScannerCountersMock struct {
+	name       string
+	suppressed uint64
+}
+
+func (s *ScannerCountersMock) Name() string       { return s.name }
+func (s *ScannerCountersMock) Suppressed() uint64 { return s.suppressed }
+
+func TestListenScanners(t *testing.T) {
+	t.Run("should write scanner counters to influx", func(t *testing.T) {
+		hostname, _ := os.Hostname()
+		shutdownChan := make(chan struct{})
+		influxClientMock := InfluxClientMock{make(chan string, 4)}
+		scanners := []ScannerCounters{&ScannerCountersMock{"scanner 1", 3}}
+
+		go ListenScanners(&influxClientMock, scanners, time.Millisecond, shutdownChan)
+		defer close(shutdownChan)
+
+		assert.Regexp(t, regexp.MustCompile("go-gateagent-scanner host=\""+hostname+"\",scanner=\"scanner 1\",suppressed=3 \\d+\n"), influxClientMock.Receive())
+	})
+}
@@ -46,7 +46,10 @@ func (m *Client) Listen() {
 	for {
 		select {
 		case fsmData := <-m.metricsChannel:
-			fsmDataCasted := fsmData.(worker.FsmScanRequest)
+			fsmDataCasted, ok := fsmData.(worker.FsmScanRequest)
+			if !ok {
+				continue
+			}
 
 			fsmMessage := &FSMMessage{
 				fsmDataCasted.State,
@@ -0,0 +1,165 @@
+// Package modbus is a minimal Modbus TCP client for the digital inputs and
+// coils of I/O modules, e.g. loop detectors and gate relays.
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the time a module has to answer a request, unless
+// configured otherwise.
+const DefaultTimeout = time.Second
+
+const (
+	fnReadCoils          = 0x01
+	fnReadDiscreteInputs = 0x02
+	fnWriteSingleCoil    = 0x05
+
+	mbapHeaderLength = 7
+	maxPDULength     = 253
+)
+
+// ErrInvalidResponse is returned if a response does not match its request.
+var ErrInvalidResponse = errors.New("invalid modbus response")
+
+// An ExceptionError is returned if a module answers with an exception.
+type ExceptionError struct {
+	Function byte
+	Code     byte
+}
+
+func (e *ExceptionError) Error() string {
+	return fmt.Sprintf("modbus exception %#02x on function %#02x", e.Code, e.Function)
+}
+
+// A Client talks to a Modbus TCP module. It is safe for concurrent use,
+// requests are sent one at a time.
+type Client struct {
+	conn    net.Conn
+	unit    byte
+	timeout time.Duration
+
+	mu            sync.Mutex
+	transactionID uint16
+}
+
+// Dial connects to the module at address, talking to the given unit ID.
+func Dial(address string, unit byte, timeout time.Duration) (*Client, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, unit: unit, timeout: timeout}, nil
+}
+
+// Close closes the connection to the module.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ReadDiscreteInputs reads quantity discrete inputs starting at address.
+func (c *Client) ReadDiscreteInputs(address, quantity uint16) ([]bool, error) {
+	return c.readBits(fnReadDiscreteInputs, address, quantity)
+}
+
+// ReadCoils reads quantity coils starting at address.
+func (c *Client) ReadCoils(address, quantity uint16) ([]bool, error) {
+	return c.readBits(fnReadCoils, address, quantity)
+}
+
+// WriteSingleCoil switches the coil at address on or off.
+func (c *Client) WriteSingleCoil(address uint16, on bool) error {
+	value := uint16(0x0000)
+	if on {
+		value = 0xff00
+	}
+	req := make([]byte, 5)
+	req[0] = fnWriteSingleCoil
+	binary.BigEndian.PutUint16(req[1:], address)
+	binary.BigEndian.PutUint16(req[3:], value)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp) != 5 || binary.BigEndian.Uint16(resp[1:]) != address {
+		return ErrInvalidResponse
+	}
+	return nil
+}
+
+func (c *Client) readBits(function byte, address, quantity uint16) ([]bool, error) {
+	req := make([]byte, 5)
+	req[0] = function
+	binary.BigEndian.PutUint16(req[1:], address)
+	binary.BigEndian.PutUint16(req[3:], quantity)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	count := int(quantity+7) / 8
+	if len(resp) < 2 || int(resp[1]) != count || len(resp) != 2+count {
+		return nil, ErrInvalidResponse
+	}
+
+	bits := make([]bool, quantity)
+	for i := range bits {
+		bits[i] = resp[2+i/8]&(1<<uint(i%8)) != 0
+	}
+	return bits, nil
+}
+
+// do sends the request PDU and returns the response PDU.
+func (c *Client) do(pdu []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.transactionID++
+	frame := make([]byte, mbapHeaderLength, mbapHeaderLength+len(pdu))
+	binary.BigEndian.PutUint16(frame[0:], c.transactionID)
+	binary.BigEndian.PutUint16(frame[2:], 0)
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(pdu)+1))
+	frame[6] = c.unit
+	frame = append(frame, pdu...)
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, mbapHeaderLength)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if length < 2 || length > maxPDULength+1 {
+		return nil, ErrInvalidResponse
+	}
+	resp := make([]byte, length-1)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint16(header[0:]) != c.transactionID || header[6] != c.unit {
+		return nil, ErrInvalidResponse
+	}
+
+	switch {
+	case resp[0] == pdu[0]|0x80 && len(resp) == 2:
+		return nil, &ExceptionError{Function: pdu[0], Code: resp[1]}
+	case resp[0] != pdu[0]:
+		return nil, ErrInvalidResponse
+	}
+	return resp, nil
+}
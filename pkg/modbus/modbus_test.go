@@ -0,0 +1,142 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// StandInModule is a Modbus TCP module with 16 discrete inputs and 16 coils.
+type StandInModule struct {
+	listener net.Listener
+
+	mu     sync.Mutex
+	inputs [16]bool
+	coils  [16]bool
+}
+
+func NewStandInModule(t *testing.T) *StandInModule {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	m := &StandInModule{listener: listener}
+	go m.serve()
+	return m
+}
+
+func (m *StandInModule) Address() string {
+	return m.listener.Addr().String()
+}
+
+func (m *StandInModule) Close() {
+	m.listener.Close()
+}
+
+func (m *StandInModule) SetInput(i int, on bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs[i] = on
+}
+
+func (m *StandInModule) Coil(i int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.coils[i]
+}
+
+func (m *StandInModule) serve() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+		go m.handle(conn)
+	}
+}
+
+func (m *StandInModule) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 7)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		pdu := make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		resp := m.respond(pdu)
+		binary.BigEndian.PutUint16(header[4:], uint16(len(resp)+1))
+		conn.Write(append(header, resp...))
+	}
+}
+
+func (m *StandInModule) respond(pdu []byte) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	address := int(binary.BigEndian.Uint16(pdu[1:]))
+	switch pdu[0] {
+	case fnReadDiscreteInputs, fnReadCoils:
+		bits := m.inputs
+		if pdu[0] == fnReadCoils {
+			bits = m.coils
+		}
+		quantity := int(binary.BigEndian.Uint16(pdu[3:]))
+		if address+quantity > len(bits) {
+			return []byte{pdu[0] | 0x80, 0x02}
+		}
+		data := make([]byte, (quantity+7)/8)
+		for i := 0; i < quantity; i++ {
+			if bits[address+i] {
+				data[i/8] |= 1 << uint(i%8)
+			}
+		}
+		return append([]byte{pdu[0], byte(len(data))}, data...)
+	case fnWriteSingleCoil:
+		m.coils[address] = binary.BigEndian.Uint16(pdu[3:]) == 0xff00
+		return pdu
+	}
+	return []byte{pdu[0] | 0x80, 0x01}
+}
+
+func TestClient(t *testing.T) {
+	module := NewStandInModule(t)
+	defer module.Close()
+	client, err := Dial(module.Address(), 1, 0)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	t.Run("reads discrete inputs", func(t *testing.T) {
+		module.SetInput(3, true)
+		module.SetInput(9, true)
+
+		inputs, err := client.ReadDiscreteInputs(2, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []bool{false, true, false, false, false, false, false, true, false, false}, inputs)
+	})
+	t.Run("writes coils", func(t *testing.T) {
+		assert.NoError(t, client.WriteSingleCoil(5, true))
+		assert.True(t, module.Coil(5))
+
+		coils, err := client.ReadCoils(5, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []bool{true}, coils)
+
+		assert.NoError(t, client.WriteSingleCoil(5, false))
+		assert.False(t, module.Coil(5))
+	})
+	t.Run("returns exceptions", func(t *testing.T) {
+		_, err := client.ReadDiscreteInputs(12, 8)
+
+		var exception *ExceptionError
+		assert.True(t, errors.As(err, &exception))
+		assert.Equal(t, byte(0x02), exception.Code)
+	})
+}
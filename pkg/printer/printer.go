@@ -0,0 +1,212 @@
+// Package printer prints interchange tickets on ESC/POS receipt printers
+// attached through TCP, a serial port or the USB printer class, or asks a
+// central print service to print them.
+package printer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/scanner"
+)
+
+var (
+	// ErrPaperOut is returned if the printer ran out of paper.
+	ErrPaperOut = errors.New("printer is out of paper")
+	// ErrCoverOpen is returned if the cover of the printer is open.
+	ErrCoverOpen = errors.New("printer cover is open")
+	// ErrFailure is returned if the printer reports an error, e.g. a cutter
+	// jam.
+	ErrFailure = errors.New("printer reports an error")
+	// ErrNoStatus is returned if the printer does not answer status requests.
+	ErrNoStatus = errors.New("printer does not answer status request")
+)
+
+// DefaultStatusTimeout is the time the printer has to answer a status
+// request, unless configured otherwise.
+const DefaultStatusTimeout = 2 * time.Second
+
+// DefaultSerialOptions are used for serial printers, unless configured
+// otherwise. Status answers are single bytes, so reads must not wait for more.
+var DefaultSerialOptions = scanner.SerialOptions{
+	BaudRate:        9600,
+	DataBits:        8,
+	Parity:          "none",
+	StopBits:        1,
+	MinimumReadSize: 1,
+}
+
+// ESC/POS real-time status requests (DLE EOT n) and their status bits.
+var (
+	cmdOfflineStatus = []byte{0x10, 0x04, 2}
+	cmdPaperStatus   = []byte{0x10, 0x04, 4}
+)
+
+const (
+	statusFixedMask  = 0x93
+	statusFixedBits  = 0x12
+	offlineCoverOpen = 0x04
+	offlinePaperEnd  = 0x20
+	offlineError     = 0x40
+	paperEnd         = 0x60
+)
+
+// A TicketPrinter prints tickets.
+type TicketPrinter interface {
+	Print(ctx context.Context, t Ticket) error
+}
+
+// A Dialer connects to a printer.
+type Dialer func(ctx context.Context) (io.ReadWriteCloser, error)
+
+// TCPDialer connects to a network printer, e.g. on port 9100.
+func TCPDialer(address string) Dialer {
+	return Dialer(func(ctx context.Context) (io.ReadWriteCloser, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", address)
+	})
+}
+
+// SerialDialer connects to a printer on the serial port device.
+func SerialDialer(device string, opts scanner.SerialOptions) Dialer {
+	return Dialer(func(context.Context) (io.ReadWriteCloser, error) {
+		return scanner.OpenSerialPort(device, opts)
+	})
+}
+
+// DeviceDialer connects to a printer through a device node, e.g. the USB
+// printer class device /dev/usb/lp0.
+func DeviceDialer(path string) Dialer {
+	return Dialer(func(context.Context) (io.ReadWriteCloser, error) {
+		return os.OpenFile(path, os.O_RDWR, 0)
+	})
+}
+
+// A Printer is an ESC/POS receipt printer. It is connected for every ticket,
+// so a printer that was switched off is used again once it is back.
+type Printer struct {
+	name          string
+	dial          Dialer
+	statusTimeout time.Duration
+}
+
+// New creates a printer connected through dial.
+func New(name string, dial Dialer, statusTimeout time.Duration) *Printer {
+	if statusTimeout <= 0 {
+		statusTimeout = DefaultStatusTimeout
+	}
+	return &Printer{name: name, dial: dial, statusTimeout: statusTimeout}
+}
+
+// Name returns the name of the printer.
+func (p *Printer) Name() string {
+	return p.name
+}
+
+// Print prints t. The printer status is checked before and after printing,
+// so paper-out or an open cover fail the print.
+func (p *Printer) Print(ctx context.Context, t Ticket) error {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("printer %s: %v", p.name, err)
+	}
+	defer conn.Close()
+
+	// Unblock pending reads and writes when ctx is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := p.checkStatus(conn); err != nil {
+		return p.failed(ctx, err)
+	}
+	if _, err := conn.Write(t.ESCPOS()); err != nil {
+		return p.failed(ctx, err)
+	}
+	if err := p.checkStatus(conn); err != nil {
+		return p.failed(ctx, err)
+	}
+	return nil
+}
+
+func (p *Printer) failed(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("printer %s: %w", p.name, err)
+}
+
+// checkStatus requests the real-time status of the printer.
+func (p *Printer) checkStatus(rw io.ReadWriter) error {
+	offline, err := p.queryStatus(rw, cmdOfflineStatus)
+	if err != nil {
+		return err
+	}
+	switch {
+	case offline&offlineCoverOpen != 0:
+		return ErrCoverOpen
+	case offline&offlinePaperEnd != 0:
+		return ErrPaperOut
+	case offline&offlineError != 0:
+		return ErrFailure
+	}
+
+	paper, err := p.queryStatus(rw, cmdPaperStatus)
+	if err != nil {
+		return err
+	}
+	if paper&paperEnd != 0 {
+		return ErrPaperOut
+	}
+	return nil
+}
+
+func (p *Printer) queryStatus(rw io.ReadWriter, cmd []byte) (byte, error) {
+	if _, err := rw.Write(cmd); err != nil {
+		return 0, err
+	}
+
+	type result struct {
+		status byte
+		err    error
+	}
+	resultChan := make(chan result, 1)
+	go func() {
+		b := make([]byte, 1)
+		for {
+			n, err := rw.Read(b)
+			if n == 1 {
+				resultChan <- result{status: b[0]}
+				return
+			}
+			if err != nil {
+				resultChan <- result{err: err}
+				return
+			}
+		}
+	}()
+
+	select {
+	case r := <-resultChan:
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.status&statusFixedMask != statusFixedBits {
+			return 0, fmt.Errorf("invalid status byte: %#02x", r.status)
+		}
+		return r.status, nil
+	case <-time.After(p.statusTimeout):
+		return 0, ErrNoStatus
+	}
+}
@@ -0,0 +1,172 @@
+package printer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// StandInPrinter is a network printer answering real-time status requests
+// and recording everything else it receives.
+type StandInPrinter struct {
+	listener net.Listener
+	offline  byte
+	paper    byte
+	silent   bool
+
+	mu       sync.Mutex
+	received bytes.Buffer
+}
+
+// NewStandInPrinter starts a printer answering status requests with the
+// given status bytes, or not at all if silent.
+func NewStandInPrinter(t *testing.T, offline, paper byte, silent bool) *StandInPrinter {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	p := &StandInPrinter{listener: listener, offline: offline, paper: paper, silent: silent}
+	go p.serve()
+	return p
+}
+
+func (p *StandInPrinter) Address() string {
+	return p.listener.Addr().String()
+}
+
+func (p *StandInPrinter) Close() {
+	p.listener.Close()
+}
+
+func (p *StandInPrinter) Received() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]byte(nil), p.received.Bytes()...)
+}
+
+func (p *StandInPrinter) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *StandInPrinter) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var pending []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		pending = append(pending, buf[:n]...)
+		for len(pending) > 0 {
+			if pending[0] != 0x10 {
+				p.mu.Lock()
+				p.received.WriteByte(pending[0])
+				p.mu.Unlock()
+				pending = pending[1:]
+				continue
+			}
+			if len(pending) < 3 {
+				break
+			}
+			if !p.silent {
+				switch pending[2] {
+				case 2:
+					conn.Write([]byte{p.offline})
+				case 4:
+					conn.Write([]byte{p.paper})
+				}
+			}
+			pending = pending[3:]
+		}
+	}
+}
+
+const (
+	statusOK        = 0x12
+	statusCoverOpen = 0x12 | 0x04
+	statusPaperEnd  = 0x12 | 0x60
+)
+
+var ticket = Ticket{
+	Title:        "Contargo",
+	Token:        "4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5",
+	Location:     "DEKOB",
+	LoadingPlace: 42,
+	Gate:         "entry-1",
+	Purpose:      "entry",
+	Time:         time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+	Details:      map[string]string{"container": "CTGU1234567", "lane": "3"},
+}
+
+func TestPrinter_Print(t *testing.T) {
+	t.Run("prints tickets", func(t *testing.T) {
+		standIn := NewStandInPrinter(t, statusOK, statusOK, false)
+		defer standIn.Close()
+		p := New("printer 1", TCPDialer(standIn.Address()), time.Second)
+
+		err := p.Print(context.Background(), ticket)
+
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return bytes.Contains(standIn.Received(), []byte("CTGU1234567"))
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("fails if out of paper", func(t *testing.T) {
+		standIn := NewStandInPrinter(t, statusOK, statusPaperEnd, false)
+		defer standIn.Close()
+		p := New("printer 1", TCPDialer(standIn.Address()), time.Second)
+
+		err := p.Print(context.Background(), ticket)
+
+		assert.True(t, errors.Is(err, ErrPaperOut))
+		assert.Empty(t, standIn.Received())
+	})
+	t.Run("fails if cover is open", func(t *testing.T) {
+		standIn := NewStandInPrinter(t, statusCoverOpen, statusOK, false)
+		defer standIn.Close()
+		p := New("printer 1", TCPDialer(standIn.Address()), time.Second)
+
+		err := p.Print(context.Background(), ticket)
+
+		assert.True(t, errors.Is(err, ErrCoverOpen))
+	})
+	t.Run("fails if status is not answered", func(t *testing.T) {
+		standIn := NewStandInPrinter(t, statusOK, statusOK, true)
+		defer standIn.Close()
+		p := New("printer 1", TCPDialer(standIn.Address()), 50*time.Millisecond)
+
+		err := p.Print(context.Background(), ticket)
+
+		assert.True(t, errors.Is(err, ErrNoStatus))
+	})
+	t.Run("fails if printer is unreachable", func(t *testing.T) {
+		standIn := NewStandInPrinter(t, statusOK, statusOK, false)
+		standIn.Close()
+		p := New("printer 1", TCPDialer(standIn.Address()), time.Second)
+
+		assert.Error(t, p.Print(context.Background(), ticket))
+	})
+	t.Run("respects context", func(t *testing.T) {
+		standIn := NewStandInPrinter(t, statusOK, statusOK, true)
+		defer standIn.Close()
+		p := New("printer 1", TCPDialer(standIn.Address()), time.Minute)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := p.Print(ctx, ticket)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
@@ -0,0 +1,206 @@
+package printer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Contargo/chamqp"
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrPrintFailed is returned if the print service reports a failed print.
+	ErrPrintFailed = errors.New("print service failed to print ticket")
+	// ErrUnconfirmed is returned if the print service does not confirm a
+	// print in time.
+	ErrUnconfirmed = errors.New("print service did not confirm print")
+)
+
+// DefaultConfirmTimeout is the time the print service has to confirm a print,
+// unless configured otherwise.
+const DefaultConfirmTimeout = 30 * time.Second
+
+const (
+	serviceCommandExchange = "gatecontrol.print.command"
+	serviceEventExchange   = "gatecontrol.event"
+	rkPrintRequest         = "print.request"
+	rkPrintCompleted       = "print.completed"
+	rkPrintFailed          = "print.failed"
+)
+
+type printRequest struct {
+	Title        string            `json:"title"`
+	Printer      string            `json:"printer"`
+	Token        string            `json:"token"`
+	Location     string            `json:"location"`
+	Loadingplace int64             `json:"loadingplaceId"`
+	Gate         string            `json:"gate"`
+	Purpose      string            `json:"purpose"`
+	Time         time.Time         `json:"time"`
+	Details      map[string]string `json:"details"`
+}
+
+type printFailed struct {
+	Reason string `json:"reason"`
+}
+
+// A Service is a central print service printing tickets on request. Requests
+// are published to the broker, the service confirms them with a print.completed
+// or print.failed event correlated by the correlation ID of the request.
+type Service struct {
+	name    string
+	timeout time.Duration
+	publish func(amqp.Publishing) error
+	events  chan amqp.Delivery
+	errChan chan error
+
+	mu      sync.Mutex
+	pending map[string]chan error
+}
+
+// NewService creates a client of the print service, consuming print events
+// on queue. Listen must be running to receive confirmations.
+func NewService(conn *chamqp.Connection, name, queue string, timeout time.Duration) *Service {
+	ch := conn.Channel()
+	s := newService(name, timeout, func(msg amqp.Publishing) error {
+		return ch.Publish(serviceCommandExchange, rkPrintRequest, false, false, msg)
+	})
+
+	ch.ExchangeDeclare(serviceCommandExchange, "topic", true, false, false, false, nil, s.errChan)
+	ch.ExchangeDeclare(serviceEventExchange, "topic", true, false, false, false, nil, s.errChan)
+	ch.QueueDeclare(queue, false, true, false, false, nil, nil, nil)
+	ch.QueueBind(queue, rkPrintCompleted, serviceEventExchange, false, nil, nil)
+	ch.QueueBind(queue, rkPrintFailed, serviceEventExchange, false, nil, nil)
+	ch.Consume(queue, "", false, false, false, false, nil, s.events, nil)
+	return s
+}
+
+func newService(name string, timeout time.Duration, publish func(amqp.Publishing) error) *Service {
+	if timeout <= 0 {
+		timeout = DefaultConfirmTimeout
+	}
+	return &Service{
+		name:    name,
+		timeout: timeout,
+		publish: publish,
+		events:  make(chan amqp.Delivery),
+		errChan: make(chan error),
+		pending: map[string]chan error{},
+	}
+}
+
+// Listen dispatches print events to the pending prints until shutdownChan is
+// closed. Channel errors are logged, the events are consumed again once the
+// connection is reestablished.
+func (s *Service) Listen(shutdownChan chan struct{}) {
+	for {
+		select {
+		case msg := <-s.events:
+			s.dispatch(msg)
+			msg.Ack(false)
+		case err := <-s.errChan:
+			log.Printf("Failed to listen for print events: %v", err)
+		case <-shutdownChan:
+			return
+		}
+	}
+}
+
+func (s *Service) dispatch(msg amqp.Delivery) {
+	s.mu.Lock()
+	resultChan, ok := s.pending[msg.CorrelationId]
+	delete(s.pending, msg.CorrelationId)
+	s.mu.Unlock()
+	if !ok {
+		// Confirmation of another agent, or of a print that timed out.
+		return
+	}
+
+	switch msg.RoutingKey {
+	case rkPrintCompleted:
+		resultChan <- nil
+	default:
+		var failed printFailed
+		if err := json.Unmarshal(msg.Body, &failed); err != nil || failed.Reason == "" {
+			resultChan <- ErrPrintFailed
+			return
+		}
+		resultChan <- fmt.Errorf("%w: %s", ErrPrintFailed, failed.Reason)
+	}
+}
+
+// Name returns the name of the printer.
+func (s *Service) Name() string {
+	return s.name
+}
+
+// Print asks the print service to print t and waits for its confirmation.
+func (s *Service) Print(ctx context.Context, t Ticket) error {
+	id, err := correlationID()
+	if err != nil {
+		return fmt.Errorf("printer %s: %v", s.name, err)
+	}
+	payload, err := json.Marshal(printRequest{
+		Title:        t.Title,
+		Printer:      s.name,
+		Token:        t.Token,
+		Location:     t.Location,
+		Loadingplace: t.LoadingPlace,
+		Gate:         t.Gate,
+		Purpose:      t.Purpose,
+		Time:         t.Time,
+		Details:      t.Details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal json: %v", err)
+	}
+
+	resultChan := make(chan error, 1)
+	s.mu.Lock()
+	s.pending[id] = resultChan
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	log.Printf("[%s] Requesting ticket from print service %s.", t.Token, s.name)
+	err = s.publish(amqp.Publishing{
+		ContentType:   "application/json",
+		CorrelationId: id,
+		Body:          payload,
+	})
+	if err != nil {
+		return fmt.Errorf("printer %s: %v", s.name, err)
+	}
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-resultChan:
+		if err != nil {
+			return fmt.Errorf("printer %s: %w", s.name, err)
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("printer %s: %w", s.name, ErrUnconfirmed)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func correlationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package printer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// StandInService answers print requests with an event of the given routing
+// key and body, or not at all if the routing key is empty.
+func StandInService(s **Service, routingKey, body string, requests chan<- printRequest) func(amqp.Publishing) error {
+	return func(msg amqp.Publishing) error {
+		var req printRequest
+		if err := json.Unmarshal(msg.Body, &req); err != nil {
+			return err
+		}
+		requests <- req
+		if routingKey != "" {
+			go (*s).dispatch(amqp.Delivery{
+				CorrelationId: msg.CorrelationId,
+				RoutingKey:    routingKey,
+				Body:          []byte(body),
+			})
+		}
+		return nil
+	}
+}
+
+func TestService(t *testing.T) {
+	t.Run("prints", func(t *testing.T) {
+		var s *Service
+		requests := make(chan printRequest, 1)
+		s = newService("central", time.Second, StandInService(&s, rkPrintCompleted, "{}", requests))
+
+		assert.NoError(t, s.Print(context.Background(), ticket))
+
+		req := <-requests
+		assert.Equal(t, "central", req.Printer)
+		assert.Equal(t, ticket.Token, req.Token)
+		assert.Equal(t, ticket.Details, req.Details)
+	})
+	t.Run("fails", func(t *testing.T) {
+		var s *Service
+		requests := make(chan printRequest, 1)
+		s = newService("central", time.Second, StandInService(&s, rkPrintFailed, `{"reason":"paper out"}`, requests))
+
+		err := s.Print(context.Background(), ticket)
+
+		assert.True(t, errors.Is(err, ErrPrintFailed))
+		assert.Contains(t, err.Error(), "paper out")
+	})
+	t.Run("times out", func(t *testing.T) {
+		var s *Service
+		requests := make(chan printRequest, 1)
+		s = newService("central", 50*time.Millisecond, StandInService(&s, "", "", requests))
+
+		err := s.Print(context.Background(), ticket)
+
+		assert.True(t, errors.Is(err, ErrUnconfirmed))
+		assert.Empty(t, s.pending)
+	})
+	t.Run("respects context", func(t *testing.T) {
+		var s *Service
+		requests := make(chan printRequest, 1)
+		s = newService("central", time.Second, StandInService(&s, "", "", requests))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := s.Print(ctx, ticket)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+	t.Run("keeps listening after channel errors", func(t *testing.T) {
+		s := newService("central", time.Second, nil)
+		result := make(chan error, 1)
+		s.pending["4711"] = result
+		shutdownChan := make(chan struct{})
+		defer close(shutdownChan)
+		go s.Listen(shutdownChan)
+
+		s.errChan <- errors.New("channel closed")
+		s.events <- amqp.Delivery{CorrelationId: "4711", RoutingKey: rkPrintCompleted}
+
+		assert.NoError(t, <-result)
+	})
+	t.Run("ignores other confirmations", func(t *testing.T) {
+		s := newService("central", time.Second, nil)
+
+		s.dispatch(amqp.Delivery{CorrelationId: "unknown", RoutingKey: rkPrintCompleted})
+
+		assert.Empty(t, s.pending)
+	})
+}
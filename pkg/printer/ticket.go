@@ -0,0 +1,110 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ESC/POS commands used to render tickets.
+var (
+	cmdInit        = []byte{0x1b, '@'}
+	cmdCodePage858 = []byte{0x1b, 't', 19}
+	cmdAlignLeft   = []byte{0x1b, 'a', 0}
+	cmdAlignCenter = []byte{0x1b, 'a', 1}
+	cmdDoubleSize  = []byte{0x1b, '!', 0x30}
+	cmdNormalSize  = []byte{0x1b, '!', 0x00}
+	cmdFeedLines   = []byte{0x1b, 'd', 4}
+	cmdPartialCut  = []byte{0x1d, 'V', 66, 0}
+)
+
+// A Ticket is the interchange ticket handed to the driver of a truck that
+// passed the gate.
+type Ticket struct {
+	Title        string
+	Token        string
+	Location     string
+	LoadingPlace int64
+	Gate         string
+	Purpose      string
+	Time         time.Time
+	// Details are provided by Gate-Control, e.g. the container or the lane
+	// to drive to. They are printed in the order of their keys.
+	Details map[string]string
+}
+
+// ESCPOS renders the ticket as ESC/POS commands, using code page 858.
+func (t Ticket) ESCPOS() []byte {
+	var b bytes.Buffer
+
+	b.Write(cmdInit)
+	b.Write(cmdCodePage858)
+
+	b.Write(cmdAlignCenter)
+	b.Write(cmdDoubleSize)
+	writeLine(&b, t.Title)
+	b.Write(cmdNormalSize)
+	writeLine(&b, "")
+
+	b.Write(cmdAlignLeft)
+	writeField(&b, "Terminal", fmt.Sprintf("%s (%d)", t.Location, t.LoadingPlace))
+	writeField(&b, "Gate", fmt.Sprintf("%s (%s)", t.Gate, t.Purpose))
+	writeField(&b, "Time", t.Time.Format("2006-01-02 15:04:05"))
+	writeField(&b, "Token", t.Token)
+
+	if len(t.Details) > 0 {
+		writeLine(&b, "")
+		keys := make([]string, 0, len(t.Details))
+		for key := range t.Details {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeField(&b, key, t.Details[key])
+		}
+	}
+
+	b.Write(cmdFeedLines)
+	b.Write(cmdPartialCut)
+	return b.Bytes()
+}
+
+func writeField(b *bytes.Buffer, label, value string) {
+	writeLine(b, fmt.Sprintf("%-11s%s", label+":", value))
+}
+
+func writeLine(b *bytes.Buffer, s string) {
+	b.Write(encode(s))
+	b.WriteByte('\n')
+}
+
+// cp858 maps the non-ASCII characters printable in code page 858 that are
+// likely to show up on tickets.
+var cp858 = map[rune]byte{
+	'Ä': 0x8e, 'Ö': 0x99, 'Ü': 0x9a,
+	'ä': 0x84, 'ö': 0x94, 'ü': 0x81,
+	'ß': 0xe1, 'é': 0x82, 'è': 0x8a,
+	'€': 0xd5,
+}
+
+// encode encodes s in code page 858. Unknown characters are replaced by '?',
+// control characters are dropped so text cannot inject printer commands.
+func encode(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			continue
+		case r < 0x80:
+			b = append(b, byte(r))
+		default:
+			if c, ok := cp858[r]; ok {
+				b = append(b, c)
+			} else {
+				b = append(b, '?')
+			}
+		}
+	}
+	return b
+}
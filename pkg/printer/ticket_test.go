@@ -0,0 +1,28 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTicket_ESCPOS(t *testing.T) {
+	t.Run("renders fields and details", func(t *testing.T) {
+		b := ticket.ESCPOS()
+
+		assert.True(t, bytes.HasPrefix(b, cmdInit))
+		assert.True(t, bytes.HasSuffix(b, cmdPartialCut))
+		assert.Contains(t, string(b), "Terminal:  DEKOB (42)\n")
+		assert.Contains(t, string(b), "Gate:      entry-1 (entry)\n")
+		assert.Contains(t, string(b), "Time:      2021-03-01 12:00:00\n")
+		assert.Contains(t, string(b), "Token:     4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5\n")
+		assert.Contains(t, string(b), "container: CTGU1234567\nlane:      3\n")
+	})
+	t.Run("encodes code page 858", func(t *testing.T) {
+		assert.Equal(t, []byte{'S', 't', 'r', 0x84, 0xe1, 'e', ' ', '?'}, encode("Sträße ☃"))
+	})
+	t.Run("drops control characters", func(t *testing.T) {
+		assert.Equal(t, []byte("cut"), encode("\x1dc\x1but\n"))
+	})
+}
@@ -3,6 +3,8 @@ package rescan
 import (
 	"contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
 	worker "contargo.net/gatecontrol/gatecontrol-agent/pkg/agent"
+	"contargo.net/gatecontrol/gatecontrol-agent/pkg/gatedriver"
+	"context"
 	"log"
 	"sync"
 	"time"
@@ -21,6 +23,7 @@ Provide method handleReentry(tokne) -> bool
 
 type lastTokenScan struct {
 	token       string
+	source      string
 	scannedTime time.Time
 }
 
@@ -61,7 +64,12 @@ func (r *RescanHandler) HandleReentry(token string) bool {
 			return false
 		}
 		log.Println("Token still valid, (re)opening gate")
-		if err := r.gate.Open(); err != nil {
+		ctx := gatedriver.NewContext(context.Background(), gatedriver.Request{
+			Token:      token,
+			ScanSource: r.lastToken.source,
+			Trigger:    gatedriver.TriggerReentry,
+		})
+		if err := r.gate.OpenContext(ctx); err != nil {
 			log.Println("Opening gate returned err", err)
 		}
 		r.manualDataChan <- true
@@ -77,11 +85,12 @@ func (r *RescanHandler) Listen() {
 		select {
 		case fsmData := <-r.dataChan:
 			r.mutex.Lock()
-			fsmDataCasted := fsmData.(worker.FsmScanRequest)
-			if fsmDataCasted.State == worker.StateGating {
+			fsmDataCasted, ok := fsmData.(worker.FsmScanRequest)
+			if ok && fsmDataCasted.State == worker.StateGating {
 				token := fsmDataCasted.ScanRequest.Token()
 				r.lastToken = &lastTokenScan{
 					token,
+					fsmDataCasted.ScanRequest.Source(),
 					time.Now(),
 				}
 			}
@@ -0,0 +1,54 @@
+package scanner
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// A Backoff describes the delays between reopen attempts of a scanner. The
+// delay starts at Initial and grows by Multiplier with every failed attempt
+// up to Max. Each delay is randomly varied by the fraction Jitter.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// DefaultBackoff is used by reopening scanners, unless configured otherwise.
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        time.Minute,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Validate returns an error if the backoff is not usable.
+func (b Backoff) Validate() error {
+	if b.Initial <= 0 {
+		return fmt.Errorf("initial delay must be positive: %v", b.Initial)
+	}
+	if b.Max < b.Initial {
+		return fmt.Errorf("maximum delay %v is less than initial delay %v", b.Max, b.Initial)
+	}
+	if b.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1: %v", b.Multiplier)
+	}
+	if b.Jitter < 0 || b.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1: %v", b.Jitter)
+	}
+	return nil
+}
+
+// Delay returns the delay before the next attempt after the given number of
+// failed attempts.
+func (b Backoff) Delay(attempts int) time.Duration {
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempts))
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	d += d * b.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(d)
+}
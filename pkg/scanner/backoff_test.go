@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	t.Run("grows exponentially up to maximum", func(t *testing.T) {
+		b := Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+		assert.Equal(t, time.Second, b.Delay(0))
+		assert.Equal(t, 2*time.Second, b.Delay(1))
+		assert.Equal(t, 8*time.Second, b.Delay(3))
+		assert.Equal(t, 10*time.Second, b.Delay(4))
+		assert.Equal(t, 10*time.Second, b.Delay(100))
+	})
+	t.Run("varies delay by jitter", func(t *testing.T) {
+		b := Backoff{Initial: time.Second, Max: time.Second, Multiplier: 1, Jitter: 0.5}
+		for i := 0; i < 100; i++ {
+			d := b.Delay(i)
+			assert.True(t, d >= 500*time.Millisecond && d <= 1500*time.Millisecond, "delay %v", d)
+		}
+	})
+}
+
+func TestBackoff_Validate(t *testing.T) {
+	assert.NoError(t, DefaultBackoff.Validate())
+	assert.Error(t, Backoff{Initial: 0, Max: time.Second, Multiplier: 1}.Validate())
+	assert.Error(t, Backoff{Initial: time.Minute, Max: time.Second, Multiplier: 1}.Validate())
+	assert.Error(t, Backoff{Initial: time.Second, Max: time.Second, Multiplier: 0.5}.Validate())
+	assert.Error(t, Backoff{Initial: time.Second, Max: time.Second, Multiplier: 1, Jitter: 2}.Validate())
+}
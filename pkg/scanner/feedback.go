@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrNotOpen is returned when commands are sent to a scanner that is
+// currently not open.
+var ErrNotOpen = errors.New("scanner is not open")
+
+// A Feedback signals the outcome of a scan at the scanner device, e.g. with a
+// beep, a LED colour or a vibration.
+type Feedback interface {
+	Good() error
+	Bad() error
+}
+
+// FeedbackCommands are the host commands a scanner model understands for a
+// good and a bad read.
+type FeedbackCommands struct {
+	Good []byte
+	Bad  []byte
+}
+
+// NewFeedbackCommands creates feedback commands from their configured
+// representation. Commands may contain Go escape sequences like \r or \x1b.
+func NewFeedbackCommands(good, bad string) (FeedbackCommands, error) {
+	g, err := unescapeCommand(good)
+	if err != nil {
+		return FeedbackCommands{}, err
+	}
+	b, err := unescapeCommand(bad)
+	if err != nil {
+		return FeedbackCommands{}, err
+	}
+	return FeedbackCommands{Good: g, Bad: b}, nil
+}
+
+func unescapeCommand(cmd string) ([]byte, error) {
+	if cmd == "" {
+		return nil, nil
+	}
+	s, err := strconv.Unquote(`"` + cmd + `"`)
+	if err != nil {
+		return nil, errors.New("invalid command: " + cmd)
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type DummyReadWriteCloser struct {
+	DummyReadCloser
+	written bytes.Buffer
+}
+
+func (rwc *DummyReadWriteCloser) Write(p []byte) (int, error) {
+	return rwc.written.Write(p)
+}
+
+func TestNewFeedbackCommands(t *testing.T) {
+	t.Run("unescapes commands", func(t *testing.T) {
+		cmds, err := NewFeedbackCommands(`\x16M\rBEEP1.`, `\x07`)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("\x16M\rBEEP1."), cmds.Good)
+		assert.Equal(t, []byte{0x07}, cmds.Bad)
+	})
+	t.Run("allows empty commands", func(t *testing.T) {
+		cmds, err := NewFeedbackCommands("", "")
+		assert.NoError(t, err)
+		assert.Nil(t, cmds.Good)
+		assert.Nil(t, cmds.Bad)
+	})
+	t.Run("returns error for invalid escapes", func(t *testing.T) {
+		_, err := NewFeedbackCommands(`\q`, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestScanner_Write(t *testing.T) {
+	t.Run("writes to writable devices", func(t *testing.T) {
+		rwc := &DummyReadWriteCloser{}
+		scanner, _ := Listen("test", "", rwc)
+		_, err := scanner.Write([]byte("cmd"))
+		assert.NoError(t, err)
+		assert.Equal(t, "cmd", rwc.written.String())
+	})
+	t.Run("returns error for read-only devices", func(t *testing.T) {
+		scanner, _ := Listen("test", "", &DummyReadCloser{})
+		_, err := scanner.Write([]byte("cmd"))
+		assert.Equal(t, ErrNotWritable, err)
+	})
+}
+
+func TestReopeningScanner_Feedback(t *testing.T) {
+	t.Run("sends commands to open scanner", func(t *testing.T) {
+		rwc := &DummyReadWriteCloser{DummyReadCloser: DummyReadCloser{value: "token\n"}}
+		scanner := NewReopeningScanner("scanner", func() (*Scanner, error) {
+			return Listen("scanner", "", rwc)
+		})
+		scanner.SetFeedback(FeedbackCommands{Good: []byte("good"), Bad: []byte("bad")})
+
+		ch := make(chan Token)
+		scanner.NotifyTokens(ch)
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+		<-ch
+
+		assert.NoError(t, scanner.Good())
+		assert.NoError(t, scanner.Bad())
+		assert.Equal(t, "goodbad", rwc.written.String())
+	})
+	t.Run("returns error if scanner is not open", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", FailingOpener(""))
+		scanner.SetFeedback(FeedbackCommands{Good: []byte("good")})
+		assert.Equal(t, ErrNotOpen, scanner.Good())
+	})
+	t.Run("does nothing without commands", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", FailingOpener(""))
+		assert.NoError(t, scanner.Bad())
+	})
+}
@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// A FramingMode defines how the byte stream of a scanner is split into tokens.
+type FramingMode string
+
+const (
+	// FramingEOL terminates a frame on CR, LF or CRLF.
+	FramingEOL FramingMode = "eol"
+	// FramingCR terminates a frame on CR.
+	FramingCR FramingMode = "cr"
+	// FramingLF terminates a frame on LF.
+	FramingLF FramingMode = "lf"
+	// FramingCRLF terminates a frame on CRLF.
+	FramingCRLF FramingMode = "crlf"
+	// FramingSTXETX encloses a frame in STX and ETX.
+	FramingSTXETX FramingMode = "stx-etx"
+	// FramingFixed reads frames of a fixed length.
+	FramingFixed FramingMode = "fixed"
+	// FramingIdle terminates a frame when no data arrived for the idle gap.
+	FramingIdle FramingMode = "idle"
+)
+
+const (
+	stx = 0x02
+	etx = 0x03
+
+	// DefaultMaxFrameLength is the maximum length of a frame, unless
+	// configured otherwise.
+	DefaultMaxFrameLength = 1024
+	// DefaultIdleGap is the idle gap used for FramingIdle, unless configured
+	// otherwise.
+	DefaultIdleGap = 50 * time.Millisecond
+)
+
+// A Framing describes how frames are cut from the byte stream of a scanner.
+type Framing struct {
+	Mode      FramingMode
+	Length    int
+	IdleGap   time.Duration
+	MaxLength int
+}
+
+// DefaultFraming terminates frames on CR, LF or CRLF.
+var DefaultFraming = Framing{Mode: FramingEOL, MaxLength: DefaultMaxFrameLength}
+
+// NewFraming returns the framing identified by mode. Length is only used by
+// FramingFixed, idleGap only by FramingIdle. A zero maxLength or idleGap
+// selects the default.
+func NewFraming(mode string, length int, idleGap time.Duration, maxLength int) (Framing, error) {
+	if mode == "" {
+		mode = string(FramingEOL)
+	}
+	if maxLength == 0 {
+		maxLength = DefaultMaxFrameLength
+	}
+	if maxLength < 0 {
+		return Framing{}, fmt.Errorf("invalid maximum frame length: %d", maxLength)
+	}
+
+	f := Framing{Mode: FramingMode(mode), MaxLength: maxLength}
+
+	switch f.Mode {
+	case FramingEOL, FramingCR, FramingLF, FramingCRLF, FramingSTXETX:
+	case FramingFixed:
+		if length <= 0 || length > maxLength {
+			return Framing{}, fmt.Errorf("invalid frame length for fixed framing: %d", length)
+		}
+		f.Length = length
+	case FramingIdle:
+		if idleGap == 0 {
+			idleGap = DefaultIdleGap
+		}
+		if idleGap < 0 {
+			return Framing{}, fmt.Errorf("invalid idle gap: %v", idleGap)
+		}
+		f.IdleGap = idleGap
+	default:
+		return Framing{}, fmt.Errorf("undefined framing: %s", mode)
+	}
+
+	return f, nil
+}
+
+// split cuts the next frame from buf. It returns the frame, the number of
+// bytes consumed and whether a complete frame was found. Frames of
+// FramingIdle are never complete, they are cut when the idle gap elapsed.
+func (f Framing) split(buf []byte) ([]byte, int, bool) {
+	switch f.Mode {
+	case FramingEOL:
+		if i := bytes.IndexAny(buf, "\r\n"); i >= 0 {
+			return buf[:i], i + 1, true
+		}
+	case FramingCR:
+		if i := bytes.IndexByte(buf, '\r'); i >= 0 {
+			return buf[:i], i + 1, true
+		}
+	case FramingLF:
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			return buf[:i], i + 1, true
+		}
+	case FramingCRLF:
+		if i := bytes.Index(buf, []byte("\r\n")); i >= 0 {
+			return buf[:i], i + 2, true
+		}
+	case FramingSTXETX:
+		start := bytes.IndexByte(buf, stx)
+		if start < 0 {
+			// Discard everything outside of a frame.
+			return nil, len(buf), false
+		}
+		if end := bytes.IndexByte(buf[start:], etx); end >= 0 {
+			return buf[start+1 : start+end], start + end + 1, true
+		}
+		return nil, start, false
+	case FramingFixed:
+		if len(buf) >= f.Length {
+			return buf[:f.Length], f.Length, true
+		}
+	}
+	return nil, 0, false
+}
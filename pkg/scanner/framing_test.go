@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ChunkedReadCloser struct {
+	chunks []string
+	closed bool
+}
+
+func (rc *ChunkedReadCloser) Read(buf []byte) (int, error) {
+	if rc.closed {
+		return 0, errors.New("closed")
+	}
+	if len(rc.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(buf, rc.chunks[0])
+	rc.chunks[0] = rc.chunks[0][n:]
+	if rc.chunks[0] == "" {
+		rc.chunks = rc.chunks[1:]
+	}
+	return n, nil
+}
+
+func (rc *ChunkedReadCloser) Close() error {
+	rc.closed = true
+	return nil
+}
+
+func acceptAll(s *Scanner) []string {
+	var tokens []string
+	for {
+		token, err := s.Accept()
+		if err != nil {
+			return tokens
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func mustFraming(t *testing.T, mode string, length int, idleGap time.Duration) Framing {
+	framing, err := NewFraming(mode, length, idleGap, 0)
+	assert.NoError(t, err)
+	return framing
+}
+
+func TestNewFraming(t *testing.T) {
+	t.Run("defaults to eol framing", func(t *testing.T) {
+		framing, err := NewFraming("", 0, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultFraming, framing)
+	})
+	t.Run("defaults idle gap", func(t *testing.T) {
+		framing, err := NewFraming("idle", 0, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultIdleGap, framing.IdleGap)
+	})
+	t.Run("returns error for undefined framing", func(t *testing.T) {
+		_, err := NewFraming("x", 0, 0, 0)
+		assert.Error(t, err)
+	})
+	t.Run("returns error for fixed framing without length", func(t *testing.T) {
+		_, err := NewFraming("fixed", 0, 0, 0)
+		assert.Error(t, err)
+	})
+	t.Run("returns error for fixed length exceeding maximum", func(t *testing.T) {
+		_, err := NewFraming("fixed", 20, 0, 10)
+		assert.Error(t, err)
+	})
+}
+
+func TestScanner_AcceptFramed(t *testing.T) {
+	t.Run("joins tokens split across reads", func(t *testing.T) {
+		scanner, _ := Listen("test", "", &ChunkedReadCloser{chunks: []string{"first-", "token\r\n"}})
+		assert.Equal(t, []string{"first-token"}, acceptAll(scanner))
+	})
+	t.Run("splits tokens from a single read", func(t *testing.T) {
+		scanner, _ := Listen("test", "", &ChunkedReadCloser{chunks: []string{"one\rtwo\rthree\r"}})
+		assert.Equal(t, []string{"one", "two", "three"}, acceptAll(scanner))
+	})
+	t.Run("handles tokens longer than a single read", func(t *testing.T) {
+		long := ""
+		for i := 0; i < 100; i++ {
+			long += "0123456789"
+		}
+		scanner, _ := Listen("test", "", &ChunkedReadCloser{chunks: []string{long[:300], long[300:], "\n"}})
+		assert.Equal(t, []string{long}, acceptAll(scanner))
+	})
+	t.Run("discards data exceeding maximum length", func(t *testing.T) {
+		framing, _ := NewFraming("lf", 0, 0, 8)
+		scanner, _ := ListenFramed("test", "", framing, &ChunkedReadCloser{chunks: []string{"0123456789", "abc\ntoken\n"}})
+		assert.Equal(t, []string{"abc", "token"}, acceptAll(scanner))
+	})
+	t.Run("cuts frames on cr only", func(t *testing.T) {
+		framing := mustFraming(t, "cr", 0, 0)
+		scanner, _ := ListenFramed("test", "", framing, &ChunkedReadCloser{chunks: []string{"one\ntwo\r"}})
+		assert.Equal(t, []string{"one\ntwo"}, acceptAll(scanner))
+	})
+	t.Run("cuts frames on crlf", func(t *testing.T) {
+		framing := mustFraming(t, "crlf", 0, 0)
+		scanner, _ := ListenFramed("test", "", framing, &ChunkedReadCloser{chunks: []string{"one\r", "\ntwo\r\n"}})
+		assert.Equal(t, []string{"one", "two"}, acceptAll(scanner))
+	})
+	t.Run("cuts frames enclosed in stx and etx", func(t *testing.T) {
+		framing := mustFraming(t, "stx-etx", 0, 0)
+		scanner, _ := ListenFramed("test", "", framing, &ChunkedReadCloser{chunks: []string{"noise\x02one\x03\x02tw", "o\x03"}})
+		assert.Equal(t, []string{"one", "two"}, acceptAll(scanner))
+	})
+	t.Run("cuts frames of fixed length", func(t *testing.T) {
+		framing := mustFraming(t, "fixed", 4, 0)
+		scanner, _ := ListenFramed("test", "", framing, &ChunkedReadCloser{chunks: []string{"abcdef", "gh"}})
+		assert.Equal(t, []string{"abcd", "efgh"}, acceptAll(scanner))
+	})
+	t.Run("cuts frames on idle gaps", func(t *testing.T) {
+		framing := mustFraming(t, "idle", 0, 20*time.Millisecond)
+		r, w := io.Pipe()
+		go func() {
+			for _, chunk := range []string{"one", "-token", "", "two", "", "three"} {
+				if chunk == "" {
+					time.Sleep(50 * time.Millisecond)
+					continue
+				}
+				w.Write([]byte(chunk))
+			}
+			w.Close()
+		}()
+		scanner, _ := ListenFramed("test", "", framing, r)
+		assert.Equal(t, []string{"one-token", "two", "three"}, acceptAll(scanner))
+	})
+	t.Run("strips configured prefix from every frame", func(t *testing.T) {
+		scanner, _ := Listen("test", "IN", &ChunkedReadCloser{chunks: []string{"INone\nINtwo\n"}})
+		assert.Equal(t, []string{"one", "two"}, acceptAll(scanner))
+	})
+}
@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	// ErrProbeFailed is the error of a scanner that did not answer probes.
+	ErrProbeFailed = errors.New("scanner did not answer probe")
+	// ErrIdle is the error of a scanner that read no data for too long
+	// during operating hours.
+	ErrIdle = errors.New("scanner read no data")
+)
+
+const (
+	// DefaultProbeInterval is the interval between probes, unless configured
+	// otherwise.
+	DefaultProbeInterval = 30 * time.Second
+	// DefaultProbeFailures is the number of unanswered probes after which a
+	// scanner is considered down, unless configured otherwise.
+	DefaultProbeFailures = 3
+
+	idleCheckInterval = time.Minute
+)
+
+// Liveness configures how a scanner that is open, but hangs, is detected.
+//
+// If a probe command is set, it is sent to the scanner every probe interval
+// and the scanner must answer with a frame matching the probe response before
+// the next probe. A scanner missing an answer is DEGRADED, after
+// ProbeFailures missed answers it is DOWN and reopened.
+//
+// If MaxIdle is set, a scanner that read no data for MaxIdle during operating
+// hours is DEGRADED until it reads again.
+type Liveness struct {
+	ProbeCommand   []byte
+	ProbeResponse  *regexp.Regexp
+	ProbeInterval  time.Duration
+	ProbeFailures  int
+	MaxIdle        time.Duration
+	OperatingHours OperatingHours
+}
+
+// NewLiveness creates a liveness configuration. Commands may contain Go escape
+// sequences like \r or \x1b. A zero interval or number of failures selects the
+// default.
+func NewLiveness(probe, response string, interval time.Duration, failures int, maxIdle time.Duration, hours OperatingHours) (Liveness, error) {
+	cmd, err := unescapeCommand(probe)
+	if err != nil {
+		return Liveness{}, err
+	}
+	if len(cmd) > 0 && response == "" {
+		return Liveness{}, errors.New("probe requires a probe response")
+	}
+	if interval == 0 {
+		interval = DefaultProbeInterval
+	}
+	if failures == 0 {
+		failures = DefaultProbeFailures
+	}
+	if interval < 0 || failures < 0 || maxIdle < 0 {
+		return Liveness{}, errors.New("probe interval, failures and maximum idle time must not be negative")
+	}
+
+	l := Liveness{
+		ProbeCommand:   cmd,
+		ProbeInterval:  interval,
+		ProbeFailures:  failures,
+		MaxIdle:        maxIdle,
+		OperatingHours: hours,
+	}
+	if response != "" {
+		if l.ProbeResponse, err = regexp.Compile(response); err != nil {
+			return Liveness{}, fmt.Errorf("invalid probe response: %v", err)
+		}
+	}
+	return l, nil
+}
+
+func (l Liveness) probing() bool {
+	return len(l.ProbeCommand) > 0
+}
+
+func (l Liveness) enabled() bool {
+	return l.probing() || l.MaxIdle > 0
+}
+
+// checkInterval returns the interval the liveness of a scanner is checked in.
+func (l Liveness) checkInterval() time.Duration {
+	if l.probing() {
+		return l.ProbeInterval
+	}
+	return idleCheckInterval
+}
+
+// OperatingHours is a daily time range, e.g. 06:00-22:00. The range may span
+// midnight. The zero value covers the whole day.
+type OperatingHours struct {
+	Start, End time.Duration
+}
+
+// ParseOperatingHours parses a time range like 06:00-22:00. An empty range
+// covers the whole day.
+func ParseOperatingHours(s string) (OperatingHours, error) {
+	if s == "" {
+		return OperatingHours{}, nil
+	}
+
+	var startH, startM, endH, endM int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &startH, &startM, &endH, &endM); err != nil {
+		return OperatingHours{}, fmt.Errorf("invalid operating hours: %s", s)
+	}
+	for _, h := range []int{startH, endH} {
+		if h < 0 || h > 24 {
+			return OperatingHours{}, fmt.Errorf("invalid operating hours: %s", s)
+		}
+	}
+	for _, m := range []int{startM, endM} {
+		if m < 0 || m > 59 {
+			return OperatingHours{}, fmt.Errorf("invalid operating hours: %s", s)
+		}
+	}
+
+	return OperatingHours{
+		Start: time.Duration(startH)*time.Hour + time.Duration(startM)*time.Minute,
+		End:   time.Duration(endH)*time.Hour + time.Duration(endM)*time.Minute,
+	}, nil
+}
+
+// Contains reports whether t is within the operating hours, in the location
+// of t.
+func (h OperatingHours) Contains(t time.Time) bool {
+	if h.Start == h.End {
+		return true
+	}
+
+	y, m, d := t.Date()
+	sinceMidnight := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	if h.Start < h.End {
+		return sinceMidnight >= h.Start && sinceMidnight < h.End
+	}
+	return sinceMidnight >= h.Start || sinceMidnight < h.End
+}
@@ -0,0 +1,182 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ProbedDevice is a scanner device answering probes with answer, unless
+// answer is empty.
+type ProbedDevice struct {
+	*io.PipeReader
+	w      *io.PipeWriter
+	answer string
+}
+
+func NewProbedDevice(answer string) *ProbedDevice {
+	r, w := io.Pipe()
+	return &ProbedDevice{PipeReader: r, w: w, answer: answer}
+}
+
+func (d *ProbedDevice) Write(p []byte) (int, error) {
+	if d.answer != "" {
+		go d.w.Write([]byte(d.answer))
+	}
+	return len(p), nil
+}
+
+func (d *ProbedDevice) Close() error {
+	d.w.Close()
+	return d.PipeReader.Close()
+}
+
+func ProbedOpener(answer string) Opener {
+	return Opener(func() (*Scanner, error) {
+		return Listen("scanner", "", NewProbedDevice(answer))
+	})
+}
+
+var testLiveness = Liveness{
+	ProbeCommand:  []byte("?"),
+	ProbeResponse: regexp.MustCompile("^OK$"),
+	ProbeInterval: 10 * time.Millisecond,
+	ProbeFailures: 2,
+}
+
+func TestReopeningScanner_Liveness(t *testing.T) {
+	t.Run("stays up while probes are answered", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", ProbedOpener("OK\n"))
+		scanner.SetLiveness(testLiveness)
+		tokens := make(chan Token, 10)
+		scanner.NotifyTokens(tokens)
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+
+		time.Sleep(100 * time.Millisecond)
+
+		assert.Equal(t, StateUp, scanner.Status().State)
+		assert.Empty(t, tokens)
+	})
+	t.Run("goes degraded and down on unanswered probes", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", ProbedOpener(""))
+		scanner.SetLiveness(testLiveness)
+		statuses := make(chan Status, 10)
+		scanner.NotifyStatus(statuses)
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+
+		assert.Equal(t, StateUp, (<-statuses).State)
+		degraded := <-statuses
+		assert.Equal(t, StateDegraded, degraded.State)
+		assert.True(t, errors.Is(degraded.Error, ErrProbeFailed))
+		down := <-statuses
+		assert.Equal(t, StateDown, down.State)
+		assert.True(t, errors.Is(down.Error, ErrProbeFailed))
+	})
+	t.Run("goes degraded when idle during operating hours", func(t *testing.T) {
+		device := NewProbedDevice("")
+		scanner := NewReopeningScanner("scanner", Opener(func() (*Scanner, error) {
+			return Listen("scanner", "", device)
+		}))
+		scanner.SetLiveness(Liveness{MaxIdle: time.Hour})
+		statuses := make(chan Status, 10)
+		scanner.NotifyStatus(statuses)
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+
+		assert.Equal(t, StateUp, (<-statuses).State)
+
+		scanner.checkLiveness(time.Now().Add(30 * time.Minute))
+		assert.Equal(t, StateUp, scanner.Status().State)
+
+		scanner.checkLiveness(time.Now().Add(2 * time.Hour))
+		degraded := <-statuses
+		assert.Equal(t, StateDegraded, degraded.State)
+		assert.True(t, errors.Is(degraded.Error, ErrIdle))
+
+		device.w.Write([]byte("token\n"))
+		assert.Equal(t, StateUp, (<-statuses).State)
+	})
+	t.Run("ignores idle scanners outside operating hours", func(t *testing.T) {
+		now := time.Now()
+		y, m, d := now.Date()
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+		scanner := NewReopeningScanner("scanner", DummyOpener("", ""))
+		scanner.liveness = Liveness{
+			MaxIdle:        time.Hour,
+			OperatingHours: OperatingHours{Start: 6 * time.Hour, End: 22 * time.Hour},
+		}
+		scanner.status.State = StateUp
+		scanner.openedAt = midnight
+
+		scanner.updateHealthLocked(midnight.Add(3 * time.Hour))
+		assert.Equal(t, StateUp, scanner.status.State)
+
+		scanner.updateHealthLocked(midnight.Add(7 * time.Hour))
+		assert.Equal(t, StateDegraded, scanner.status.State)
+	})
+}
+
+func TestNewLiveness(t *testing.T) {
+	t.Run("applies defaults", func(t *testing.T) {
+		l, err := NewLiveness(`\x1bS\r`, "^S0", 0, 0, 0, OperatingHours{})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("\x1bS\r"), l.ProbeCommand)
+		assert.Equal(t, DefaultProbeInterval, l.ProbeInterval)
+		assert.Equal(t, DefaultProbeFailures, l.ProbeFailures)
+		assert.True(t, l.enabled())
+	})
+	t.Run("is disabled by default", func(t *testing.T) {
+		l, err := NewLiveness("", "", 0, 0, 0, OperatingHours{})
+		assert.NoError(t, err)
+		assert.False(t, l.enabled())
+	})
+	t.Run("requires probe response", func(t *testing.T) {
+		_, err := NewLiveness("?", "", 0, 0, 0, OperatingHours{})
+		assert.Error(t, err)
+	})
+	t.Run("rejects invalid probe response", func(t *testing.T) {
+		_, err := NewLiveness("?", "(", 0, 0, 0, OperatingHours{})
+		assert.Error(t, err)
+	})
+}
+
+func TestOperatingHours(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, 3, 1, hour, min, 0, 0, time.UTC)
+	}
+
+	t.Run("parses ranges", func(t *testing.T) {
+		h, err := ParseOperatingHours("06:00-22:30")
+		assert.NoError(t, err)
+		assert.Equal(t, OperatingHours{Start: 6 * time.Hour, End: 22*time.Hour + 30*time.Minute}, h)
+		assert.True(t, h.Contains(at(6, 0)))
+		assert.True(t, h.Contains(at(22, 29)))
+		assert.False(t, h.Contains(at(22, 30)))
+		assert.False(t, h.Contains(at(5, 59)))
+	})
+	t.Run("spans midnight", func(t *testing.T) {
+		h, err := ParseOperatingHours("22:00-06:00")
+		assert.NoError(t, err)
+		assert.True(t, h.Contains(at(23, 0)))
+		assert.True(t, h.Contains(at(1, 0)))
+		assert.False(t, h.Contains(at(12, 0)))
+	})
+	t.Run("covers whole day by default", func(t *testing.T) {
+		h, err := ParseOperatingHours("")
+		assert.NoError(t, err)
+		assert.True(t, h.Contains(at(3, 0)))
+	})
+	t.Run("rejects invalid ranges", func(t *testing.T) {
+		for _, s := range []string{"6-22", "06:00-25:00", "06:60-22:00", "always"} {
+			_, err := ParseOperatingHours(s)
+			assert.Error(t, err, s)
+		}
+	})
+}
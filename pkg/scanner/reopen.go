@@ -2,11 +2,10 @@ package scanner
 
 import (
 	"context"
-	"strings"
+	"fmt"
+	"log"
 	"sync"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 const (
@@ -16,56 +15,40 @@ const (
 	StateUp string = "UP"
 	// StateDown represents the down state, aka the scanner is unhealthy.
 	StateDown string = "DOWN"
+	// StateDegraded represents the degraded state, aka the scanner is open
+	// but seems to hang.
+	StateDegraded string = "DEGRADED"
 )
 
 // A Opener can be used to (re-)open an instance of a scanner.
 type Opener func() (*Scanner, error)
 
+// A Locator returns the path of a scanner device. Openers consult it on every
+// (re-)open, so a device is found again after it moved to another path.
+type Locator func() (string, error)
+
+// StaticPath locates a scanner device at a fixed path.
+func StaticPath(path string) Locator {
+	return Locator(func() (string, error) {
+		return path, nil
+	})
+}
+
 // A Status represents the current state of an instance of a scanner.
 type Status struct {
 	Name  string
 	State string
 	Error error
-}
-
-type ScanSource string
-
-const (
-	Invalid       ScanSource = "INVALID"
-	Paper         ScanSource = "PAPER"
-	TruckersTrust ScanSource = "TRUCKERS_TRUST"
-)
-
-type Token struct {
-	Content    string
-	Scanner    string
-	ScanSource ScanSource
-}
-
-func getScanSource(rawContent string) ScanSource {
-	if strings.HasPrefix(rawContent, "tt-") {
-		return TruckersTrust
-	}
-	return Paper
-}
-
-func NewToken(rawContent, scanner string) *Token {
-	content := strings.Replace(rawContent, "tt-", "", 1)
-	return &Token{
-		content,
-		scanner,
-		getScanSource(rawContent),
-	}
-}
-
-func (t *Token) IsValidUUID() bool {
-	if strings.HasPrefix(t.Content, "tt-") {
-		replacedToken := strings.Replace(t.Content, "tt-", "", 1)
-		_, err := uuid.Parse(replacedToken)
-		return err == nil
-	}
-	_, err := uuid.Parse(t.Content)
-	return err == nil
+	// Since is the time the current state was entered.
+	Since time.Time
+	// LastRead is the time of the last successful read, zero if the scanner
+	// never read anything.
+	LastRead time.Time
+	// Attempts is the number of failed reopen attempts since the scanner went
+	// down.
+	Attempts int
+	// LastError is the most recent error, kept after the scanner recovered.
+	LastError error
 }
 
 // A ReopeningScanner is a Scanner that tries to reopen whenever it gets closed.
@@ -73,6 +56,18 @@ type ReopeningScanner struct {
 	name                   string
 	opener                 Opener
 	scanner                *Scanner
+	status                 Status
+	backoff                Backoff
+	liveness               Liveness
+	openedAt               time.Time
+	probePending           bool
+	probeFailures          int
+	feedback               FeedbackCommands
+	formats                Formats
+	debounce               time.Duration
+	lastToken              Token
+	lastTokenTime          time.Time
+	suppressed             uint64
 	tokenChans             []chan Token
 	statusChans            []chan Status
 	shutdownChan, doneChan chan struct{}
@@ -84,6 +79,9 @@ func NewReopeningScanner(name string, opener Opener) *ReopeningScanner {
 	return &ReopeningScanner{
 		name:         name,
 		opener:       opener,
+		status:       Status{Name: name, State: StateUnknown, Since: time.Now()},
+		backoff:      DefaultBackoff,
+		formats:      DefaultFormats,
 		shutdownChan: make(chan struct{}),
 		doneChan:     make(chan struct{}),
 	}
@@ -94,24 +92,6 @@ func NewReopeningScanner(name string, opener Opener) *ReopeningScanner {
 func (s *ReopeningScanner) Listen() {
 	defer close(s.doneChan)
 
-	status := StateUnknown
-
-	updateStatus := func(err error) {
-		// Going from DOWN to UP
-		if status != StateUp && err == nil {
-			status = StateUp
-			s.dispatchStatus(Status{s.name, status, nil})
-			return
-		}
-
-		// Going from UP to DOWN
-		if status != StateDown && err != nil {
-			status = StateDown
-			s.dispatchStatus(Status{s.name, status, err})
-			return
-		}
-	}
-
 	for {
 		select {
 		case <-s.shutdownChan:
@@ -119,38 +99,211 @@ func (s *ReopeningScanner) Listen() {
 		default:
 		}
 
-		if status == StateDown {
+		if status := s.Status(); status.State == StateDown {
 			select {
-			case <-time.After(time.Second):
+			case <-time.After(s.backoff.Delay(status.Attempts)):
 			case <-s.shutdownChan:
 				return
 			}
 		}
 
 		scanner, err := s.opener()
-		updateStatus(err)
 		if err != nil {
-			s.scanner = nil
+			s.setScanner(nil)
+			s.openFailed(err)
 			continue
-		} else {
-			s.scanner = scanner
+		}
+		s.setScanner(scanner)
+		s.opened()
+
+		stopMonitor := make(chan struct{})
+		if s.getLiveness().enabled() {
+			go s.monitor(stopMonitor)
 		}
 
-		for status == StateUp {
-			token, err := s.scanner.Accept()
-			updateStatus(err)
+		for {
+			token, err := scanner.Accept()
 			if err != nil {
-				s.scanner = nil
-				continue
-			} else {
-				if token != "" {
-					s.dispatchToken(token)
-				}
+				s.setScanner(nil)
+				s.readFailed(err)
+				break
+			}
+			s.read()
+			if token != "" && !s.isProbeResponse(token) {
+				s.dispatchToken(token)
 			}
 		}
+		close(stopMonitor)
 	}
 }
 
+// SetLiveness configures how a hung scanner is detected.
+func (s *ReopeningScanner) SetLiveness(liveness Liveness) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.liveness = liveness
+}
+
+func (s *ReopeningScanner) getLiveness() Liveness {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.liveness
+}
+
+// monitor checks the liveness of the open scanner until stop is closed.
+func (s *ReopeningScanner) monitor(stop chan struct{}) {
+	ticker := time.NewTicker(s.getLiveness().checkInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.checkLiveness(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *ReopeningScanner) checkLiveness(now time.Time) {
+	s.mu.Lock()
+	if s.scanner == nil {
+		s.mu.Unlock()
+		return
+	}
+
+	var probe *Scanner
+	if s.liveness.probing() {
+		if s.probePending {
+			s.probeFailures++
+		}
+		if s.probeFailures >= s.liveness.ProbeFailures {
+			log.Printf("Scanner %s did not answer %d probes, reopening", s.name, s.probeFailures)
+			// Unblocks Accept, the scanner goes down and is reopened.
+			s.scanner.Close()
+			s.mu.Unlock()
+			return
+		}
+		probe = s.scanner
+		s.probePending = true
+	}
+	s.updateHealthLocked(now)
+	cmd := s.liveness.ProbeCommand
+	s.mu.Unlock()
+
+	if probe != nil {
+		if err := send(probe, cmd); err != nil {
+			log.Printf("Failed to probe scanner %s: %v", s.name, err)
+		}
+	}
+}
+
+// isProbeResponse reports whether token answers a pending probe.
+func (s *ReopeningScanner) isProbeResponse(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.probePending || !s.liveness.ProbeResponse.MatchString(token) {
+		return false
+	}
+	s.probePending = false
+	s.probeFailures = 0
+	s.updateHealthLocked(time.Now())
+	return true
+}
+
+// updateHealthLocked switches between the UP and DEGRADED state of an open
+// scanner.
+func (s *ReopeningScanner) updateHealthLocked(now time.Time) {
+	lastActivity := s.openedAt
+	if s.status.LastRead.After(lastActivity) {
+		lastActivity = s.status.LastRead
+	}
+
+	switch {
+	case s.probeFailures > 0:
+		s.setStateLocked(StateDegraded, fmt.Errorf("%w %d times", ErrProbeFailed, s.probeFailures))
+	case s.liveness.MaxIdle > 0 &&
+		s.liveness.OperatingHours.Contains(now) &&
+		now.Sub(lastActivity) >= s.liveness.MaxIdle:
+		s.setStateLocked(StateDegraded, fmt.Errorf("%w since %v", ErrIdle, lastActivity.Format(time.RFC3339)))
+	default:
+		s.setStateLocked(StateUp, nil)
+	}
+}
+
+// Status returns the current status of the scanner.
+func (s *ReopeningScanner) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// SetBackoff configures the delays between reopen attempts.
+func (s *ReopeningScanner) SetBackoff(backoff Backoff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.backoff = backoff
+}
+
+func (s *ReopeningScanner) opened() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.openedAt = time.Now()
+	s.probePending = false
+	s.probeFailures = 0
+	s.setStateLocked(StateUp, nil)
+	s.status.Attempts = 0
+}
+
+func (s *ReopeningScanner) openFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Attempts++
+	s.status.LastError = err
+	s.setStateLocked(StateDown, err)
+}
+
+func (s *ReopeningScanner) readFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.liveness.probing() && s.probeFailures >= s.liveness.ProbeFailures {
+		// The scanner was closed for not answering probes.
+		err = fmt.Errorf("%w %d times", ErrProbeFailed, s.probeFailures)
+	}
+	s.status.LastError = err
+	s.setStateLocked(StateDown, err)
+}
+
+func (s *ReopeningScanner) read() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.LastRead = time.Now()
+	if s.status.State == StateDegraded && s.probeFailures == 0 {
+		// Data arrived, the scanner is no longer idle.
+		s.updateHealthLocked(s.status.LastRead)
+	}
+}
+
+// setStateLocked updates the state and notifies subscribers on transitions.
+func (s *ReopeningScanner) setStateLocked(state string, err error) {
+	s.status.Error = err
+	if s.status.State == state {
+		return
+	}
+	s.status.State = state
+	s.status.Since = time.Now()
+	s.dispatchStatus(s.status)
+}
+
 // Shutdown gracefully shuts down the reopening scanner. Shutdown works by
 // closing the scanner device and wait for the listening routine to stop. If the
 // provided context expires before the shutdown is complete, Shutdown returns
@@ -158,8 +311,8 @@ func (s *ReopeningScanner) Listen() {
 func (s *ReopeningScanner) Shutdown(ctx context.Context) error {
 	close(s.shutdownChan)
 
-	if s.scanner != nil {
-		s.scanner.Close()
+	if scanner := s.getScanner(); scanner != nil {
+		scanner.Close()
 	}
 
 	select {
@@ -175,6 +328,89 @@ func (s *ReopeningScanner) Name() string {
 	return s.name
 }
 
+// SetFormats configures the token formats recognised by the scanner.
+func (s *ReopeningScanner) SetFormats(formats Formats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.formats = formats
+}
+
+// SetDebounce configures the window in which identical tokens are dropped.
+// Every suppressed token extends the window, so a token held in front of the
+// scanner is only reported once. Tokens dropped since no subscriber was ready
+// do not open a window. A zero window disables suppression.
+func (s *ReopeningScanner) SetDebounce(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.debounce = window
+}
+
+// Suppressed returns the number of tokens dropped as duplicates.
+func (s *ReopeningScanner) Suppressed() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.suppressed
+}
+
+// SetFeedback configures the commands sent to the scanner device on the
+// outcome of a scan.
+func (s *ReopeningScanner) SetFeedback(cmds FeedbackCommands) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feedback = cmds
+}
+
+// Good implements the Feedback interface. It sends the good-read command to
+// the currently open scanner device.
+func (s *ReopeningScanner) Good() error {
+	s.mu.Lock()
+	scanner, cmd := s.scanner, s.feedback.Good
+	s.mu.Unlock()
+
+	return send(scanner, cmd)
+}
+
+// Bad implements the Feedback interface. It sends the bad-read command to the
+// currently open scanner device.
+func (s *ReopeningScanner) Bad() error {
+	s.mu.Lock()
+	scanner, cmd := s.scanner, s.feedback.Bad
+	s.mu.Unlock()
+
+	return send(scanner, cmd)
+}
+
+// send writes cmd to scanner. It is called without holding the lock, so a
+// blocking device does not stall reading tokens.
+func send(scanner *Scanner, cmd []byte) error {
+	if len(cmd) == 0 {
+		return nil
+	}
+	if scanner == nil {
+		return ErrNotOpen
+	}
+	_, err := scanner.Write(cmd)
+	return err
+}
+
+func (s *ReopeningScanner) setScanner(scanner *Scanner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scanner = scanner
+}
+
+func (s *ReopeningScanner) getScanner() *Scanner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.scanner
+}
+
 // NotifyTokens adds `ch` to the list of subscribers for tokens.
 func (s *ReopeningScanner) NotifyTokens(ch chan Token) {
 	s.mu.Lock()
@@ -192,13 +428,51 @@ func (s *ReopeningScanner) NotifyStatus(ch chan Status) {
 }
 
 func (s *ReopeningScanner) dispatchToken(token string) {
-	tokenContainer := NewToken(token, s.scanner.name)
+	s.mu.Lock()
+	tokenContainer := s.formats.Token(token, s.scanner.name)
+	duplicate := s.isDuplicateLocked(*tokenContainer, time.Now())
+	s.mu.Unlock()
+
+	if duplicate {
+		log.Println("[", tokenContainer.Content, "]", "Suppressing duplicate scan on scanner", s.name)
+		return
+	}
+
+	dispatched := false
 	for _, ch := range s.tokenChans {
 		select {
 		case ch <- *tokenContainer:
+			dispatched = true
 		default:
 		}
 	}
+
+	// Dropped tokens are not debounced, so scanning them again is reported.
+	if dispatched {
+		s.mu.Lock()
+		s.recordLocked(*tokenContainer, time.Now())
+		s.mu.Unlock()
+	}
+}
+
+// isDuplicateLocked reports whether token is a duplicate of the last token
+// dispatched. Duplicates extend the window.
+func (s *ReopeningScanner) isDuplicateLocked(token Token, now time.Time) bool {
+	duplicate := s.debounce > 0 &&
+		token == s.lastToken &&
+		now.Sub(s.lastTokenTime) < s.debounce
+
+	if duplicate {
+		s.suppressed++
+		s.lastTokenTime = now
+	}
+	return duplicate
+}
+
+// recordLocked notes token as the last token dispatched.
+func (s *ReopeningScanner) recordLocked(token Token, now time.Time) {
+	s.lastToken = token
+	s.lastTokenTime = now
 }
 
 func (s *ReopeningScanner) dispatchStatus(status Status) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -88,3 +89,108 @@ func TestReopeningScanner_Shutdown(t *testing.T) {
 		assert.EqualError(t, err, "context canceled")
 	})
 }
+
+func TestReopeningScanner_Debounce(t *testing.T) {
+	t.Run("suppresses identical tokens within window", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", DummyOpener("", ""))
+		scanner.SetDebounce(time.Hour)
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+
+		ch := make(chan Token, 100)
+		scanner.NotifyTokens(ch)
+
+		for scanner.Suppressed() < 10 {
+			time.Sleep(time.Millisecond)
+		}
+		assert.True(t, len(ch) <= 1)
+	})
+	t.Run("reports identical tokens after window", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", nil)
+		scanner.SetDebounce(time.Minute)
+		now := time.Now()
+		token := *NewToken("token", "scanner")
+
+		assert.False(t, scanner.isDuplicateLocked(token, now))
+		scanner.recordLocked(token, now)
+		assert.True(t, scanner.isDuplicateLocked(token, now.Add(30*time.Second)))
+		assert.True(t, scanner.isDuplicateLocked(token, now.Add(80*time.Second)))
+		assert.False(t, scanner.isDuplicateLocked(token, now.Add(150*time.Second)))
+		assert.Equal(t, uint64(2), scanner.Suppressed())
+	})
+	t.Run("reports different tokens", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", nil)
+		scanner.SetDebounce(time.Minute)
+		now := time.Now()
+
+		scanner.recordLocked(*NewToken("one", "scanner"), now)
+		assert.False(t, scanner.isDuplicateLocked(*NewToken("two", "scanner"), now))
+		scanner.recordLocked(*NewToken("two", "scanner"), now)
+		assert.False(t, scanner.isDuplicateLocked(*NewToken("one", "scanner"), now))
+	})
+	t.Run("reports all tokens when disabled", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", nil)
+		now := time.Now()
+		token := *NewToken("token", "scanner")
+
+		scanner.recordLocked(token, now)
+		assert.False(t, scanner.isDuplicateLocked(token, now))
+	})
+	t.Run("reports tokens again that were dropped", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", nil)
+		scanner.SetDebounce(time.Hour)
+		scanner.setScanner(&Scanner{name: "scanner"})
+		ch := make(chan Token)
+		scanner.NotifyTokens(ch)
+
+		scanner.dispatchToken("token")
+		received := make(chan Token, 1)
+		go func() { received <- <-ch }()
+		for {
+			scanner.dispatchToken("token")
+			select {
+			case token := <-received:
+				assert.Equal(t, "token", token.Content)
+				assert.Equal(t, uint64(0), scanner.Suppressed())
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	})
+}
+
+func TestReopeningScanner_Status(t *testing.T) {
+	t.Run("counts failed reopen attempts", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", FailingOpener(""))
+		scanner.SetBackoff(Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1})
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+
+		for scanner.Status().Attempts < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		status := scanner.Status()
+		assert.Equal(t, StateDown, status.State)
+		assert.EqualError(t, status.LastError, "failed")
+		assert.False(t, status.Since.IsZero())
+		assert.True(t, status.LastRead.IsZero())
+	})
+	t.Run("tracks last read", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", DummyOpener("", ""))
+		ch := make(chan Token)
+		scanner.NotifyTokens(ch)
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+
+		<-ch
+		status := scanner.Status()
+		assert.Equal(t, StateUp, status.State)
+		assert.Equal(t, 0, status.Attempts)
+		assert.False(t, status.LastRead.IsZero())
+	})
+	t.Run("starts in unknown state", func(t *testing.T) {
+		scanner := NewReopeningScanner("scanner", DummyOpener("", ""))
+		assert.Equal(t, StateUnknown, scanner.Status().State)
+		assert.Equal(t, "scanner", scanner.Status().Name)
+	})
+}
@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A RFIDProtocol defines how a RFID reader transmits card UIDs.
+type RFIDProtocol string
+
+const (
+	// RFIDASCII readers send the UID as hex digits terminated by CR, LF or
+	// CRLF.
+	RFIDASCII RFIDProtocol = "ascii"
+	// RFIDEM4100 readers, e.g. the RDM6300, send STX, the 5 byte UID and its
+	// XOR checksum as hex digits and ETX.
+	RFIDEM4100 RFIDProtocol = "em4100"
+	// RFIDWiegand readers send the 26 or 34 bits of a Wiegand frame as 0 and
+	// 1 terminated by CR, LF or CRLF.
+	RFIDWiegand RFIDProtocol = "wiegand"
+)
+
+// ParseRFIDProtocol returns the protocol identified by name, defaults to
+// RFIDASCII.
+func ParseRFIDProtocol(name string) (RFIDProtocol, error) {
+	switch p := RFIDProtocol(name); p {
+	case "":
+		return RFIDASCII, nil
+	case RFIDASCII, RFIDEM4100, RFIDWiegand:
+		return p, nil
+	default:
+		return "", fmt.Errorf("undefined rfid protocol: %s", name)
+	}
+}
+
+func (p RFIDProtocol) framing() Framing {
+	if p == RFIDEM4100 {
+		return Framing{Mode: FramingSTXETX, MaxLength: DefaultMaxFrameLength}
+	}
+	return DefaultFraming
+}
+
+// decode returns the UID of a frame, prefixed with "rfid:" so it is
+// recognised by the RFIDFormat.
+func (p RFIDProtocol) decode(frame string) (string, error) {
+	var uid string
+	var err error
+
+	switch p {
+	case RFIDEM4100:
+		uid, err = decodeEM4100(frame)
+	case RFIDWiegand:
+		uid, err = decodeWiegand(frame)
+	default:
+		uid = frame
+	}
+	if err != nil {
+		return "", err
+	}
+	return "rfid:" + uid, nil
+}
+
+func decodeEM4100(frame string) (string, error) {
+	b, err := hex.DecodeString(frame)
+	if err != nil || len(b) != 6 {
+		return "", fmt.Errorf("invalid em4100 frame: %q", frame)
+	}
+
+	var checksum byte
+	for _, d := range b[:5] {
+		checksum ^= d
+	}
+	if checksum != b[5] {
+		return "", fmt.Errorf("invalid em4100 checksum: %02X, expected %02X", b[5], checksum)
+	}
+	return strings.ToUpper(hex.EncodeToString(b[:5])), nil
+}
+
+// decodeWiegand checks the leading even and trailing odd parity bit of a
+// Wiegand frame, each covering one half of the data bits. The data bits are
+// returned as 4 bytes of hex.
+func decodeWiegand(frame string) (string, error) {
+	if len(frame) != 26 && len(frame) != 34 {
+		return "", fmt.Errorf("invalid wiegand frame length: %d", len(frame))
+	}
+
+	if strings.Trim(frame, "01") != "" {
+		return "", fmt.Errorf("invalid wiegand frame: %q", frame)
+	}
+
+	ones := func(bits string) int {
+		return strings.Count(bits, "1")
+	}
+	half := len(frame) / 2
+	if ones(frame[:half])%2 != 0 || ones(frame[half:])%2 != 1 {
+		return "", fmt.Errorf("invalid wiegand parity: %s", frame)
+	}
+
+	var data uint32
+	for _, bit := range frame[1 : len(frame)-1] {
+		data = data<<1 | uint32(bit-'0')
+	}
+	return fmt.Sprintf("%08X", data), nil
+}
+
+// RFIDOpener returns an opener for a RFID reader connected to a serial port.
+// Frames that fail to decode, e.g. on a checksum error, are discarded.
+func RFIDOpener(name string, protocol RFIDProtocol, locate Locator, opts SerialOptions) Opener {
+	return Opener(func() (*Scanner, error) {
+		port, err := openSerial(locate, opts)
+		if err != nil {
+			return nil, err
+		}
+		return listenRFID(name, protocol, port)
+	})
+}
+
+func listenRFID(name string, protocol RFIDProtocol, rc io.ReadCloser) (*Scanner, error) {
+	scanner, err := ListenFramed(name, "", protocol.framing(), rc)
+	if err != nil {
+		return nil, err
+	}
+	scanner.decode = protocol.decode
+	return scanner, nil
+}
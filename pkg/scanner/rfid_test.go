@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRFIDProtocol_decode(t *testing.T) {
+	t.Run("decodes em4100", func(t *testing.T) {
+		uid, err := RFIDEM4100.decode("0A002B3C4D50")
+		assert.NoError(t, err)
+		assert.Equal(t, "rfid:0A002B3C4D", uid)
+	})
+	t.Run("rejects em4100 checksum errors", func(t *testing.T) {
+		_, err := RFIDEM4100.decode("0A002B3C4D51")
+		assert.Error(t, err)
+	})
+	t.Run("decodes wiegand 26", func(t *testing.T) {
+		uid, err := RFIDWiegand.decode("1" + "00000001" + "0000000000000001" + "0")
+		assert.NoError(t, err)
+		assert.Equal(t, "rfid:00010001", uid)
+	})
+	t.Run("decodes wiegand 34", func(t *testing.T) {
+		uid, err := RFIDWiegand.decode("1" + "11011110101011011011111011101111" + "0")
+		assert.NoError(t, err)
+		assert.Equal(t, "rfid:DEADBEEF", uid)
+	})
+	t.Run("rejects wiegand parity errors", func(t *testing.T) {
+		_, err := RFIDWiegand.decode("0" + "00000001" + "0000000000000001" + "0")
+		assert.Error(t, err)
+	})
+	t.Run("rejects invalid wiegand frames", func(t *testing.T) {
+		_, err := RFIDWiegand.decode("10000000100000000000000012")
+		assert.Error(t, err)
+		_, err = RFIDWiegand.decode("101")
+		assert.Error(t, err)
+	})
+	t.Run("passes ascii through", func(t *testing.T) {
+		uid, err := RFIDASCII.decode("04:A1:B2:C3")
+		assert.NoError(t, err)
+		assert.Equal(t, "rfid:04:A1:B2:C3", uid)
+	})
+}
+
+func TestListenRFID(t *testing.T) {
+	t.Run("discards frames failing to decode", func(t *testing.T) {
+		rc := ioutil.NopCloser(strings.NewReader("\x020A002B3C4D51\x03\x020A002B3C4D50\x03"))
+		scanner, err := listenRFID("rfid", RFIDEM4100, rc)
+		assert.NoError(t, err)
+
+		tokens := acceptAll(scanner)
+
+		assert.Equal(t, []string{"rfid:0A002B3C4D"}, tokens)
+		assert.Equal(t, Token{Content: "0A002B3C4D", Scanner: "rfid", ScanSource: RFID},
+			*Formats{RFIDFormat}.Token(tokens[0], "rfid"))
+	})
+}
+
+func TestParseRFIDProtocol(t *testing.T) {
+	p, err := ParseRFIDProtocol("")
+	assert.NoError(t, err)
+	assert.Equal(t, RFIDASCII, p)
+
+	_, err = ParseRFIDProtocol("mifare")
+	assert.Error(t, err)
+}
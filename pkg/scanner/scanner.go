@@ -2,17 +2,37 @@ package scanner
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"log"
 	"strings"
+	"time"
 )
 
+const readSize = 256
+
+// ErrNotWritable is returned when commands are sent to a scanner device that
+// cannot be written to.
+var ErrNotWritable = errors.New("scanner does not accept commands")
+
 // A Scanner is a generic device that provides string values from optical,
 // machine-readable representations of data.
 type Scanner struct {
-	name   string
-	prefix string
-	rc     io.ReadCloser
+	name    string
+	prefix  string
+	framing Framing
+	decode  func(frame string) (string, error)
+	rc      io.ReadCloser
+
+	buf   []byte
+	idle  bool
+	err   error
+	reads chan readResult
+}
+
+type readResult struct {
+	data []byte
+	err  error
 }
 
 // Name returns the configured name of the scanner.
@@ -25,20 +45,43 @@ func (s *Scanner) Prefix() string {
 	return s.prefix
 }
 
-// Accept waits for and returns the next value from the scanner.
+// Accept waits for and returns the next value from the scanner. Values are
+// cut from the byte stream according to the configured framing, so a value
+// may span several reads and a single read may contain several values.
 func (s *Scanner) Accept() (string, error) {
-	buf := make([]byte, 64)
+	for {
+		if frame, ok := s.nextFrame(); ok {
+			input := string(bytes.TrimSpace(frame))
+			if input == "" {
+				continue
+			}
+			input = strings.TrimPrefix(input, s.prefix)
+			if s.decode != nil {
+				decoded, err := s.decode(input)
+				if err != nil {
+					log.Println("Discarding frame", input, "on scanner", s.name, err)
+					continue
+				}
+				input = decoded
+			}
+			log.Println("Received token", input, "on scanner", s.name)
+			return input, nil
+		}
+
+		if err := s.fill(); err != nil {
+			defer s.Close()
+			return "", err
+		}
+	}
+}
 
-	n, err := s.rc.Read(buf)
-	if err != nil {
-		defer s.Close()
-		return "", err
+// Write sends the command p to the scanner device.
+func (s *Scanner) Write(p []byte) (int, error) {
+	w, ok := s.rc.(io.Writer)
+	if !ok {
+		return 0, ErrNotWritable
 	}
-	input := string(bytes.TrimSpace(buf[:n]))
-	input = strings.TrimPrefix(input, s.prefix)
-	input = strings.TrimPrefix(input, "QR")
-	log.Println("Received token", input, "on scanner", s.name)
-	return input, nil
+	return w.Write(p)
 }
 
 // Close closes the scanner.
@@ -47,12 +90,99 @@ func (s *Scanner) Close() error {
 	return s.rc.Close()
 }
 
-// ReadAndStartListen creates a scanner that reads from rc.
+func (s *Scanner) nextFrame() ([]byte, bool) {
+	if s.framing.Mode == FramingIdle {
+		if !s.idle || len(s.buf) == 0 {
+			return nil, false
+		}
+		s.idle = false
+		frame := s.buf
+		s.buf = nil
+		return frame, true
+	}
+
+	frame, advance, ok := s.framing.split(s.buf)
+	if ok {
+		frame = append([]byte(nil), frame...)
+	}
+	s.buf = s.buf[advance:]
+	if !ok && len(s.buf) > s.framing.MaxLength {
+		log.Printf("Discarding %d bytes without frame end on scanner %s", len(s.buf), s.name)
+		s.buf = nil
+	}
+	return frame, ok
+}
+
+// fill reads the next chunk of data into the buffer. With idle framing, fill
+// also returns once the idle gap elapsed after the last received data. Read
+// errors are deferred until the data received before has been consumed.
+func (s *Scanner) fill() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.framing.Mode != FramingIdle {
+		buf := make([]byte, readSize)
+		n, err := s.rc.Read(buf)
+		s.buf = append(s.buf, buf[:n]...)
+		s.err = err
+		if n > 0 {
+			return nil
+		}
+		return err
+	}
+
+	if s.reads == nil {
+		s.reads = make(chan readResult, 1)
+		go s.readLoop()
+	}
+
+	var gap <-chan time.Time
+	if len(s.buf) > 0 {
+		gap = time.After(s.framing.IdleGap)
+	}
+
+	select {
+	case r := <-s.reads:
+		s.buf = append(s.buf, r.data...)
+		s.err = r.err
+		if r.err != nil || len(s.buf) > s.framing.MaxLength {
+			s.idle = true
+		}
+		if len(s.buf) > 0 {
+			return nil
+		}
+		return r.err
+	case <-gap:
+		s.idle = true
+		return nil
+	}
+}
+
+func (s *Scanner) readLoop() {
+	for {
+		buf := make([]byte, readSize)
+		n, err := s.rc.Read(buf)
+		s.reads <- readResult{buf[:n], err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Listen creates a scanner that reads from rc using the default framing.
 func Listen(name, prefix string, rc io.ReadCloser) (*Scanner, error) {
+	return ListenFramed(name, prefix, DefaultFraming, rc)
+}
+
+// ListenFramed creates a scanner that reads frames described by framing from
+// rc.
+func ListenFramed(name, prefix string, framing Framing, rc io.ReadCloser) (*Scanner, error) {
 	scanner := &Scanner{
-		name:   name,
-		prefix: prefix,
-		rc:     rc,
+		name:    name,
+		prefix:  prefix,
+		framing: framing,
+		rc:      rc,
 	}
 	return scanner, nil
 }
@@ -77,19 +77,19 @@ func TestKeyboardScanner_Accept(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "token", token)
 	})
-	t.Run("strips static QR prefix", func(t *testing.T) {
+	t.Run("leaves token format prefixes", func(t *testing.T) {
 		scanner, _ := Listen("test", "", &DummyReadCloser{value: "QRtoken\n"})
 
 		token, err := scanner.Accept()
 		assert.NoError(t, err)
-		assert.Equal(t, "token", token)
+		assert.Equal(t, "QRtoken", token)
 	})
-	t.Run("strips configured and static QR prefix", func(t *testing.T) {
+	t.Run("strips configured prefix only", func(t *testing.T) {
 		scanner, _ := Listen("test", "IN", &DummyReadCloser{value: "INQRtoken\n"})
 
 		token, err := scanner.Accept()
 		assert.NoError(t, err)
-		assert.Equal(t, "token", token)
+		assert.Equal(t, "QRtoken", token)
 	})
 	t.Run("closes scanner on error", func(t *testing.T) {
 		reader := &FailingReadCloser{}
@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+// SerialOptions configures the serial port of a scanner.
+type SerialOptions struct {
+	BaudRate              uint
+	DataBits              uint
+	Parity                string
+	StopBits              uint
+	RTSCTSFlowControl     bool
+	InterCharacterTimeout time.Duration
+	MinimumReadSize       uint
+}
+
+// DefaultSerialOptions are used for serial scanners, unless configured
+// otherwise.
+var DefaultSerialOptions = SerialOptions{
+	BaudRate:        115200,
+	DataBits:        8,
+	Parity:          "none",
+	StopBits:        1,
+	MinimumReadSize: 4,
+}
+
+var parityModes = map[string]serial.ParityMode{
+	"none": serial.PARITY_NONE,
+	"odd":  serial.PARITY_ODD,
+	"even": serial.PARITY_EVEN,
+}
+
+// Validate returns an error if the options do not describe a usable serial
+// port configuration.
+func (o SerialOptions) Validate() error {
+	if !serial.IsStandardBaudRate(o.BaudRate) {
+		return fmt.Errorf("unsupported baud rate: %d", o.BaudRate)
+	}
+	if o.DataBits < 5 || o.DataBits > 8 {
+		return fmt.Errorf("unsupported data bits: %d", o.DataBits)
+	}
+	if _, ok := parityModes[o.Parity]; !ok {
+		return fmt.Errorf("unsupported parity: %s", o.Parity)
+	}
+	if o.StopBits != 1 && o.StopBits != 2 {
+		return fmt.Errorf("unsupported stop bits: %d", o.StopBits)
+	}
+	if o.InterCharacterTimeout < 0 || o.InterCharacterTimeout > 25500*time.Millisecond {
+		return fmt.Errorf("inter-character timeout out of range: %v", o.InterCharacterTimeout)
+	}
+	if o.InterCharacterTimeout%(100*time.Millisecond) != 0 {
+		return fmt.Errorf("inter-character timeout must be a multiple of 100ms: %v", o.InterCharacterTimeout)
+	}
+	if o.MinimumReadSize > 255 {
+		return fmt.Errorf("minimum read size out of range: %d", o.MinimumReadSize)
+	}
+	if o.MinimumReadSize == 0 && o.InterCharacterTimeout == 0 {
+		return fmt.Errorf("either minimum read size or inter-character timeout must be set")
+	}
+	return nil
+}
+
+// String returns the options in the common short notation, e.g. 9600 7E1.
+func (o SerialOptions) String() string {
+	parity := "N"
+	switch o.Parity {
+	case "odd":
+		parity = "O"
+	case "even":
+		parity = "E"
+	}
+	return fmt.Sprintf("%d %d%s%d", o.BaudRate, o.DataBits, parity, o.StopBits)
+}
+
+func (o SerialOptions) openOptions(device string) serial.OpenOptions {
+	return serial.OpenOptions{
+		PortName:              device,
+		BaudRate:              o.BaudRate,
+		DataBits:              o.DataBits,
+		ParityMode:            parityModes[o.Parity],
+		StopBits:              o.StopBits,
+		RTSCTSFlowControl:     o.RTSCTSFlowControl,
+		InterCharacterTimeout: uint(o.InterCharacterTimeout / time.Millisecond),
+		MinimumReadSize:       o.MinimumReadSize,
+	}
+}
+
+// SerialOpener returns an opener for a scanner attached to the serial port
+// device.
+func SerialOpener(name, prefix string, framing Framing, locate Locator, opts SerialOptions) Opener {
+	return Opener(func() (*Scanner, error) {
+		port, err := openSerial(locate, opts)
+		if err != nil {
+			return nil, err
+		}
+		return ListenFramed(name, prefix, framing, port)
+	})
+}
+
+func openSerial(locate Locator, opts SerialOptions) (io.ReadWriteCloser, error) {
+	device, err := locate()
+	if err != nil {
+		return nil, err
+	}
+	return OpenSerialPort(device, opts)
+}
+
+// OpenSerialPort opens the serial port device, e.g. of a device other than a
+// scanner sharing the serial port configuration.
+func OpenSerialPort(device string, opts SerialOptions) (io.ReadWriteCloser, error) {
+	return serial.Open(opts.openOptions(device))
+}
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/go-serial/serial"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialOptions_Validate(t *testing.T) {
+	t.Run("accepts defaults", func(t *testing.T) {
+		assert.NoError(t, DefaultSerialOptions.Validate())
+	})
+	t.Run("accepts 9600 7E1", func(t *testing.T) {
+		opts := SerialOptions{BaudRate: 9600, DataBits: 7, Parity: "even", StopBits: 1, MinimumReadSize: 1}
+		assert.NoError(t, opts.Validate())
+		assert.Equal(t, "9600 7E1", opts.String())
+	})
+	t.Run("rejects invalid options", func(t *testing.T) {
+		for name, modify := range map[string]func(*SerialOptions){
+			"baud rate":              func(o *SerialOptions) { o.BaudRate = 12345 },
+			"data bits":              func(o *SerialOptions) { o.DataBits = 9 },
+			"parity":                 func(o *SerialOptions) { o.Parity = "mark" },
+			"stop bits":              func(o *SerialOptions) { o.StopBits = 3 },
+			"timeout resolution":     func(o *SerialOptions) { o.InterCharacterTimeout = 150 * time.Millisecond },
+			"timeout range":          func(o *SerialOptions) { o.InterCharacterTimeout = 30 * time.Second },
+			"minimum read size":      func(o *SerialOptions) { o.MinimumReadSize = 256 },
+			"non-blocking port read": func(o *SerialOptions) { o.MinimumReadSize = 0 },
+		} {
+			opts := DefaultSerialOptions
+			modify(&opts)
+			assert.Error(t, opts.Validate(), name)
+		}
+	})
+}
+
+func TestSerialOptions_openOptions(t *testing.T) {
+	opts := SerialOptions{
+		BaudRate:              9600,
+		DataBits:              7,
+		Parity:                "odd",
+		StopBits:              2,
+		RTSCTSFlowControl:     true,
+		InterCharacterTimeout: 200 * time.Millisecond,
+	}
+	assert.Equal(t, serial.OpenOptions{
+		PortName:              "/dev/ttyS0",
+		BaudRate:              9600,
+		DataBits:              7,
+		ParityMode:            serial.PARITY_ODD,
+		StopBits:              2,
+		RTSCTSFlowControl:     true,
+		InterCharacterTimeout: 200,
+	}, opts.openOptions("/dev/ttyS0"))
+}
+
+func TestSerialOpener(t *testing.T) {
+	t.Run("fails if device cannot be located", func(t *testing.T) {
+		locateErr := errors.New("no matching usb device")
+		opener := SerialOpener("test", "", DefaultFraming, func() (string, error) {
+			return "", locateErr
+		}, DefaultSerialOptions)
+
+		_, err := opener()
+
+		assert.Equal(t, locateErr, err)
+	})
+}
@@ -0,0 +1,239 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnplugged is returned when opening a simulated scanner that was
+// disconnected by a script.
+var ErrUnplugged = errors.New("simulated scanner is disconnected")
+
+// DefaultUnplugDuration is the time a simulated scanner stays disconnected,
+// unless the script says otherwise.
+const DefaultUnplugDuration = 5 * time.Second
+
+// SimulatedOpener returns an opener for a scanner simulated by scripts sent to
+// address, either "unix:<path>" or "tcp:<host>:<port>" on the loopback
+// interface. The listener is created on first use and kept open for the
+// lifetime of the process.
+//
+// Every line of a script is a scan, except for the commands
+//
+//	!delay <duration>       wait before processing the next line
+//	!disconnect [duration]  unplug the scanner, it goes down for the duration
+//	!ghost                  read a random token, as if the scanner misread
+//
+// Empty lines and lines starting with # are ignored.
+func SimulatedOpener(name, prefix, address string) Opener {
+	sim := &simulator{address: address}
+
+	return Opener(func() (*Scanner, error) {
+		device, err := sim.plug()
+		if err != nil {
+			return nil, err
+		}
+		return Listen(name, prefix, device)
+	})
+}
+
+type simulator struct {
+	address        string
+	listener       net.Listener
+	device         *simulatedDevice
+	unpluggedUntil time.Time
+	mu             sync.Mutex
+}
+
+// plug returns a new device, unless the scanner is disconnected.
+func (s *simulator) plug() (*simulatedDevice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		l, err := listenSimulator(s.address)
+		if err != nil {
+			return nil, err
+		}
+		s.listener = l
+		go s.serve(l)
+	}
+
+	if time.Now().Before(s.unpluggedUntil) {
+		return nil, ErrUnplugged
+	}
+
+	s.device = newSimulatedDevice()
+	return s.device, nil
+}
+
+func (s *simulator) unplug(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unpluggedUntil = time.Now().Add(d)
+	if s.device != nil {
+		s.device.Close()
+		s.device = nil
+	}
+}
+
+func (s *simulator) scan(token string) error {
+	s.mu.Lock()
+	device := s.device
+	s.mu.Unlock()
+
+	if device == nil {
+		return ErrNotOpen
+	}
+	return device.feed(token)
+}
+
+func (s *simulator) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go s.run(conn)
+	}
+}
+
+// run executes the script read from conn. Errors are reported back to the
+// client, they do not end the script.
+func (s *simulator) run(conn net.Conn) {
+	defer conn.Close()
+
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		if err := s.execute(strings.TrimSpace(lines.Text())); err != nil {
+			fmt.Fprintf(conn, "error: %v\n", err)
+		}
+	}
+}
+
+func (s *simulator) execute(line string) error {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	if !strings.HasPrefix(line, "!") {
+		return s.scan(line)
+	}
+
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "!delay":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: !delay <duration>")
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return err
+		}
+		time.Sleep(d)
+	case "!disconnect":
+		d := DefaultUnplugDuration
+		if len(fields) > 1 {
+			var err error
+			if d, err = time.ParseDuration(fields[1]); err != nil {
+				return err
+			}
+		}
+		s.unplug(d)
+	case "!ghost":
+		return s.scan(ghostToken())
+	default:
+		return fmt.Errorf("unknown command: %s", fields[0])
+	}
+	return nil
+}
+
+// ghostToken returns a random token of printable garbage. It starts with a
+// symbol, so it never passes as a valid token.
+func ghostToken() string {
+	const (
+		symbols = "%$/+-."
+		chars   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789" + symbols
+	)
+	b := make([]byte, 4+rand.Intn(12))
+	b[0] = symbols[rand.Intn(len(symbols))]
+	for i := 1; i < len(b); i++ {
+		b[i] = chars[rand.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+func listenSimulator(address string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		path := strings.TrimPrefix(address, "unix:")
+		// Remove the socket of a previous run.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	case strings.HasPrefix(address, "tcp:"):
+		addr, err := net.ResolveTCPAddr("tcp", strings.TrimPrefix(address, "tcp:"))
+		if err != nil {
+			return nil, err
+		}
+		if addr.IP == nil || !addr.IP.IsLoopback() {
+			return nil, fmt.Errorf("simulated scanner must listen on loopback: %s", address)
+		}
+		return net.ListenTCP("tcp", addr)
+	default:
+		return nil, fmt.Errorf("invalid simulator address: %s", address)
+	}
+}
+
+// A simulatedDevice is the byte stream of a simulated scanner, it emits every
+// token as a line.
+type simulatedDevice struct {
+	frames    chan []byte
+	pending   []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newSimulatedDevice() *simulatedDevice {
+	return &simulatedDevice{
+		frames: make(chan []byte),
+		closed: make(chan struct{}),
+	}
+}
+
+func (d *simulatedDevice) feed(token string) error {
+	select {
+	case d.frames <- []byte(token + "\n"):
+		return nil
+	case <-d.closed:
+		return ErrNotOpen
+	}
+}
+
+func (d *simulatedDevice) Read(p []byte) (int, error) {
+	if len(d.pending) == 0 {
+		select {
+		case frame := <-d.frames:
+			d.pending = frame
+		case <-d.closed:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func (d *simulatedDevice) Close() error {
+	d.closeOnce.Do(func() { close(d.closed) })
+	return nil
+}
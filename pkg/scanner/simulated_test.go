@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startSimulated(t *testing.T) (*ReopeningScanner, chan Token, chan Status, net.Conn, func()) {
+	dir, err := ioutil.TempDir("", "simulated")
+	assert.NoError(t, err)
+	address := "unix:" + filepath.Join(dir, "scanner.sock")
+
+	scanner := NewReopeningScanner("sim", SimulatedOpener("sim", "", address))
+	scanner.SetBackoff(Backoff{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 1})
+	tokens := make(chan Token, 5)
+	statuses := make(chan Status, 5)
+	scanner.NotifyTokens(tokens)
+	scanner.NotifyStatus(statuses)
+	go scanner.Listen()
+
+	assert.Equal(t, StateUp, (<-statuses).State)
+
+	conn, err := net.Dial("unix", filepath.Join(dir, "scanner.sock"))
+	assert.NoError(t, err)
+
+	return scanner, tokens, statuses, conn, func() {
+		conn.Close()
+		scanner.Shutdown(context.Background())
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSimulatedOpener(t *testing.T) {
+	t.Run("reads scans of a script", func(t *testing.T) {
+		_, tokens, _, conn, stop := startSimulated(t)
+		defer stop()
+
+		conn.Write([]byte("# commissioning\ntt-4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5\n\n!delay 10ms\nTEST\n"))
+
+		assert.Equal(t, Token{Content: "4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5", ScanSource: TruckersTrust, Scanner: "sim"}, <-tokens)
+		assert.Equal(t, Token{Content: "TEST", ScanSource: Invalid, Scanner: "sim"}, <-tokens)
+	})
+	t.Run("reads ghost scans", func(t *testing.T) {
+		_, tokens, _, conn, stop := startSimulated(t)
+		defer stop()
+
+		conn.Write([]byte("!ghost\n"))
+
+		token := <-tokens
+		assert.Equal(t, Invalid, token.ScanSource)
+		assert.NotEmpty(t, token.Content)
+	})
+	t.Run("goes down on disconnect", func(t *testing.T) {
+		scanner, _, statuses, conn, stop := startSimulated(t)
+		defer stop()
+
+		conn.Write([]byte("!disconnect 50ms\n"))
+
+		assert.Equal(t, StateDown, (<-statuses).State)
+		assert.Equal(t, StateUp, (<-statuses).State)
+		assert.True(t, scanner.Status().LastError != nil)
+	})
+	t.Run("reports errors to the client", func(t *testing.T) {
+		_, _, _, conn, stop := startSimulated(t)
+		defer stop()
+
+		conn.Write([]byte("!explode\n"))
+
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "error: unknown command: !explode\n", reply)
+	})
+}
+
+func TestListenSimulator(t *testing.T) {
+	t.Run("listens on loopback", func(t *testing.T) {
+		l, err := listenSimulator("tcp:127.0.0.1:0")
+		assert.NoError(t, err)
+		l.Close()
+	})
+	t.Run("rejects other interfaces", func(t *testing.T) {
+		_, err := listenSimulator("tcp:0.0.0.0:0")
+		assert.Error(t, err)
+	})
+	t.Run("rejects unknown networks", func(t *testing.T) {
+		_, err := listenSimulator("udp:127.0.0.1:0")
+		assert.Error(t, err)
+	})
+}
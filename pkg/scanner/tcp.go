@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// TCPOptions configures the network connection of a scanner.
+type TCPOptions struct {
+	ConnectTimeout time.Duration
+	KeepAlive      time.Duration
+}
+
+// DefaultTCPOptions are used for network scanners, unless configured
+// otherwise.
+var DefaultTCPOptions = TCPOptions{
+	ConnectTimeout: 5 * time.Second,
+	KeepAlive:      30 * time.Second,
+}
+
+// TCPOpener returns an opener connecting to a scanner listening on address,
+// e.g. a serial-to-Ethernet converter in TCP server mode.
+func TCPOpener(name, prefix string, framing Framing, address string, opts TCPOptions) Opener {
+	dialer := &net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: opts.KeepAlive,
+	}
+
+	return Opener(func() (*Scanner, error) {
+		conn, err := dialer.Dial("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return ListenFramed(name, prefix, framing, conn)
+	})
+}
+
+// TCPListenOpener returns an opener waiting for a scanner to connect to
+// address, e.g. a serial-to-Ethernet converter in TCP client mode. The
+// listener is created on first use and kept open for the lifetime of the
+// process. If no scanner connects within the connect timeout, the opener
+// returns an error.
+func TCPListenOpener(name, prefix string, framing Framing, address string, opts TCPOptions) Opener {
+	var (
+		mu       sync.Mutex
+		listener *net.TCPListener
+	)
+
+	return Opener(func() (*Scanner, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if listener == nil {
+			addr, err := net.ResolveTCPAddr("tcp", address)
+			if err != nil {
+				return nil, err
+			}
+			listener, err = net.ListenTCP("tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if opts.ConnectTimeout > 0 {
+			listener.SetDeadline(time.Now().Add(opts.ConnectTimeout))
+		}
+		conn, err := listener.AcceptTCP()
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.KeepAlive > 0 {
+			conn.SetKeepAlive(true)
+			conn.SetKeepAlivePeriod(opts.KeepAlive)
+		}
+		return ListenFramed(name, prefix, framing, conn)
+	})
+}
@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTCPOptions = TCPOptions{
+	ConnectTimeout: time.Second,
+	KeepAlive:      time.Second,
+}
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestTCPOpener(t *testing.T) {
+	t.Run("reads tokens from connection", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer l.Close()
+
+		go func() {
+			conn, _ := l.Accept()
+			conn.Write([]byte("first\r\nsec"))
+			conn.Write([]byte("ond\r\n"))
+			conn.Close()
+		}()
+
+		scanner, err := TCPOpener("test", "", DefaultFraming, l.Addr().String(), testTCPOptions)()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first", "second"}, acceptAll(scanner))
+	})
+	t.Run("returns error if nobody listens", func(t *testing.T) {
+		_, err := TCPOpener("test", "", DefaultFraming, freeAddress(t), testTCPOptions)()
+		assert.Error(t, err)
+	})
+	t.Run("reports status of reopening scanner", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		scanner := NewReopeningScanner("scanner", TCPOpener("scanner", "", DefaultFraming, l.Addr().String(), testTCPOptions))
+		ch := make(chan Status, 2)
+		scanner.NotifyStatus(ch)
+		go scanner.Listen()
+		defer scanner.Shutdown(context.Background())
+
+		conn, err := l.Accept()
+		assert.NoError(t, err)
+		assert.Equal(t, StateUp, (<-ch).State)
+
+		l.Close()
+		conn.Close()
+		assert.Equal(t, StateDown, (<-ch).State)
+	})
+}
+
+func TestTCPListenOpener(t *testing.T) {
+	t.Run("reads tokens from connecting scanners", func(t *testing.T) {
+		address := freeAddress(t)
+		opener := TCPListenOpener("test", "", DefaultFraming, address, testTCPOptions)
+
+		go func() {
+			for i := 0; i < 2; i++ {
+				var conn net.Conn
+				var err error
+				for conn == nil {
+					time.Sleep(10 * time.Millisecond)
+					conn, err = net.Dial("tcp", address)
+				}
+				assert.NoError(t, err)
+				conn.Write([]byte("token\n"))
+				conn.Close()
+			}
+		}()
+
+		for i := 0; i < 2; i++ {
+			scanner, err := opener()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"token"}, acceptAll(scanner))
+		}
+	})
+	t.Run("returns error if nobody connects", func(t *testing.T) {
+		opts := TCPOptions{ConnectTimeout: 10 * time.Millisecond}
+		_, err := TCPListenOpener("test", "", DefaultFraming, freeAddress(t), opts)()
+		assert.Error(t, err)
+	})
+}
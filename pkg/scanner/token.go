@@ -0,0 +1,227 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// A ScanSource identifies the kind of a scanned token.
+type ScanSource string
+
+const (
+	Invalid       ScanSource = "INVALID"
+	Paper         ScanSource = "PAPER"
+	TruckersTrust ScanSource = "TRUCKERS_TRUST"
+	RFID          ScanSource = "RFID"
+	Container     ScanSource = "CONTAINER"
+)
+
+// A Token is a value read by a scanner, normalised by its token format.
+type Token struct {
+	Content    string
+	Scanner    string
+	ScanSource ScanSource
+}
+
+// NewToken creates a token from the raw scanner input using the default
+// token formats.
+func NewToken(rawContent, scanner string) *Token {
+	return DefaultFormats.Token(rawContent, scanner)
+}
+
+// IsValid returns whether the token matched any token format.
+func (t *Token) IsValid() bool {
+	return t.ScanSource != Invalid
+}
+
+// A TokenFormat recognises, normalises and validates tokens of one kind.
+type TokenFormat struct {
+	Name      string
+	Source    ScanSource
+	Match     func(raw string) bool
+	Normalise func(raw string) string
+	Validate  func(content string) bool
+}
+
+// Formats is an ordered list of token formats. A raw input is handled by the
+// first format that matches it and accepts its normalised content.
+type Formats []TokenFormat
+
+// Token creates a token from the raw scanner input. Inputs no format accepts
+// are tried again without the static "QR" prefix of some scanners. If no
+// format accepts the input either way, the token has the scan source Invalid.
+func (f Formats) Token(raw, scanner string) *Token {
+	if token, ok := f.token(raw, scanner); ok {
+		return token
+	}
+	if trimmed := strings.TrimPrefix(raw, "QR"); trimmed != raw {
+		if token, ok := f.token(trimmed, scanner); ok {
+			return token
+		}
+	}
+	return &Token{raw, scanner, Invalid}
+}
+
+func (f Formats) token(raw, scanner string) (*Token, bool) {
+	for _, format := range f {
+		if !format.Match(raw) {
+			continue
+		}
+		content := format.Normalise(raw)
+		if format.Validate(content) {
+			return &Token{content, scanner, format.Source}, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the names of the formats.
+func (f Formats) Names() []string {
+	var names []string
+	for _, format := range f {
+		names = append(names, format.Name)
+	}
+	return names
+}
+
+var (
+	// TruckersTrustFormat accepts UUIDs prefixed with "tt-".
+	TruckersTrustFormat = TokenFormat{
+		Name:      "truckers-trust",
+		Source:    TruckersTrust,
+		Match:     hasPrefix("tt-"),
+		Normalise: trimPrefix("tt-"),
+		Validate:  isUUID,
+	}
+	// PaperFormat accepts UUIDs of printed permissions, optionally prefixed
+	// with "QR".
+	PaperFormat = TokenFormat{
+		Name:      "paper",
+		Source:    Paper,
+		Match:     hasPrefix(""),
+		Normalise: trimPrefix("QR"),
+		Validate:  isUUID,
+	}
+	// RFIDFormat accepts card UIDs of 4, 7 or 10 bytes as hex, optionally
+	// prefixed with "rfid:" and separated by colons, dashes or spaces.
+	RFIDFormat = TokenFormat{
+		Name:      "rfid",
+		Source:    RFID,
+		Match:     regexp.MustCompile(`^(?i)(rfid:)?[0-9a-f]{2}([:\- ]?[0-9a-f]{2})+$`).MatchString,
+		Normalise: normaliseRFID,
+		Validate:  regexp.MustCompile(`^([0-9A-F]{8}|[0-9A-F]{10}|[0-9A-F]{14}|[0-9A-F]{20})$`).MatchString,
+	}
+	// SignedFormat accepts signed paper permissions. Only the structure is
+	// checked here, signatures are verified by the agent.
+	SignedFormat = TokenFormat{
+		Name:      "signed",
+		Source:    Paper,
+		Match:     hasPrefix("GC1."),
+		Normalise: trimPrefix(""),
+		Validate:  regexp.MustCompile(`^GC1\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`).MatchString,
+	}
+	// ContainerFormat accepts ISO 6346 container numbers.
+	ContainerFormat = TokenFormat{
+		Name:      "container",
+		Source:    Container,
+		Match:     regexp.MustCompile(`^(?i)[a-z]{4}[ \-]?[0-9]{6}[ \-]?[0-9]$`).MatchString,
+		Normalise: normaliseContainer,
+		Validate:  isContainerNumber,
+	}
+
+	// DefaultFormats accepts paper and TRUCKERS_TRUST permissions.
+	DefaultFormats = Formats{TruckersTrustFormat, PaperFormat}
+)
+
+var builtinFormats = map[string]TokenFormat{
+	TruckersTrustFormat.Name: TruckersTrustFormat,
+	PaperFormat.Name:         PaperFormat,
+	RFIDFormat.Name:          RFIDFormat,
+	SignedFormat.Name:        SignedFormat,
+	ContainerFormat.Name:     ContainerFormat,
+}
+
+// BuiltinFormat returns the built-in token format identified by name.
+func BuiltinFormat(name string) (TokenFormat, bool) {
+	format, ok := builtinFormats[name]
+	return format, ok
+}
+
+// NewPatternFormat creates a token format for tokens starting with prefix.
+// The prefix is removed and the remaining content must match pattern.
+func NewPatternFormat(name string, source ScanSource, prefix, pattern string) (TokenFormat, error) {
+	if source == "" || source == Invalid {
+		return TokenFormat{}, fmt.Errorf("invalid scan source for token format %s: %q", name, source)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return TokenFormat{}, fmt.Errorf("invalid pattern for token format %s: %v", name, err)
+	}
+	return TokenFormat{
+		Name:      name,
+		Source:    source,
+		Match:     hasPrefix(prefix),
+		Normalise: trimPrefix(prefix),
+		Validate:  re.MatchString,
+	}, nil
+}
+
+func hasPrefix(prefix string) func(string) bool {
+	return func(raw string) bool {
+		return strings.HasPrefix(raw, prefix)
+	}
+}
+
+func trimPrefix(prefix string) func(string) string {
+	return func(raw string) string {
+		return strings.TrimPrefix(raw, prefix)
+	}
+}
+
+func isUUID(content string) bool {
+	_, err := uuid.Parse(content)
+	return err == nil
+}
+
+var separators = strings.NewReplacer(":", "", "-", "", " ", "")
+
+func normaliseRFID(raw string) string {
+	if len(raw) >= 5 && strings.EqualFold(raw[:5], "rfid:") {
+		raw = raw[5:]
+	}
+	return strings.ToUpper(separators.Replace(raw))
+}
+
+func normaliseContainer(raw string) string {
+	return strings.ToUpper(separators.Replace(raw))
+}
+
+// isContainerNumber validates the check digit of an ISO 6346 container
+// number.
+func isContainerNumber(content string) bool {
+	if len(content) != 11 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 10; i++ {
+		c := content[i]
+		var value int
+		switch {
+		case c >= 'A' && c <= 'Z':
+			// Letters count from 10, skipping multiples of 11.
+			value = int(c-'A') + 10
+			value += (value - 1) / 10
+		case c >= '0' && c <= '9':
+			value = int(c - '0')
+		default:
+			return false
+		}
+		sum += value << uint(i)
+	}
+
+	return int(content[10]-'0') == sum%11%10
+}
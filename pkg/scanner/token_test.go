@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testUUID = "4e4e3c62-3d47-4c8b-9f41-8a3ea3f7f2c1"
+
+func TestNewToken(t *testing.T) {
+	t.Run("creates paper tokens", func(t *testing.T) {
+		assert.Equal(t, &Token{testUUID, "scanner", Paper}, NewToken(testUUID, "scanner"))
+	})
+	t.Run("strips static QR prefix of paper tokens", func(t *testing.T) {
+		assert.Equal(t, &Token{testUUID, "scanner", Paper}, NewToken("QR"+testUUID, "scanner"))
+	})
+	t.Run("creates truckers trust tokens", func(t *testing.T) {
+		assert.Equal(t, &Token{testUUID, "scanner", TruckersTrust}, NewToken("tt-"+testUUID, "scanner"))
+	})
+	t.Run("strips static QR prefix of truckers trust tokens", func(t *testing.T) {
+		assert.Equal(t, &Token{testUUID, "scanner", TruckersTrust}, NewToken("QRtt-"+testUUID, "scanner"))
+	})
+	t.Run("marks unknown tokens invalid", func(t *testing.T) {
+		token := NewToken("ghost", "scanner")
+		assert.Equal(t, &Token{"ghost", "scanner", Invalid}, token)
+		assert.False(t, token.IsValid())
+	})
+	t.Run("marks truckers trust tokens without uuid invalid", func(t *testing.T) {
+		assert.False(t, NewToken("tt-ghost", "scanner").IsValid())
+		assert.Equal(t, &Token{"QRtt-ghost", "scanner", Invalid}, NewToken("QRtt-ghost", "scanner"))
+	})
+}
+
+func TestFormats_Token(t *testing.T) {
+	formats := Formats{TruckersTrustFormat, PaperFormat, RFIDFormat, ContainerFormat}
+
+	t.Run("normalises rfid card uids", func(t *testing.T) {
+		for _, raw := range []string{"04a2241a", "rfid:04A2241A", "04:a2:24:1a", "04-A2-24-1A"} {
+			assert.Equal(t, &Token{"04A2241A", "scanner", RFID}, formats.Token(raw, "scanner"), raw)
+		}
+		assert.Equal(t, &Token{"04A2241AB25E80", "scanner", RFID}, formats.Token("04a2241ab25e80", "scanner"))
+	})
+	t.Run("rejects rfid uids of invalid length", func(t *testing.T) {
+		assert.False(t, formats.Token("04a224", "scanner").IsValid())
+	})
+	t.Run("validates container numbers", func(t *testing.T) {
+		assert.Equal(t, &Token{"CSQU3054383", "scanner", Container}, formats.Token("csqu 305438-3", "scanner"))
+		assert.False(t, formats.Token("CSQU3054384", "scanner").IsValid())
+	})
+	t.Run("accepts well-formed signed tokens", func(t *testing.T) {
+		formats := Formats{PaperFormat, SignedFormat}
+		assert.Equal(t, &Token{"GC1.eyJwaWQiOiIxIn0.c2ln", "scanner", Paper}, formats.Token("GC1.eyJwaWQiOiIxIn0.c2ln", "scanner"))
+		assert.False(t, formats.Token("GC1.eyJwaWQiOiIxIn0", "scanner").IsValid())
+	})
+	t.Run("respects order of formats", func(t *testing.T) {
+		custom, err := NewPatternFormat("custom", "CUSTOM", "", "^[0-9A-F]{8}$")
+		assert.NoError(t, err)
+		formats := Formats{custom, RFIDFormat}
+		assert.Equal(t, ScanSource("CUSTOM"), formats.Token("04A2241A", "scanner").ScanSource)
+	})
+	t.Run("returns names", func(t *testing.T) {
+		assert.Equal(t, []string{"truckers-trust", "paper", "rfid", "container"}, formats.Names())
+	})
+}
+
+func TestNewPatternFormat(t *testing.T) {
+	t.Run("strips prefix and validates pattern", func(t *testing.T) {
+		format, err := NewPatternFormat("partner", "PARTNER", "px-", "^[0-9]{6}$")
+		assert.NoError(t, err)
+		formats := Formats{format}
+		assert.Equal(t, &Token{"123456", "scanner", "PARTNER"}, formats.Token("px-123456", "scanner"))
+		assert.False(t, formats.Token("px-12345", "scanner").IsValid())
+		assert.False(t, formats.Token("123456", "scanner").IsValid())
+	})
+	t.Run("returns error for invalid pattern", func(t *testing.T) {
+		_, err := NewPatternFormat("partner", "PARTNER", "", "(")
+		assert.Error(t, err)
+	})
+	t.Run("returns error for invalid scan source", func(t *testing.T) {
+		_, err := NewPatternFormat("partner", Invalid, "", ".*")
+		assert.Error(t, err)
+	})
+}
+
+func TestBuiltinFormat(t *testing.T) {
+	format, ok := BuiltinFormat("rfid")
+	assert.True(t, ok)
+	assert.Equal(t, RFID, format.Source)
+
+	_, ok = BuiltinFormat("x")
+	assert.False(t, ok)
+}
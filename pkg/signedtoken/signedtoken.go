@@ -0,0 +1,197 @@
+// Package signedtoken verifies signed tokens, i.e. QR payloads carrying a
+// permission ID and an expiry signed by the issuer, without asking the
+// backend.
+//
+// A signed token reads "GC1.<payload>.<signature>". The payload is a JSON
+// object, the signature is computed over "GC1.<payload>". Both are encoded
+// using unpadded base64url.
+package signedtoken
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Prefix starts every signed token.
+const Prefix = "GC1."
+
+var (
+	// ErrMalformed is returned for tokens that cannot be decoded.
+	ErrMalformed = errors.New("malformed signed token")
+	// ErrUnknownKey is returned for tokens signed with a key not configured.
+	ErrUnknownKey = errors.New("signed token has unknown key")
+	// ErrSignature is returned for tokens with an invalid signature.
+	ErrSignature = errors.New("signed token has invalid signature")
+	// ErrExpired is returned for expired tokens.
+	ErrExpired = errors.New("signed token has expired")
+)
+
+// An Algorithm identifies how tokens are signed.
+type Algorithm string
+
+const (
+	// Ed25519 signatures are verified with the public key of the issuer.
+	Ed25519 Algorithm = "ed25519"
+	// HMACSHA256 signatures are verified with a secret shared with the
+	// issuer.
+	HMACSHA256 Algorithm = "hmac-sha256"
+)
+
+// Claims are the verified contents of a signed token.
+type Claims struct {
+	PermissionID string
+	Expiry       time.Time
+	KeyID        string
+}
+
+type payload struct {
+	PermissionID string `json:"pid"`
+	Expiry       int64  `json:"exp"`
+	KeyID        string `json:"kid"`
+}
+
+// A Key verifies the tokens of one issuer key.
+type Key struct {
+	ID        string
+	Algorithm Algorithm
+	// Material is the public key for Ed25519 and the secret for HMACSHA256.
+	Material []byte
+}
+
+// NewKey creates a key from its base64 encoded material.
+func NewKey(id string, algorithm Algorithm, material string) (Key, error) {
+	b, err := base64.StdEncoding.DecodeString(material)
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid key material: %v", err)
+	}
+
+	switch algorithm {
+	case Ed25519:
+		if len(b) != ed25519.PublicKeySize {
+			return Key{}, fmt.Errorf("invalid ed25519 public key size: %d", len(b))
+		}
+	case HMACSHA256:
+		if len(b) < sha256.Size {
+			return Key{}, fmt.Errorf("hmac secret must have at least %d bytes", sha256.Size)
+		}
+	default:
+		return Key{}, fmt.Errorf("undefined algorithm: %s", algorithm)
+	}
+
+	return Key{ID: id, Algorithm: algorithm, Material: b}, nil
+}
+
+func (k Key) verify(message, signature []byte) bool {
+	switch k.Algorithm {
+	case Ed25519:
+		return ed25519.Verify(ed25519.PublicKey(k.Material), message, signature)
+	case HMACSHA256:
+		return hmac.Equal(k.mac(message), signature)
+	}
+	return false
+}
+
+func (k Key) mac(message []byte) []byte {
+	mac := hmac.New(sha256.New, k.Material)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// IsSigned reports whether token looks like a signed token.
+func IsSigned(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// A Verifier verifies signed tokens against a set of keys.
+type Verifier struct {
+	keys map[string]Key
+	now  func() time.Time
+}
+
+// NewVerifier creates a verifier accepting tokens signed with keys.
+func NewVerifier(keys ...Key) *Verifier {
+	v := &Verifier{keys: map[string]Key{}, now: time.Now}
+	for _, key := range keys {
+		v.keys[key.ID] = key
+	}
+	return v
+}
+
+// Verify checks the signature and expiry of token and returns its claims.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	if !IsSigned(token) {
+		return Claims{}, ErrMalformed
+	}
+	parts := strings.Split(strings.TrimPrefix(token, Prefix), ".")
+	if len(parts) != 2 {
+		return Claims{}, ErrMalformed
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	var p payload
+	if err := json.Unmarshal(raw, &p); err != nil || p.PermissionID == "" || p.Expiry == 0 {
+		return Claims{}, ErrMalformed
+	}
+
+	key, ok := v.keys[p.KeyID]
+	if !ok {
+		return Claims{}, fmt.Errorf("%w: %s", ErrUnknownKey, p.KeyID)
+	}
+	if !key.verify([]byte(Prefix+parts[0]), signature) {
+		return Claims{}, ErrSignature
+	}
+
+	claims := Claims{
+		PermissionID: p.PermissionID,
+		Expiry:       time.Unix(p.Expiry, 0),
+		KeyID:        p.KeyID,
+	}
+	if !v.now().Before(claims.Expiry) {
+		return Claims{}, fmt.Errorf("%w at %v", ErrExpired, claims.Expiry)
+	}
+	return claims, nil
+}
+
+// Sign creates a signed token for claims. The key ID of the claims selects the
+// key on verification. For Ed25519, signingKey is the private key of the
+// issuer, for HMACSHA256 the shared secret.
+func Sign(claims Claims, algorithm Algorithm, signingKey []byte) (string, error) {
+	raw, err := json.Marshal(payload{
+		PermissionID: claims.PermissionID,
+		Expiry:       claims.Expiry.Unix(),
+		KeyID:        claims.KeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	message := Prefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	var signature []byte
+	switch algorithm {
+	case Ed25519:
+		if len(signingKey) != ed25519.PrivateKeySize {
+			return "", fmt.Errorf("invalid ed25519 private key size: %d", len(signingKey))
+		}
+		signature = ed25519.Sign(ed25519.PrivateKey(signingKey), []byte(message))
+	case HMACSHA256:
+		signature = Key{Material: signingKey}.mac([]byte(message))
+	default:
+		return "", fmt.Errorf("undefined algorithm: %s", algorithm)
+	}
+
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,122 @@
+package signedtoken
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	now    = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	secret = []byte("0123456789abcdef0123456789abcdef")
+	claims = Claims{
+		PermissionID: "4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5",
+		Expiry:       now.Add(time.Hour),
+		KeyID:        "hq",
+	}
+)
+
+func testVerifier(keys ...Key) *Verifier {
+	v := NewVerifier(keys...)
+	v.now = func() time.Time { return now }
+	return v
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	edKey, err := NewKey("hq", Ed25519, base64.StdEncoding.EncodeToString(public))
+	assert.NoError(t, err)
+	hmacKey, err := NewKey("hq", HMACSHA256, base64.StdEncoding.EncodeToString(secret))
+	assert.NoError(t, err)
+
+	t.Run("accepts ed25519 tokens", func(t *testing.T) {
+		token, err := Sign(claims, Ed25519, private)
+		assert.NoError(t, err)
+
+		verified, err := testVerifier(edKey).Verify(token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, claims.PermissionID, verified.PermissionID)
+		assert.True(t, claims.Expiry.Equal(verified.Expiry))
+		assert.Equal(t, "hq", verified.KeyID)
+	})
+	t.Run("accepts hmac tokens", func(t *testing.T) {
+		token, err := Sign(claims, HMACSHA256, secret)
+		assert.NoError(t, err)
+
+		_, err = testVerifier(hmacKey).Verify(token)
+
+		assert.NoError(t, err)
+	})
+	t.Run("rejects forged tokens", func(t *testing.T) {
+		_, forger, _ := ed25519.GenerateKey(nil)
+		token, _ := Sign(claims, Ed25519, forger)
+
+		_, err := testVerifier(edKey).Verify(token)
+
+		assert.Equal(t, ErrSignature, err)
+	})
+	t.Run("rejects tampered payloads", func(t *testing.T) {
+		token, _ := Sign(claims, HMACSHA256, secret)
+		other := claims
+		other.PermissionID = "00000000-0000-0000-0000-000000000000"
+		forged, _ := Sign(other, HMACSHA256, []byte("wrong secret"))
+		parts := strings.Split(token, ".")
+		tampered := strings.Join([]string{parts[0], strings.Split(forged, ".")[1], parts[2]}, ".")
+
+		_, err := testVerifier(hmacKey).Verify(tampered)
+
+		assert.Equal(t, ErrSignature, err)
+	})
+	t.Run("rejects expired tokens", func(t *testing.T) {
+		expired := claims
+		expired.Expiry = now.Add(-time.Second)
+		token, _ := Sign(expired, HMACSHA256, secret)
+
+		_, err := testVerifier(hmacKey).Verify(token)
+
+		assert.True(t, errors.Is(err, ErrExpired))
+	})
+	t.Run("rejects unknown keys", func(t *testing.T) {
+		other := claims
+		other.KeyID = "branch"
+		token, _ := Sign(other, HMACSHA256, secret)
+
+		_, err := testVerifier(hmacKey).Verify(token)
+
+		assert.True(t, errors.Is(err, ErrUnknownKey))
+	})
+	t.Run("rejects malformed tokens", func(t *testing.T) {
+		for _, token := range []string{
+			"4d31a9e2-0f71-4c1e-9a4e-8e2b4f7c11d5",
+			"GC1.",
+			"GC1.e30.",
+			"GC1.!!.AAAA",
+			"GC1.a.b.c",
+		} {
+			_, err := testVerifier(hmacKey).Verify(token)
+			assert.Equal(t, ErrMalformed, err, token)
+		}
+	})
+}
+
+func TestNewKey(t *testing.T) {
+	t.Run("rejects short hmac secrets", func(t *testing.T) {
+		_, err := NewKey("hq", HMACSHA256, base64.StdEncoding.EncodeToString([]byte("secret")))
+		assert.Error(t, err)
+	})
+	t.Run("rejects invalid public keys", func(t *testing.T) {
+		_, err := NewKey("hq", Ed25519, base64.StdEncoding.EncodeToString(secret[:16]))
+		assert.Error(t, err)
+	})
+	t.Run("rejects unknown algorithms", func(t *testing.T) {
+		_, err := NewKey("hq", "rsa", base64.StdEncoding.EncodeToString(secret))
+		assert.Error(t, err)
+	})
+}
@@ -22,7 +22,21 @@ type EntityStatus struct {
 }
 
 type GateStatus EntityStatus
-type ScannerStatus EntityStatus
+
+type ScannerStatus struct {
+	Name   string `json:"name"`
+	Gate   string `json:"gate,omitempty"`
+	Status string `json:"status"`
+	*ScannerDiagnostics
+}
+
+// ScannerDiagnostics help to tell a disconnected scanner from a flapping one.
+type ScannerDiagnostics struct {
+	StateSeconds    int64  `json:"stateSeconds"`
+	LastReadSeconds *int64 `json:"lastReadSeconds,omitempty"`
+	ReopenAttempts  int    `json:"reopenAttempts"`
+	LastError       string `json:"lastError,omitempty"`
+}
 
 type Status struct {
 	Hostname    string          `json:"hostname"`
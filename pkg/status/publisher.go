@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"time"
 
 	"github.com/streadway/amqp"
 )
@@ -13,6 +14,10 @@ type Channel interface {
 	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
 }
 
+// GateMaintenance is the status of gates whose lane is locked, e.g. while the
+// barrier is repaired. It is published instead of the state of the gate.
+const GateMaintenance = "MAINTENANCE"
+
 type Publisher struct {
 	name         string
 	instance     int64
@@ -21,30 +26,79 @@ type Publisher struct {
 
 	ch Channel
 
-	gateStatus    map[string]string
-	scannerStatus map[string]string
+	gateStatus     map[string]string
+	maintenance    map[string]bool
+	scannerStatus  map[string]string
+	scannerGate    map[string]string
+	scannerDetails map[string]ScannerDetails
+}
+
+// ScannerDetails are the diagnostics of a scanner as of the last update.
+type ScannerDetails struct {
+	Since          time.Time
+	LastRead       time.Time
+	ReopenAttempts int
+	LastError      error
+}
+
+func (d ScannerDetails) diagnostics(now time.Time) *ScannerDiagnostics {
+	diagnostics := &ScannerDiagnostics{
+		StateSeconds:   int64(now.Sub(d.Since) / time.Second),
+		ReopenAttempts: d.ReopenAttempts,
+	}
+	if !d.LastRead.IsZero() {
+		lastRead := int64(now.Sub(d.LastRead) / time.Second)
+		diagnostics.LastReadSeconds = &lastRead
+	}
+	if d.LastError != nil {
+		diagnostics.LastError = d.LastError.Error()
+	}
+	return diagnostics
 }
 
 func NewPublisher(name string, instance int64, location string, loadingPlace int64, ch Channel) *Publisher {
 	return &Publisher{
-		name:          name,
-		instance:      instance,
-		location:      location,
-		loadingPlace:  loadingPlace,
-		ch:            ch,
-		gateStatus:    make(map[string]string),
-		scannerStatus: make(map[string]string),
+		name:           name,
+		instance:       instance,
+		location:       location,
+		loadingPlace:   loadingPlace,
+		ch:             ch,
+		gateStatus:     make(map[string]string),
+		maintenance:    make(map[string]bool),
+		scannerStatus:  make(map[string]string),
+		scannerGate:    make(map[string]string),
+		scannerDetails: make(map[string]ScannerDetails),
 	}
 }
 
+// UpdateGate publishes the state of a gate: OPEN, CLOSED, MOVING or FAULT, or
+// UNKNOWN for gates not queried yet and for gates that can neither report
+// their state nor be closed by the agent. Agents before gate states published
+// UP for all gates.
 func (p *Publisher) UpdateGate(name, status string) {
 	p.gateStatus[name] = status
 }
 
+// UpdateGateMaintenance publishes the gate as in maintenance until it is
+// updated again, regardless of its state.
+func (p *Publisher) UpdateGateMaintenance(name string, maintenance bool) {
+	p.maintenance[name] = maintenance
+}
+
 func (p *Publisher) UpdateScanner(name, status string) {
 	p.scannerStatus[name] = status
 }
 
+// AssignScanner publishes the scanner as one of the scanners of gate.
+func (p *Publisher) AssignScanner(name, gate string) {
+	p.scannerGate[name] = gate
+}
+
+// UpdateScannerDetails updates the diagnostics published for a scanner.
+func (p *Publisher) UpdateScannerDetails(name string, details ScannerDetails) {
+	p.scannerDetails[name] = details
+}
+
 func (p *Publisher) Publish() error {
 	status := p.status()
 
@@ -85,11 +139,19 @@ func (p *Publisher) status() Status {
 	hostname := getHostname()
 
 	for name, status := range p.gateStatus {
+		if p.maintenance[name] {
+			status = GateMaintenance
+		}
 		gates = append(gates, GateStatus{name, status})
 	}
 
+	now := time.Now()
 	for name, status := range p.scannerStatus {
-		scanners = append(scanners, ScannerStatus{name, status})
+		scanner := ScannerStatus{Name: name, Gate: p.scannerGate[name], Status: status}
+		if details, ok := p.scannerDetails[name]; ok {
+			scanner.ScannerDiagnostics = details.diagnostics(now)
+		}
+		scanners = append(scanners, scanner)
 	}
 
 	return Status{
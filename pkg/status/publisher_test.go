@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
@@ -82,8 +83,8 @@ func TestPublisherStatus(t *testing.T) {
 
 		gate1 := GateStatus{"gate-1", "UP"}
 		gate2 := GateStatus{"gate-2", "DOWN"}
-		scanner1 := ScannerStatus{"scanner-1", "UP"}
-		scanner2 := ScannerStatus{"scanner-2", "DOWN"}
+		scanner1 := ScannerStatus{Name: "scanner-1", Status: "UP"}
+		scanner2 := ScannerStatus{Name: "scanner-2", Status: "DOWN"}
 
 		publisher.UpdateGate(gate1.Name, gate1.Status)
 		publisher.UpdateGate(gate2.Name, gate2.Status)
@@ -100,3 +101,75 @@ func TestPublisherStatus(t *testing.T) {
 		assert.ElementsMatch(t, []ScannerStatus{scanner1, scanner2}, status.Scanners)
 	})
 }
+
+func TestPublisherUpdateGateMaintenance(t *testing.T) {
+	t.Run("publishs gates in maintenance", func(t *testing.T) {
+		publisher := NewPublisher("test", 23, "terminal", 42, nil)
+
+		publisher.UpdateGate("gate-1", "CLOSED")
+		publisher.UpdateGateMaintenance("gate-1", true)
+		publisher.UpdateGate("gate-1", "OPEN")
+
+		assert.Equal(t, []GateStatus{{"gate-1", GateMaintenance}}, publisher.status().Gates)
+	})
+	t.Run("publishs gate state after maintenance", func(t *testing.T) {
+		publisher := NewPublisher("test", 23, "terminal", 42, nil)
+
+		publisher.UpdateGate("gate-1", "CLOSED")
+		publisher.UpdateGateMaintenance("gate-1", true)
+		publisher.UpdateGateMaintenance("gate-1", false)
+
+		assert.Equal(t, []GateStatus{{"gate-1", "CLOSED"}}, publisher.status().Gates)
+	})
+}
+
+func TestPublisherScannerDetails(t *testing.T) {
+	t.Run("publishs scanner diagnostics", func(t *testing.T) {
+		ch := DummyChannel{}
+		publisher := NewPublisher("test", 23, "terminal", 42, &ch)
+
+		now := time.Now()
+		publisher.UpdateScanner("scanner-1", "DOWN")
+		publisher.UpdateScannerDetails("scanner-1", ScannerDetails{
+			Since:          now.Add(-90 * time.Second),
+			LastRead:       now.Add(-time.Hour),
+			ReopenAttempts: 4,
+			LastError:      fmt.Errorf("no such device"),
+		})
+
+		err := publisher.Publish()
+
+		assert.NoError(t, err)
+		assert.Contains(t, string(ch.calls[0].msg.Body),
+			"\"scanners\":[{\"name\":\"scanner-1\",\"status\":\"DOWN\","+
+				"\"stateSeconds\":90,\"lastReadSeconds\":3600,\"reopenAttempts\":4,\"lastError\":\"no such device\"}]")
+	})
+	t.Run("omits last read for scanners that never read", func(t *testing.T) {
+		publisher := NewPublisher("test", 23, "terminal", 42, nil)
+
+		publisher.UpdateScanner("scanner-1", "UP")
+		publisher.UpdateScannerDetails("scanner-1", ScannerDetails{Since: time.Now()})
+
+		status := publisher.status()
+
+		assert.Nil(t, status.Scanners[0].LastReadSeconds)
+		assert.Equal(t, int64(0), status.Scanners[0].StateSeconds)
+	})
+}
+
+func TestPublisherAssignScanner(t *testing.T) {
+	t.Run("publishs the gate of scanners", func(t *testing.T) {
+		ch := DummyChannel{}
+		publisher := NewPublisher("test", 23, "terminal", 42, &ch)
+
+		publisher.UpdateGate("entry-1", "UP")
+		publisher.UpdateScanner("scanner-1", "UP")
+		publisher.AssignScanner("scanner-1", "entry-1")
+
+		err := publisher.Publish()
+
+		assert.NoError(t, err)
+		assert.Contains(t, string(ch.calls[0].msg.Body),
+			"\"scanners\":[{\"name\":\"scanner-1\",\"gate\":\"entry-1\",\"status\":\"UP\"}]")
+	})
+}
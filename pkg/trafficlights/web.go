@@ -18,22 +18,46 @@ type StatusOnline struct {
 	IsOnline bool
 }
 
+// StatusQueue tells the display how many scans wait to be handled.
+type StatusQueue struct {
+	QueueDepth int
+}
+
+// StatusGate tells the display the state of the gate, e.g. to show faults.
+type StatusGate struct {
+	GateState string
+}
+
+// StatusMaintenance tells the display whether the lane is closed, e.g. for
+// works on the barrier.
+type StatusMaintenance struct {
+	Maintenance bool
+}
+
 type Webserver struct {
 	fsmDataChan     chan interface{}
 	manualDataChan  chan bool
 	ShutdownChannel chan struct{}
 	onlineChannel   chan bool
+	gateChannel     chan worker.GateStatus
 	upgrader        websocket.Upgrader
 	connections     []*websocket.Conn
 	mu              sync.Mutex
+
+	// gateStatus is the last known state of the gate, sent to new displays.
+	gateStatus *StatusGate
+	// maintenance is the last known lock state of the lane, sent to new
+	// displays.
+	maintenance *StatusMaintenance
 }
 
-func NewWebserver(fsmDataChan chan interface{}, manualDataChan chan bool, onlineChannel chan bool, shutdownChannel chan struct{}) *Webserver {
+func NewWebserver(fsmDataChan chan interface{}, manualDataChan chan bool, onlineChannel chan bool, gateChannel chan worker.GateStatus, shutdownChannel chan struct{}) *Webserver {
 	return &Webserver{
 		fsmDataChan:     fsmDataChan,
 		manualDataChan:  manualDataChan,
 		ShutdownChannel: shutdownChannel,
 		onlineChannel:   onlineChannel,
+		gateChannel:     gateChannel,
 		upgrader:        websocket.Upgrader{},
 		connections:     []*websocket.Conn{},
 	}
@@ -100,6 +124,53 @@ func (ws *Webserver) informIsOnline(isOnline bool) {
 	ws.mu.Unlock()
 }
 
+func (ws *Webserver) informQueue(queueStatus worker.QueueStatus) {
+	ws.mu.Lock()
+	statusQueue := StatusQueue{
+		QueueDepth: queueStatus.Depth,
+	}
+
+	for i, conn := range ws.connections {
+		if err := conn.WriteJSON(statusQueue); err != nil {
+			log.Println("can't write", err)
+			ws.removeConnection(i)
+		}
+	}
+	ws.mu.Unlock()
+}
+
+func (ws *Webserver) informGate(gateStatus worker.GateStatus) {
+	ws.mu.Lock()
+	statusGate := StatusGate{
+		GateState: string(gateStatus.State),
+	}
+	ws.gateStatus = &statusGate
+
+	for i, conn := range ws.connections {
+		if err := conn.WriteJSON(statusGate); err != nil {
+			log.Println("can't write", err)
+			ws.removeConnection(i)
+		}
+	}
+	ws.mu.Unlock()
+}
+
+func (ws *Webserver) informMaintenance(lockStatus worker.LockStatus) {
+	ws.mu.Lock()
+	statusMaintenance := StatusMaintenance{
+		Maintenance: lockStatus.Locked,
+	}
+	ws.maintenance = &statusMaintenance
+
+	for i, conn := range ws.connections {
+		if err := conn.WriteJSON(statusMaintenance); err != nil {
+			log.Println("can't write", err)
+			ws.removeConnection(i)
+		}
+	}
+	ws.mu.Unlock()
+}
+
 func (ws *Webserver) echo(w http.ResponseWriter, r *http.Request) {
 	c, err := ws.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -109,6 +180,18 @@ func (ws *Webserver) echo(w http.ResponseWriter, r *http.Request) {
 	ws.connections = append(ws.connections, c)
 	indexOfConnection := len(ws.connections) - 1
 	defer c.Close()
+	ws.mu.Lock()
+	if ws.gateStatus != nil {
+		if err := c.WriteJSON(ws.gateStatus); err != nil {
+			log.Println("can't write", err)
+		}
+	}
+	if ws.maintenance != nil {
+		if err := c.WriteJSON(ws.maintenance); err != nil {
+			log.Println("can't write", err)
+		}
+	}
+	ws.mu.Unlock()
 	for {
 		_, _, err := c.ReadMessage()
 		if err != nil {
@@ -123,10 +206,11 @@ func (ws *Webserver) Start(listenAddress string) error {
 	log.Println("starting traffic lights on", listenAddress)
 	box := packr.New("staticAssets", "./staticAssets")
 
-	http.Handle("/", http.FileServer(box))
-
-	http.HandleFunc("/echo", ws.echo)
-	return http.ListenAndServe(listenAddress, nil)
+	// Every gate has its own display, so do not use the default mux.
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(box))
+	mux.HandleFunc("/echo", ws.echo)
+	return http.ListenAndServe(listenAddress, mux)
 }
 
 func (ws *Webserver) Listen() {
@@ -134,9 +218,16 @@ func (ws *Webserver) Listen() {
 
 		select {
 		case fsmData := <-ws.fsmDataChan:
-			fsmDataCasted := fsmData.(worker.FsmScanRequest)
-			log.Println("Received fsm state", fsmDataCasted.State)
-			ws.inform(fsmDataCasted)
+			switch data := fsmData.(type) {
+			case worker.FsmScanRequest:
+				log.Println("Received fsm state", data.State)
+				ws.inform(data)
+			case worker.QueueStatus:
+				ws.informQueue(data)
+			case worker.LockStatus:
+				log.Println("Received lock status", data.Locked)
+				ws.informMaintenance(data)
+			}
 			break
 		case <-ws.manualDataChan:
 			ws.informManualOpen()
@@ -144,6 +235,9 @@ func (ws *Webserver) Listen() {
 		case isOnline := <-ws.onlineChannel:
 			ws.informIsOnline(isOnline)
 			break
+		case gateStatus := <-ws.gateChannel:
+			ws.informGate(gateStatus)
+			break
 		case <-ws.ShutdownChannel:
 			close(ws.fsmDataChan)
 			return
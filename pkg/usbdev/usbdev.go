@@ -0,0 +1,172 @@
+// Package usbdev finds the device nodes of USB devices through sysfs, so
+// devices can be addressed independent of the order the kernel enumerated them.
+package usbdev
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned if no device matches a selector.
+var ErrNotFound = errors.New("no matching usb device")
+
+// A Class is a sysfs device class, e.g. tty. Only device nodes starting with
+// Prefix are considered.
+type Class struct {
+	Name   string
+	Prefix string
+}
+
+var (
+	// TTY are serial ports, e.g. /dev/ttyACM0.
+	TTY = Class{Name: "tty", Prefix: "tty"}
+	// Input are input event devices, e.g. /dev/input/event3.
+	Input = Class{Name: "input", Prefix: "event"}
+	// HIDRaw are raw HID devices, e.g. /dev/hidraw0.
+	HIDRaw = Class{Name: "hidraw", Prefix: "hidraw"}
+)
+
+// A Selector identifies a USB device. Empty fields match any device.
+type Selector struct {
+	// Vendor is the hexadecimal vendor ID, e.g. 0c2e.
+	Vendor string
+	// Product is the hexadecimal product ID.
+	Product string
+	// Serial is the serial number of the device.
+	Serial string
+	// Port is the physical port path, e.g. 1-1.4.
+	Port string
+}
+
+// IsZero reports whether the selector matches any device.
+func (s Selector) IsZero() bool {
+	return s == Selector{}
+}
+
+func (s Selector) String() string {
+	var parts []string
+	for _, p := range []struct{ key, value string }{
+		{"vendor", s.Vendor},
+		{"product", s.Product},
+		{"serial", s.Serial},
+		{"port", s.Port},
+	} {
+		if p.value != "" {
+			parts = append(parts, p.key+"="+p.value)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s Selector) matches(d device) bool {
+	return (s.Vendor == "" || strings.EqualFold(s.Vendor, d.vendor)) &&
+		(s.Product == "" || strings.EqualFold(s.Product, d.product)) &&
+		(s.Serial == "" || s.Serial == d.serial) &&
+		(s.Port == "" || s.Port == d.port)
+}
+
+type device struct {
+	vendor, product, serial, port string
+}
+
+// A Resolver resolves selectors through the sysfs mounted at SysfsRoot to
+// device nodes below DevRoot.
+type Resolver struct {
+	SysfsRoot string
+	DevRoot   string
+}
+
+// DefaultResolver uses the sysfs and devices of the running system.
+var DefaultResolver = Resolver{SysfsRoot: "/sys", DevRoot: "/dev"}
+
+// Resolve returns the device node of the device of class matching sel, using
+// the DefaultResolver.
+func Resolve(sel Selector, class Class) (string, error) {
+	return DefaultResolver.Resolve(sel, class)
+}
+
+// Resolve returns the device node of the device of class matching sel. If a
+// device has several nodes of the class, e.g. one per interface, the first one
+// is returned. Resolve fails if more than one device matches.
+func (r Resolver) Resolve(sel Selector, class Class) (string, error) {
+	classDir := filepath.Join(r.SysfsRoot, "class", class.Name)
+	entries, err := ioutil.ReadDir(classDir)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var node string
+	var found *device
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, class.Prefix) {
+			continue
+		}
+		d, ok := r.usbDevice(filepath.Join(classDir, name))
+		if !ok || !sel.matches(d) {
+			continue
+		}
+		if found != nil && *found != d {
+			return "", fmt.Errorf("usb device %s is ambiguous: found %s and %s", sel, found.port, d.port)
+		}
+		if found == nil {
+			found = &d
+			node = name
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, sel)
+	}
+
+	return r.devicePath(class, node), nil
+}
+
+// devicePath returns the path of a node below DevRoot. Input devices live in
+// a subdirectory of their own.
+func (r Resolver) devicePath(class Class, node string) string {
+	if class.Name == Input.Name {
+		return filepath.Join(r.DevRoot, "input", node)
+	}
+	return filepath.Join(r.DevRoot, node)
+}
+
+// usbDevice walks up from a class entry to the USB device it belongs to, i.e.
+// the first directory that has an idVendor attribute.
+func (r Resolver) usbDevice(entry string) (device, bool) {
+	dir, err := filepath.EvalSymlinks(entry)
+	if err != nil {
+		return device{}, false
+	}
+	root, err := filepath.EvalSymlinks(r.SysfsRoot)
+	if err != nil {
+		return device{}, false
+	}
+
+	for strings.HasPrefix(dir, root) && dir != root {
+		if vendor, ok := readAttr(dir, "idVendor"); ok {
+			product, _ := readAttr(dir, "idProduct")
+			serial, _ := readAttr(dir, "serial")
+			return device{
+				vendor:  vendor,
+				product: product,
+				serial:  serial,
+				port:    filepath.Base(dir),
+			}, true
+		}
+		dir = filepath.Dir(dir)
+	}
+	return device{}, false
+}
+
+func readAttr(dir, name string) (string, bool) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(b)), true
+}
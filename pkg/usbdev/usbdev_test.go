@@ -0,0 +1,124 @@
+package usbdev
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSysfs mimics the sysfs layout of USB devices: a device directory with
+// its attributes, an interface directory per node and a class entry linking
+// to the node.
+type fakeSysfs struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeSysfs(t *testing.T) *fakeSysfs {
+	root, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeSysfs{t: t, root: root}
+}
+
+func (f *fakeSysfs) addDevice(port, vendor, product, serial string, class, node string) {
+	dev := filepath.Join(f.root, "devices", "pci0000:00", "usb1", port)
+	f.write(filepath.Join(dev, "idVendor"), vendor)
+	f.write(filepath.Join(dev, "idProduct"), product)
+	if serial != "" {
+		f.write(filepath.Join(dev, "serial"), serial)
+	}
+	f.addNode(filepath.Join(dev, port+":1.0", class, node), class, node)
+}
+
+func (f *fakeSysfs) addNode(target, class, node string) {
+	f.mkdir(target)
+	f.mkdir(filepath.Join(f.root, "class", class))
+	if err := os.Symlink(target, filepath.Join(f.root, "class", class, node)); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeSysfs) write(path, content string) {
+	f.mkdir(filepath.Dir(path))
+	if err := ioutil.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeSysfs) mkdir(path string) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeSysfs) resolver() Resolver {
+	return Resolver{SysfsRoot: f.root, DevRoot: "/dev"}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	sysfs := newFakeSysfs(t)
+	defer os.RemoveAll(sysfs.root)
+
+	sysfs.addDevice("1-1.2", "0c2e", "0b61", "17050B4E3A", "tty", "ttyACM1")
+	sysfs.addDevice("1-1.4", "0c2e", "0b61", "18120B0C7F", "tty", "ttyACM0")
+	sysfs.addDevice("1-2", "05e0", "1200", "", "input", "event5")
+	sysfs.addNode(filepath.Join(sysfs.root, "devices", "virtual", "tty", "tty0"), "tty", "tty0")
+
+	r := sysfs.resolver()
+
+	t.Run("resolves serial number", func(t *testing.T) {
+		path, err := r.Resolve(Selector{Vendor: "0c2e", Serial: "18120B0C7F"}, TTY)
+		assert.NoError(t, err)
+		assert.Equal(t, "/dev/ttyACM0", path)
+	})
+	t.Run("resolves port path", func(t *testing.T) {
+		path, err := r.Resolve(Selector{Port: "1-1.2"}, TTY)
+		assert.NoError(t, err)
+		assert.Equal(t, "/dev/ttyACM1", path)
+	})
+	t.Run("ignores case of ids", func(t *testing.T) {
+		path, err := r.Resolve(Selector{Vendor: "05E0", Product: "1200"}, Input)
+		assert.NoError(t, err)
+		assert.Equal(t, "/dev/input/event5", path)
+	})
+	t.Run("fails on ambiguous selector", func(t *testing.T) {
+		_, err := r.Resolve(Selector{Vendor: "0c2e", Product: "0b61"}, TTY)
+		assert.Error(t, err)
+	})
+	t.Run("fails if nothing matches", func(t *testing.T) {
+		_, err := r.Resolve(Selector{Vendor: "0c2e", Serial: "unknown"}, TTY)
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+	t.Run("only considers nodes of the class", func(t *testing.T) {
+		_, err := r.Resolve(Selector{Vendor: "0c2e"}, HIDRaw)
+		assert.Error(t, err)
+	})
+}
+
+func TestResolver_ResolveInterfaces(t *testing.T) {
+	t.Run("picks first node of a composite device", func(t *testing.T) {
+		sysfs := newFakeSysfs(t)
+		defer os.RemoveAll(sysfs.root)
+
+		sysfs.addDevice("1-3", "05e0", "1200", "", "input", "event7")
+		dev := filepath.Join(sysfs.root, "devices", "pci0000:00", "usb1", "1-3")
+		sysfs.addNode(filepath.Join(dev, "1-3:1.1", "input", "input9", "event8"), "input", "event8")
+		sysfs.addNode(filepath.Join(dev, "1-3:1.0", "input", "input8"), "input", "input8")
+
+		path, err := sysfs.resolver().Resolve(Selector{Vendor: "05e0"}, Input)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/dev/input/event7", path)
+	})
+}
+
+func TestSelector_String(t *testing.T) {
+	assert.Equal(t, "vendor=0c2e,serial=17050B4E3A", Selector{Vendor: "0c2e", Serial: "17050B4E3A"}.String())
+	assert.True(t, Selector{}.IsZero())
+}